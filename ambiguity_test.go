@@ -0,0 +1,48 @@
+package goracler
+
+import (
+	"bytes"
+	"encoding/hex"
+	"log"
+	"strings"
+	"testing"
+
+	"github.com/manelmontilla/goracler/crypto"
+)
+
+func TestDecryptWithAmbiguityDepth(t *testing.T) {
+	key := "ee581a043ac19191c7d551710bab13a9"
+	// The last byte of the IV makes the intermediate value of the last
+	// byte of the first block 0x02, so the wrong value of the last byte,
+	// 0x00, is explored before the right one, 0x03.
+	iv := "91db4482c4ffa9858338ab0e98ddf963"
+	// The byte before the last one of the first block is 0x02, so the
+	// values of the last byte producing the pads 0x01 and 0x02 0x02 are
+	// both valid.
+	msg := "Somewhere in l\x02aMancha"
+	ct, err := crypto.CBCEncrypt(iv, key, msg)
+	if err != nil {
+		t.Fatal(err)
+	}
+	c, err := hex.DecodeString(ct)
+	if err != nil {
+		t.Fatal(err)
+	}
+	for _, opts := range [][]Option{
+		{WithAmbiguityDepth(2)},
+		{WithAmbiguityDepth(2), WithSequentialExecution()},
+	} {
+		var out bytes.Buffer
+		got, err := DecryptUnpadded(c, testOracle{key: key}, log.New(&out, "", 0), opts...)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if got != msg {
+			t.Errorf("DecryptUnpadded() = %q, want %q", got, msg)
+		}
+		if !strings.Contains(out.String(), "2 valid values found for the last byte") ||
+			!strings.Contains(out.String(), "discarding the value 0 of the last byte") {
+			t.Errorf("the attack didn't explore the branches of the last byte, log:\n%s", out.String())
+		}
+	}
+}
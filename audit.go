@@ -0,0 +1,164 @@
+package goracler
+
+import (
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"io"
+	"os"
+	"sync"
+	"time"
+)
+
+// AuditEntry is the record of a query written by an AuditOracle.
+type AuditEntry struct {
+	// Seq is the number of the query, starting at 1.
+	Seq int64 `json:"seq"`
+	// Time is the time at which the query was sent.
+	Time time.Time `json:"time"`
+	// Candidate is the hex encoded ciphertext sent to the oracle.
+	Candidate string `json:"candidate"`
+	// Result is the result returned by the oracle.
+	Result int `json:"result"`
+	// Error is the error returned by the oracle, if any.
+	Error string `json:"error,omitempty"`
+	// Elapsed is the time the oracle took to answer, in nanoseconds.
+	Elapsed time.Duration `json:"elapsed_ns"`
+}
+
+// AuditOracle is a Poracle that queries another oracle and writes a record
+// of every query, including the ones for which the oracle returned an error,
+// to provide an auditable trail of what was sent to the target. The records
+// are AuditEntry values written as JSON, one per line, and each one is written
+// with a single call to the Write method of the writer, so it can be combined
+// with a RotatingWriter to limit the size of the files. It's safe for
+// concurrent use. Unlike the HAR files written by the HTTPOracle, it works
+// with any kind of oracle.
+type AuditOracle struct {
+	q   Poracle
+	mu  sync.Mutex
+	w   io.Writer
+	seq int64
+}
+
+// NewAuditOracle returns an AuditOracle querying q and writing the records to
+// w.
+func NewAuditOracle(q Poracle, w io.Writer) *AuditOracle {
+	return &AuditOracle{q: q, w: w}
+}
+
+// Do implements the Poracle interface. It returns the error returned by the
+// writer if the record of the query can't be written.
+func (a *AuditOracle) Do(c []byte) (int, error) {
+	start := time.Now()
+	res, err := a.q.Do(c)
+	e := AuditEntry{
+		Time:      start,
+		Candidate: hex.EncodeToString(c),
+		Result:    res,
+		Elapsed:   time.Since(start),
+	}
+	if err != nil {
+		e.Error = err.Error()
+	}
+	a.mu.Lock()
+	defer a.mu.Unlock()
+	a.seq++
+	e.Seq = a.seq
+	line, merr := json.Marshal(e)
+	if merr != nil {
+		return 0, merr
+	}
+	if _, werr := a.w.Write(append(line, '\n')); werr != nil {
+		return 0, werr
+	}
+	return res, err
+}
+
+// RotatingWriter is an io.WriteCloser writing to a file that is rotated when
+// its size would exceed a limit. When the file at path is rotated it's
+// renamed to path.1, the previous path.1 to path.2, and so on, keeping at most
+// the given number of rotated files and removing the oldest one. Each call to
+// Write is written entirely to the same file, so the records written by an
+// AuditOracle are never split. It's safe for concurrent use.
+type RotatingWriter struct {
+	mu       sync.Mutex
+	path     string
+	maxBytes int64
+	maxFiles int
+	f        *os.File
+	size     int64
+}
+
+// NewRotatingWriter returns a RotatingWriter appending to the file at path,
+// which is created if it doesn't exist, rotating it when its size would
+// exceed maxBytes and keeping at most maxFiles rotated files.
+func NewRotatingWriter(path string, maxBytes int64, maxFiles int) (*RotatingWriter, error) {
+	w := &RotatingWriter{path: path, maxBytes: maxBytes, maxFiles: maxFiles}
+	if err := w.open(); err != nil {
+		return nil, err
+	}
+	return w, nil
+}
+
+func (w *RotatingWriter) open() error {
+	f, err := os.OpenFile(w.path, os.O_CREATE|os.O_WRONLY|os.O_APPEND, 0600)
+	if err != nil {
+		return err
+	}
+	info, err := f.Stat()
+	if err != nil {
+		f.Close()
+		return err
+	}
+	w.f, w.size = f, info.Size()
+	return nil
+}
+
+// Write implements the io.Writer interface.
+func (w *RotatingWriter) Write(p []byte) (int, error) {
+	w.mu.Lock()
+	defer w.mu.Unlock()
+	if w.size > 0 && w.size+int64(len(p)) > w.maxBytes {
+		if err := w.rotate(); err != nil {
+			return 0, err
+		}
+	}
+	n, err := w.f.Write(p)
+	w.size += int64(n)
+	return n, err
+}
+
+// rotate closes the current file, shifts the rotated ones and opens a new
+// file.
+func (w *RotatingWriter) rotate() error {
+	if err := w.f.Close(); err != nil {
+		return err
+	}
+	if w.maxFiles < 1 {
+		if err := os.Remove(w.path); err != nil {
+			return err
+		}
+		return w.open()
+	}
+	os.Remove(fmt.Sprintf("%s.%d", w.path, w.maxFiles))
+	for i := w.maxFiles - 1; i >= 1; i-- {
+		old := fmt.Sprintf("%s.%d", w.path, i)
+		if _, err := os.Stat(old); err == nil {
+			if err := os.Rename(old, fmt.Sprintf("%s.%d", w.path, i+1)); err != nil {
+				return err
+			}
+		}
+	}
+	if err := os.Rename(w.path, w.path+".1"); err != nil {
+		return err
+	}
+	return w.open()
+}
+
+// Close closes the current file.
+func (w *RotatingWriter) Close() error {
+	w.mu.Lock()
+	defer w.mu.Unlock()
+	return w.f.Close()
+}
@@ -0,0 +1,103 @@
+package goracler
+
+import (
+	"bufio"
+	"bytes"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+func TestAuditOracle(t *testing.T) {
+	key := "ee581a043ac19191c7d551710bab13a9"
+	c := testCiphertext(t, "Hello world")
+	var log bytes.Buffer
+	q := &countingOracle{Poracle: testOracle{key: key}}
+	if _, err := Decrypt(c, NewAuditOracle(q, &log), nopLogger{}); err != nil {
+		t.Fatal(err)
+	}
+	s := bufio.NewScanner(&log)
+	var entries, valid int64
+	seqs := make(map[int64]bool)
+	for s.Scan() {
+		var e AuditEntry
+		if err := json.Unmarshal(s.Bytes(), &e); err != nil {
+			t.Fatalf("invalid entry %q: %v", s.Text(), err)
+		}
+		entries++
+		seqs[e.Seq] = true
+		cand, err := hex.DecodeString(e.Candidate)
+		if err != nil || len(cand)%CipherBlockLen != 0 {
+			t.Errorf("invalid candidate in entry %d: %q", e.Seq, e.Candidate)
+		}
+		if e.Time.IsZero() || e.Elapsed < 0 || e.Error != "" {
+			t.Errorf("invalid entry %+v", e)
+		}
+		if e.Result > 0 {
+			valid++
+		}
+	}
+	if entries != q.queries || int64(len(seqs)) != entries {
+		t.Errorf("got %d entries with %d distinct sequence numbers for %d queries", entries, len(seqs), q.queries)
+	}
+	if valid == 0 {
+		t.Error("got no entries with valid results")
+	}
+
+	log.Reset()
+	failing := failingOracle{errors.New("connection refused")}
+	if _, err := NewAuditOracle(failing, &log).Do(c); err == nil {
+		t.Fatal("got no error from a failing oracle")
+	}
+	if !strings.Contains(log.String(), `"error":"connection refused"`) {
+		t.Errorf("the error is not recorded: %s", log.String())
+	}
+}
+
+// failingOracle simulates an oracle that always fails.
+type failingOracle struct {
+	err error
+}
+
+func (o failingOracle) Do(c []byte) (int, error) {
+	return 0, o.err
+}
+
+func TestRotatingWriter(t *testing.T) {
+	dir, err := ioutil.TempDir("", "audit")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+	path := filepath.Join(dir, "audit.jsonl")
+	w, err := NewRotatingWriter(path, 25, 2)
+	if err != nil {
+		t.Fatal(err)
+	}
+	for i := 0; i < 5; i++ {
+		if _, err := w.Write([]byte("0123456789\n")); err != nil {
+			t.Fatal(err)
+		}
+	}
+	if err := w.Close(); err != nil {
+		t.Fatal(err)
+	}
+	// Each file holds two writes, so the first one was removed.
+	for _, name := range []string{path, path + ".1", path + ".2"} {
+		data, err := ioutil.ReadFile(name)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if len(data) > 25 {
+			t.Errorf("got %d bytes in %s, want at most 25", len(data), name)
+		}
+	}
+	if _, err := os.Stat(path + ".3"); !os.IsNotExist(err) {
+		t.Errorf("got more rotated files than allowed: %v", err)
+	}
+}
@@ -0,0 +1,109 @@
+package goracler
+
+import (
+	"errors"
+	"sync"
+	"sync/atomic"
+	"time"
+)
+
+var (
+	// AutoTuneLevels defines the concurrency levels probed by AutoTune, in
+	// the order they are probed.
+	AutoTuneLevels = []int{1, 2, 4, 8, 16, 32, 64}
+
+	// AutoTuneProbes defines the number of queries sent to the oracle by
+	// AutoTune for each concurrency level.
+	AutoTuneProbes = 64
+
+	// ErrAutoTuneFailed is returned by AutoTune when the oracle returns
+	// errors even when queried with no concurrency.
+	ErrAutoTuneFailed = errors.New("oracle returned errors at the minimum concurrency level")
+)
+
+// minAutoTuneGain is the minimum throughput improvement, relative to the best
+// level found so far, that a level must provide to keep probing higher levels.
+const minAutoTuneGain = 1.1
+
+// AutoTune probes the oracle at the concurrency levels defined in the var
+// AutoTuneLevels and returns the one with the best throughput that didn't
+// produce any error. The returned value is meant to be passed to the
+// WithConcurrency option.
+//
+// The probes are built from the sampleCiphertext, which must be a valid
+// ciphertext for the oracle: half of them are the sample unmodified, and the
+// other half have the last byte of the penultimate block flipped, so no real
+// attack data is needed. AutoTune stops probing as soon as a level returns an
+// error or doesn't improve the throughput of the previous ones.
+//
+// AutoTune consumes up to len(AutoTuneLevels)*AutoTuneProbes queries. As all of
+// them are sent through q, any rate limit applied by the oracle is respected.
+// The block length of the sample is the one set with the WithBlockLen option,
+// the rest of options are ignored.
+func AutoTune(q Poracle, sampleCiphertext []byte, opts ...Option) (concurrency int, err error) {
+	cfg := newConfig(opts)
+	if err := cfg.validate(); err != nil {
+		return 0, err
+	}
+	n := len(sampleCiphertext)
+	if n < 2*cfg.blockLen || n%cfg.blockLen != 0 {
+		return 0, ErrInvalidCiphertext
+	}
+	valid := sampleCiphertext
+	invalid := make([]byte, n)
+	copy(invalid, sampleCiphertext)
+	invalid[n-cfg.blockLen-1] ^= 0xff
+
+	var best int
+	var bestRate float64
+	for _, level := range AutoTuneLevels {
+		rate, errs := probeThroughput(q, level, valid, invalid)
+		if errs > 0 {
+			break
+		}
+		if best != 0 && rate < bestRate*minAutoTuneGain {
+			break
+		}
+		best, bestRate = level, rate
+	}
+	if best == 0 {
+		return 0, ErrAutoTuneFailed
+	}
+	return best, nil
+}
+
+// probeThroughput sends AutoTuneProbes queries to the oracle using the given
+// number of workers. It returns the number of queries per second and the
+// number of errors returned by the oracle.
+func probeThroughput(q Poracle, workers int, valid, invalid []byte) (float64, int) {
+	probes := make(chan []byte, AutoTuneProbes)
+	for i := 0; i < AutoTuneProbes; i++ {
+		if i%2 == 0 {
+			probes <- valid
+		} else {
+			probes <- invalid
+		}
+	}
+	close(probes)
+
+	var errs int32
+	var wg sync.WaitGroup
+	start := time.Now()
+	for i := 0; i < workers; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			for p := range probes {
+				if atomic.LoadInt32(&errs) > 0 {
+					return
+				}
+				if _, err := q.Do(p); err != nil {
+					atomic.AddInt32(&errs, 1)
+				}
+			}
+		}()
+	}
+	wg.Wait()
+	elapsed := time.Since(start)
+	return float64(AutoTuneProbes) / elapsed.Seconds(), int(errs)
+}
@@ -0,0 +1,51 @@
+package goracler
+
+import (
+	"errors"
+	"sync/atomic"
+	"testing"
+	"time"
+)
+
+// limitedOracle simulates an oracle with latency that fails when it's queried
+// by more than max concurrent clients.
+type limitedOracle struct {
+	testOracle
+	max      int32
+	inFlight int32
+	latency  time.Duration
+}
+
+func (o *limitedOracle) Do(c []byte) (int, error) {
+	n := atomic.AddInt32(&o.inFlight, 1)
+	defer atomic.AddInt32(&o.inFlight, -1)
+	time.Sleep(o.latency)
+	if n > o.max {
+		return 0, errors.New("too many concurrent requests")
+	}
+	return o.testOracle.Do(c)
+}
+
+func TestAutoTune(t *testing.T) {
+	key := "ee581a043ac19191c7d551710bab13a9"
+	c := testCiphertext(t, "Hello world")
+	q := &limitedOracle{
+		testOracle: testOracle{key: key},
+		max:        8,
+		latency:    2 * time.Millisecond,
+	}
+	got, err := AutoTune(q, c)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if got < 2 || got > 8 {
+		t.Errorf("AutoTune() = %d, want a value in [2, 8]", got)
+	}
+}
+
+func TestAutoTuneInvalidCiphertext(t *testing.T) {
+	_, err := AutoTune(testOracle{}, make([]byte, CipherBlockLen))
+	if err != ErrInvalidCiphertext {
+		t.Errorf("AutoTune() error = %v, want %v", err, ErrInvalidCiphertext)
+	}
+}
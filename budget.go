@@ -0,0 +1,59 @@
+package goracler
+
+import (
+	"errors"
+	"sync/atomic"
+)
+
+// ErrQueryBudgetExhausted is returned when an attack needs to query the oracle
+// but its query budget is exhausted.
+var ErrQueryBudgetExhausted = errors.New("query budget exhausted")
+
+// QueryBudget defines a maximum number of queries that can be sent to an
+// oracle. The same QueryBudget can be shared by several attacks running
+// concurrently, using the WithQueryBudget option, so the total number of
+// queries sent by all of them never exceeds the budget. It's safe for
+// concurrent use.
+type QueryBudget struct {
+	remaining int64
+}
+
+// NewQueryBudget returns a QueryBudget allowing n queries.
+func NewQueryBudget(n int64) *QueryBudget {
+	return &QueryBudget{remaining: n}
+}
+
+// Remaining returns the number of queries left in the budget.
+func (b *QueryBudget) Remaining() int64 {
+	return atomic.LoadInt64(&b.remaining)
+}
+
+// take consumes one query from the budget. It returns false if the budget is
+// exhausted.
+func (b *QueryBudget) take() bool {
+	for {
+		r := atomic.LoadInt64(&b.remaining)
+		if r <= 0 {
+			return false
+		}
+		if atomic.CompareAndSwapInt64(&b.remaining, r, r-1) {
+			return true
+		}
+	}
+}
+
+// budgetOracle is a Poracle that consumes a query from a budget before
+// querying the wrapped oracle. It returns the err when the budget is
+// exhausted.
+type budgetOracle struct {
+	Poracle
+	budget *QueryBudget
+	err    error
+}
+
+func (b budgetOracle) Do(c []byte) (int, error) {
+	if !b.budget.take() {
+		return 0, b.err
+	}
+	return b.Poracle.Do(c)
+}
@@ -0,0 +1,58 @@
+package goracler
+
+import (
+	"sync"
+	"sync/atomic"
+	"testing"
+)
+
+// countingOracle counts the queries received before forwarding them to the
+// wrapped oracle.
+type countingOracle struct {
+	Poracle
+	queries int64
+}
+
+func (o *countingOracle) Do(c []byte) (int, error) {
+	atomic.AddInt64(&o.queries, 1)
+	return o.Poracle.Do(c)
+}
+
+func TestDecryptSharedQueryBudget(t *testing.T) {
+	key := "ee581a043ac19191c7d551710bab13a9"
+	c := testCiphertext(t, "Somewhere in la Mancha, in a place")
+	q := &countingOracle{Poracle: testOracle{key: key}}
+	var max int64 = 3000
+	budget := NewQueryBudget(max)
+
+	var wg sync.WaitGroup
+	errs := make([]error, 2)
+	results := make([]string, 2)
+	for i := 0; i < 2; i++ {
+		wg.Add(1)
+		go func(i int) {
+			defer wg.Done()
+			results[i], errs[i] = Decrypt(c, q, nopLogger{}, WithQueryBudget(budget))
+		}(i)
+	}
+	wg.Wait()
+
+	if got := atomic.LoadInt64(&q.queries); got > max {
+		t.Errorf("oracle received %d queries, want at most %d", got, max)
+	}
+	if budget.Remaining() != 0 {
+		t.Errorf("budget.Remaining() = %d, want 0", budget.Remaining())
+	}
+	exhausted := 0
+	for i, err := range errs {
+		if err == ErrQueryBudgetExhausted {
+			exhausted++
+		}
+		if len(results[i])%CipherBlockLen != 0 {
+			t.Errorf("partial plaintext length %d is not block aligned", len(results[i]))
+		}
+	}
+	if exhausted == 0 {
+		t.Errorf("no attack returned %v", ErrQueryBudgetExhausted)
+	}
+}
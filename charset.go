@@ -0,0 +1,37 @@
+package goracler
+
+import (
+	"fmt"
+
+	"golang.org/x/text/encoding"
+)
+
+// WithCharset makes Decrypt decode the recovered plaintext from the given
+// encoding to UTF-8 before returning it. It's applied to the whole plaintext,
+// after the PayloadExtractor, so the pad must be removed by the extractor for
+// encodings that would decode it into garbage, like UTF-16. Any encoding of
+// the golang.org/x/text/encoding packages can be used, for instance
+// charmap.ISO8859_1 or charmap.Windows1252 for Latin-1 plaintexts,
+// unicode.UTF16(unicode.LittleEndian, unicode.UseBOM) for UTF-16 plaintexts,
+// which detects the endianness from the BOM if present, or japanese.ShiftJIS.
+// By default the plaintext is returned as is. It isn't applied to the
+// plaintext written to the sink set with WithPlaintextSink, to the blocks
+// passed to the BlockCallback, nor to the plaintext recovered so far returned
+// when the attack fails.
+func WithCharset(enc encoding.Encoding) Option {
+	return func(c *config) {
+		c.charset = enc
+	}
+}
+
+// decodeCharset decodes the plaintext from the encoding set with WithCharset.
+func decodeCharset(m []byte, cfg *config) ([]byte, error) {
+	if cfg.charset == nil {
+		return m, nil
+	}
+	d, err := cfg.charset.NewDecoder().Bytes(m)
+	if err != nil {
+		return nil, fmt.Errorf("decoding the plaintext: %w", err)
+	}
+	return d, nil
+}
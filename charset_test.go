@@ -0,0 +1,32 @@
+package goracler
+
+import (
+	"testing"
+
+	"golang.org/x/text/encoding/charmap"
+)
+
+func TestDecryptWithCharset(t *testing.T) {
+	key := "ee581a043ac19191c7d551710bab13a9"
+	want := "En un lugar de la Mancha, de cuyo nombre no quiero acordarme, vivía un hidalgo"
+	latin1, err := charmap.ISO8859_1.NewEncoder().String(want)
+	if err != nil {
+		t.Fatal(err)
+	}
+	c := testCiphertext(t, latin1)
+	q := testOracle{key: key}
+	raw, err := DecryptUnpadded(c, q, nopLogger{})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if raw != latin1 {
+		t.Errorf("DecryptUnpadded() = %q, want the raw bytes %q", raw, latin1)
+	}
+	got, err := DecryptUnpadded(c, q, nopLogger{}, WithCharset(charmap.ISO8859_1))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if got != want {
+		t.Errorf("DecryptUnpadded() = %q, want %q", got, want)
+	}
+}
@@ -0,0 +1,214 @@
+package goracler
+
+import (
+	"encoding/base32"
+	"encoding/base64"
+	"encoding/hex"
+	"errors"
+	"math/big"
+	neturl "net/url"
+	"strings"
+)
+
+// Codec encodes and decodes the ciphertexts exchanged with a target. Its
+// Encode method can be used as the Encoder of the oracles in the oracle
+// package.
+type Codec interface {
+	Encode(c []byte) string
+	Decode(s string) ([]byte, error)
+}
+
+// ErrInvalidEncoding is returned when a string is not valid for a Codec.
+var ErrInvalidEncoding = errors.New("invalid encoding")
+
+type hexCodec struct{}
+
+func (hexCodec) Encode(c []byte) string { return hex.EncodeToString(c) }
+
+func (hexCodec) Decode(s string) ([]byte, error) { return hex.DecodeString(s) }
+
+type encodingCodec interface {
+	EncodeToString(src []byte) string
+	DecodeString(s string) ([]byte, error)
+}
+
+type stdCodec struct {
+	enc encodingCodec
+}
+
+func (c stdCodec) Encode(b []byte) string { return c.enc.EncodeToString(b) }
+
+func (c stdCodec) Decode(s string) ([]byte, error) { return c.enc.DecodeString(s) }
+
+// base58Alphabet is the alphabet used by Bitcoin.
+const base58Alphabet = "123456789ABCDEFGHJKLMNPQRSTUVWXYZabcdefghijkmnopqrstuvwxyz"
+
+type base58Codec struct{}
+
+func (base58Codec) Encode(c []byte) string {
+	n := new(big.Int).SetBytes(c)
+	radix := big.NewInt(58)
+	mod := new(big.Int)
+	var out []byte
+	for n.Sign() > 0 {
+		n.DivMod(n, radix, mod)
+		out = append(out, base58Alphabet[mod.Int64()])
+	}
+	// Each leading zero byte is encoded as the first character of the
+	// alphabet.
+	for _, b := range c {
+		if b != 0 {
+			break
+		}
+		out = append(out, base58Alphabet[0])
+	}
+	for i, j := 0, len(out)-1; i < j; i, j = i+1, j-1 {
+		out[i], out[j] = out[j], out[i]
+	}
+	return string(out)
+}
+
+func (base58Codec) Decode(s string) ([]byte, error) {
+	n := new(big.Int)
+	radix := big.NewInt(58)
+	for i := 0; i < len(s); i++ {
+		d := -1
+		for j := 0; j < len(base58Alphabet); j++ {
+			if base58Alphabet[j] == s[i] {
+				d = j
+				break
+			}
+		}
+		if d < 0 {
+			return nil, ErrInvalidEncoding
+		}
+		n.Mul(n, radix)
+		n.Add(n, big.NewInt(int64(d)))
+	}
+	var zeros int
+	for zeros < len(s) && s[zeros] == base58Alphabet[0] {
+		zeros++
+	}
+	return append(make([]byte, zeros), n.Bytes()...), nil
+}
+
+var (
+	// HexCodec encodes the ciphertexts in hex.
+	HexCodec Codec = hexCodec{}
+
+	// Base64Codec encodes the ciphertexts using standard base64.
+	Base64Codec Codec = stdCodec{base64.StdEncoding}
+
+	// Base64URLCodec encodes the ciphertexts using the URL safe base64
+	// alphabet.
+	Base64URLCodec Codec = stdCodec{base64.URLEncoding}
+
+	// Base32Codec encodes the ciphertexts using standard base32.
+	Base32Codec Codec = stdCodec{base32.StdEncoding}
+
+	// Base58Codec encodes the ciphertexts using base58 with the Bitcoin
+	// alphabet.
+	Base58Codec Codec = base58Codec{}
+)
+
+// DecryptEncoded performs a decrypt attack in the same way Decrypt does for
+// a ciphertext encoded using the given Codec. The ciphertext is normalized
+// before decoding it, as NormalizeCiphertext does, but only applying the
+// transformations that make sense for the Codec.
+func DecryptEncoded(s string, codec Codec, q Poracle, l Logger, opts ...Option) (string, error) {
+	s, err := normalizeCiphertext(s, codec)
+	if err != nil {
+		return "", err
+	}
+	c, err := codec.Decode(s)
+	if err != nil {
+		return "", err
+	}
+	return Decrypt(c, q, l, opts...)
+}
+
+// lineBreaks removes the line breaks from a string.
+var lineBreaks = strings.NewReplacer("\r", "", "\n", "")
+
+// DecryptBase64 performs a decrypt attack in the same way Decrypt does for a
+// ciphertext encoded using standard base64. The ciphertext can be wrapped in
+// lines, as in the PEM or MIME formats, because the CR and LF characters are
+// removed before decoding it, and the spaces are restored to '+', as
+// NormalizeCiphertext does. Any other whitespace inside the string, like tabs,
+// makes it invalid.
+func DecryptBase64(s string, q Poracle, l Logger, opts ...Option) (string, error) {
+	return DecryptEncoded(s, Base64Codec, q, l, opts...)
+}
+
+// quotes are the characters trimmed by NormalizeCiphertext when they surround
+// the ciphertext.
+const quotes = "\"'`"
+
+// NormalizeCiphertext undoes the common mangling suffered by encoded
+// ciphertexts copied through different channels, and returns a string ready
+// to be decoded. It applies, in order, these transformations:
+//
+//   - The whitespace and the quotes, double, single or backticks, surrounding
+//     the ciphertext are trimmed, repeatedly, so "'abc'" becomes abc.
+//   - The line breaks, CR and LF, are removed, so wrapped ciphertexts are
+//     joined.
+//   - If it contains percent-encoded characters, like %2B, they are decoded.
+//   - If, ignoring spaces, it only contains hex digits and has an even
+//     length, it's considered hex: the spaces are removed and the digits
+//     lowercased.
+//   - Otherwise, the spaces are restored to '+', as URL decoding turns the
+//     '+' of base64 into spaces.
+//
+// The detection of hex is a heuristic, a base64 string containing only hex
+// digits would be lowercased, so when the encoding is known DecryptEncoded,
+// which only applies the transformations that make sense for the Codec, is
+// preferred. It returns ErrInvalidEncoding if the string is empty after
+// trimming it.
+func NormalizeCiphertext(s string) (string, error) {
+	return normalizeCiphertext(s, nil)
+}
+
+// normalizeCiphertext normalizes the ciphertext as NormalizeCiphertext does.
+// If a Codec is given, the hex transformations are only applied for HexCodec
+// and the spaces are only restored to '+' for Base64Codec.
+func normalizeCiphertext(s string, codec Codec) (string, error) {
+	for {
+		t := strings.TrimSpace(s)
+		if len(t) >= 2 && t[0] == t[len(t)-1] && strings.IndexByte(quotes, t[0]) >= 0 {
+			t = t[1 : len(t)-1]
+		}
+		if t == s {
+			break
+		}
+		s = t
+	}
+	if s == "" {
+		return "", ErrInvalidEncoding
+	}
+	s = lineBreaks.Replace(s)
+	if strings.Contains(s, "%") {
+		if u, err := neturl.PathUnescape(s); err == nil {
+			s = u
+		}
+	}
+	compact := strings.Replace(s, " ", "", -1)
+	isHex := codec == HexCodec || (codec == nil && len(compact)%2 == 0 && isHexString(compact))
+	switch {
+	case isHex:
+		return strings.ToLower(compact), nil
+	case codec == nil || codec == Base64Codec:
+		return strings.Replace(s, " ", "+", -1), nil
+	}
+	return s, nil
+}
+
+// isHexString returns true if s only contains hex digits.
+func isHexString(s string) bool {
+	for i := 0; i < len(s); i++ {
+		c := s[i]
+		if !(c >= '0' && c <= '9') && !(c >= 'a' && c <= 'f') && !(c >= 'A' && c <= 'F') {
+			return false
+		}
+	}
+	return true
+}
@@ -0,0 +1,146 @@
+package goracler
+
+import (
+	"bytes"
+	"encoding/base64"
+	"encoding/hex"
+	"strings"
+	"testing"
+
+	"github.com/manelmontilla/goracler/crypto"
+)
+
+func TestCodecs(t *testing.T) {
+	tests := []struct {
+		name  string
+		codec Codec
+		data  []byte
+		want  string
+	}{
+		{"Hex", HexCodec, []byte("Hello World!"), "48656c6c6f20576f726c6421"},
+		{"Base64", Base64Codec, []byte("Hello World!"), "SGVsbG8gV29ybGQh"},
+		{"Base64URL", Base64URLCodec, []byte{0xfb, 0xff}, "-_8="},
+		{"Base32", Base32Codec, []byte("Hello World!"), "JBSWY3DPEBLW64TMMQQQ===="},
+		{"Base58", Base58Codec, []byte("Hello World!"), "2NEpo7TZRRrLZSi2U"},
+		{"Base58LeadingZeros", Base58Codec, []byte{0, 0, 0x28, 0x7f, 0xb4, 0xcd}, "11233QC4"},
+		{"Base58Zeros", Base58Codec, []byte{0, 0}, "11"},
+	}
+	for _, tt := range tests {
+		tt := tt
+		t.Run(tt.name, func(t *testing.T) {
+			got := tt.codec.Encode(tt.data)
+			if got != tt.want {
+				t.Errorf("Encode() = %q, want %q", got, tt.want)
+			}
+			decoded, err := tt.codec.Decode(got)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if !bytes.Equal(decoded, tt.data) {
+				t.Errorf("Decode() = %x, want %x", decoded, tt.data)
+			}
+		})
+	}
+	if _, err := Base58Codec.Decode("0OIl"); err != ErrInvalidEncoding {
+		t.Errorf("got error %v decoding invalid base58, want %v", err, ErrInvalidEncoding)
+	}
+}
+
+func TestDecryptEncoded(t *testing.T) {
+	key := "ee581a043ac19191c7d551710bab13a9"
+	msg := "Hello world"
+	c := testCiphertext(t, msg)
+	for _, codec := range []Codec{Base32Codec, Base58Codec} {
+		got, err := DecryptEncoded(codec.Encode(c), codec, testOracle{key: key}, nopLogger{}, WithPayloadExtractor(PKCS7Extractor))
+		if err != nil {
+			t.Fatal(err)
+		}
+		if got != msg {
+			t.Errorf("DecryptEncoded() = %q, want %q", got, msg)
+		}
+	}
+}
+
+func TestDecryptBase64WrappedLines(t *testing.T) {
+	key := "ee581a043ac19191c7d551710bab13a9"
+	msg := "Somewhere in la Mancha, in a place whose name I do not care to remember"
+	c := testCiphertext(t, msg)
+	// Wrap the encoded ciphertext at 76 columns, as MIME does.
+	enc := base64.StdEncoding.EncodeToString(c)
+	var b strings.Builder
+	for len(enc) > 76 {
+		b.WriteString(enc[:76] + "\r\n")
+		enc = enc[76:]
+	}
+	b.WriteString(enc + "\r\n")
+	wrapped := b.String()
+	if strings.Count(wrapped, "\r\n") < 2 {
+		t.Fatalf("the ciphertext is not wrapped: %q", wrapped)
+	}
+	got, err := DecryptBase64(wrapped, testOracle{key: key}, nopLogger{}, WithPayloadExtractor(PKCS7Extractor))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if got != msg {
+		t.Errorf("DecryptBase64() = %q, want %q", got, msg)
+	}
+	if _, err := DecryptBase64(strings.Replace(wrapped, "\r\n", "\t", 1), testOracle{key: key}, nopLogger{}); err == nil {
+		t.Error("DecryptBase64() returned no error for a ciphertext containing tabs")
+	}
+}
+
+func TestNormalizeCiphertext(t *testing.T) {
+	tests := []struct {
+		name    string
+		s       string
+		want    string
+		wantErr error
+	}{
+		{"TrimsWhitespaceAndQuotes", " \"'q+/w=='\"\n", "q+/w==", nil},
+		{"JoinsWrappedLines", "q+/w\r\nAB==", "q+/wAB==", nil},
+		{"RestoresPlusFromSpaces", "q /w AB==", "q+/w+AB==", nil},
+		{"DecodesPercentEncoding", "q%2B%2Fw%3D%3D", "q+/w==", nil},
+		{"LowercasesHex", "`DEADbeef`", "deadbeef", nil},
+		{"RemovesSpacesFromHex", "de ad BE EF", "deadbeef", nil},
+		{"KeepsOddLengthHexLikeStrings", "abc", "abc", nil},
+		{"RejectsEmptyStrings", " '' ", "", ErrInvalidEncoding},
+	}
+	for _, tt := range tests {
+		tt := tt
+		t.Run(tt.name, func(t *testing.T) {
+			got, err := NormalizeCiphertext(tt.s)
+			if err != tt.wantErr {
+				t.Fatalf("NormalizeCiphertext() error = %v, want %v", err, tt.wantErr)
+			}
+			if got != tt.want {
+				t.Errorf("NormalizeCiphertext() = %q, want %q", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestDecryptEncodedNormalizesTheCiphertext(t *testing.T) {
+	key := "ee581a043ac19191c7d551710bab13a9"
+	msg := "Somewhere in la Mancha"
+	ct, err := crypto.CBCEncrypt("91db4482c4ffa9858338ab0e98ddf96c", key, msg)
+	if err != nil {
+		t.Fatal(err)
+	}
+	c, err := hex.DecodeString(ct)
+	if err != nil {
+		t.Fatal(err)
+	}
+	mangled := map[Codec]string{
+		HexCodec:    "\"" + strings.ToUpper(ct) + "\"",
+		Base64Codec: strings.Replace(base64.StdEncoding.EncodeToString(c), "+", " ", -1) + "\n",
+	}
+	for codec, s := range mangled {
+		got, err := DecryptEncoded(s, codec, testOracle{key: key}, nopLogger{}, WithPayloadExtractor(PKCS7Extractor))
+		if err != nil {
+			t.Fatal(err)
+		}
+		if got != msg {
+			t.Errorf("DecryptEncoded() = %q, want %q", got, msg)
+		}
+	}
+}
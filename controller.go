@@ -0,0 +1,95 @@
+package goracler
+
+import (
+	"context"
+	"sync"
+)
+
+// Controller pauses and resumes the attacks using it. While paused, the
+// queries to the oracle are held until the attack is resumed or the context
+// set with WithOracleContext, or the one passed to DecryptContext or
+// EncryptContext, is done, in which case they fail with the error of the
+// context. The queries already sent to the oracle when Pause is called are
+// not affected. Pausing an attack doesn't cancel it, so it continues from
+// the same point when resumed. The zero value is a running Controller and
+// it's safe for concurrent use.
+type Controller struct {
+	mu     sync.Mutex
+	paused bool
+	resume chan struct{}
+}
+
+// Pause holds the queries of the attacks using the Controller until Resume is
+// called. Calling Pause on a paused Controller has no effect.
+func (c *Controller) Pause() {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	if c.paused {
+		return
+	}
+	c.paused = true
+	c.resume = make(chan struct{})
+}
+
+// Resume releases the queries held by Pause. Calling Resume on a running
+// Controller has no effect.
+func (c *Controller) Resume() {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	if !c.paused {
+		return
+	}
+	c.paused = false
+	close(c.resume)
+}
+
+// Paused returns true if the Controller is paused.
+func (c *Controller) Paused() bool {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	return c.paused
+}
+
+// wait blocks while the Controller is paused or until ctx or attack are
+// done.
+func (c *Controller) wait(ctx, attack context.Context) error {
+	c.mu.Lock()
+	if !c.paused {
+		c.mu.Unlock()
+		return nil
+	}
+	resume := c.resume
+	c.mu.Unlock()
+	select {
+	case <-resume:
+		return nil
+	case <-ctx.Done():
+		return ctx.Err()
+	case <-attack.Done():
+		return attack.Err()
+	}
+}
+
+// WithController makes the attack use the given Controller, so it can be
+// paused and resumed while running.
+func WithController(ctrl *Controller) Option {
+	return func(c *config) {
+		c.controller = ctrl
+	}
+}
+
+// controlledOracle is a Poracle that holds the queries while its Controller
+// is paused.
+type controlledOracle struct {
+	Poracle
+	ctrl   *Controller
+	ctx    context.Context
+	attack context.Context
+}
+
+func (o controlledOracle) Do(c []byte) (int, error) {
+	if err := o.ctrl.wait(o.ctx, o.attack); err != nil {
+		return 0, err
+	}
+	return o.Poracle.Do(c)
+}
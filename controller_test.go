@@ -0,0 +1,77 @@
+package goracler
+
+import (
+	"context"
+	"sync/atomic"
+	"testing"
+	"time"
+)
+
+// pausingOracle simulates an oracle that pauses the attack after receiving a
+// given number of queries.
+type pausingOracle struct {
+	testOracle
+	ctrl    *Controller
+	after   int64
+	queries *int64
+}
+
+func (o pausingOracle) Do(c []byte) (int, error) {
+	if atomic.AddInt64(o.queries, 1) == o.after {
+		o.ctrl.Pause()
+	}
+	return o.testOracle.Do(c)
+}
+
+func TestWithController(t *testing.T) {
+	key := "ee581a043ac19191c7d551710bab13a9"
+	msg := "Somewhere in la Mancha"
+	c := testCiphertext(t, msg)
+	ctrl := &Controller{}
+	q := pausingOracle{testOracle{key: key}, ctrl, 200, new(int64)}
+	type result struct {
+		m   string
+		err error
+	}
+	done := make(chan result, 1)
+	go func() {
+		m, err := DecryptUnpadded(c, q, nopLogger{}, WithController(ctrl))
+		done <- result{m, err}
+	}()
+	for atomic.LoadInt64(q.queries) < q.after {
+		time.Sleep(time.Millisecond)
+	}
+	// Let the queries sent before the pause finish.
+	time.Sleep(20 * time.Millisecond)
+	paused := atomic.LoadInt64(q.queries)
+	time.Sleep(50 * time.Millisecond)
+	if n := atomic.LoadInt64(q.queries); n != paused {
+		t.Fatalf("got %d queries while paused", n-paused)
+	}
+	select {
+	case <-done:
+		t.Fatal("the attack finished while paused")
+	default:
+	}
+	ctrl.Resume()
+	r := <-done
+	if r.err != nil {
+		t.Fatal(r.err)
+	}
+	if r.m != msg {
+		t.Errorf("DecryptUnpadded() = %q, want %q", r.m, msg)
+	}
+}
+
+func TestControllerRespectsContext(t *testing.T) {
+	ctrl := &Controller{}
+	ctrl.Pause()
+	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Millisecond)
+	defer cancel()
+	c := make([]byte, 2*CipherBlockLen)
+	_, err := Decrypt(c, testOracle{key: "ee581a043ac19191c7d551710bab13a9"}, nopLogger{},
+		WithController(ctrl), WithOracleContext(ctx))
+	if err != context.DeadlineExceeded {
+		t.Errorf("Decrypt() error = %v, want %v", err, context.DeadlineExceeded)
+	}
+}
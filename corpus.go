@@ -0,0 +1,155 @@
+package goracler
+
+import (
+	"encoding/hex"
+	"encoding/json"
+	"sync"
+)
+
+// Corpus stores the intermediate values recovered by decrypt attacks, keyed by
+// the block of ciphertext they belong to. The intermediate value of a block is
+// the result of decrypting it with the block cipher, before xoring it with the
+// previous block, so it only depends on the block itself and the key. When
+// several ciphertexts encrypted with the same key share blocks the ones
+// already present in the corpus are decrypted without querying the oracle.
+// In CBC mode, that happens when the same ciphertext is captured several
+// times, or when the plaintexts have a common prefix and are encrypted with
+// the same IV. Note that plaintexts with a common suffix don't produce
+// common blocks of ciphertext, because each block depends on all the previous
+// ones. It's safe for concurrent use.
+//
+// A Corpus can be persisted and loaded using encoding/json. It's encoded as a
+// JSON object with the hex encoded blocks as keys and the hex encoded
+// intermediate values as values.
+type Corpus struct {
+	mu     sync.Mutex
+	blocks map[string][]byte
+	// inflight contains the blocks being attacked.
+	inflight map[string]*corpusCall
+}
+
+// corpusCall is an attack to recover the intermediate value of a block.
+type corpusCall struct {
+	done chan struct{}
+	err  error
+}
+
+// NewCorpus returns an empty Corpus.
+func NewCorpus() *Corpus {
+	return &Corpus{blocks: make(map[string][]byte)}
+}
+
+// Add stores the intermediate value of the given block of ciphertext.
+func (c *Corpus) Add(block, intermediate []byte) {
+	v := make([]byte, len(intermediate))
+	copy(v, intermediate)
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	if c.blocks == nil {
+		c.blocks = make(map[string][]byte)
+	}
+	c.blocks[string(block)] = v
+}
+
+// Intermediate returns the intermediate value of the given block of
+// ciphertext, if present in the corpus.
+func (c *Corpus) Intermediate(block []byte) ([]byte, bool) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	v, ok := c.blocks[string(block)]
+	if !ok {
+		return nil, false
+	}
+	r := make([]byte, len(v))
+	copy(r, v)
+	return r, true
+}
+
+// resolve returns the intermediate value of the given block, from the corpus
+// if present or using the attack function otherwise. The intermediate values
+// recovered by the attack are added to the corpus, unless the attack reports
+// them as incomplete. When several goroutines resolve the same block at the
+// same time, only one of them runs the attack and the rest wait for its
+// result.
+func (c *Corpus) resolve(block []byte, attack func() (d []byte, complete bool, err error)) ([]byte, error) {
+	for {
+		if d, ok := c.Intermediate(block); ok {
+			return d, nil
+		}
+		c.mu.Lock()
+		if c.inflight == nil {
+			c.inflight = make(map[string]*corpusCall)
+		}
+		call, ok := c.inflight[string(block)]
+		if ok {
+			c.mu.Unlock()
+			<-call.done
+			if call.err != nil {
+				return nil, call.err
+			}
+			continue
+		}
+		call = &corpusCall{done: make(chan struct{})}
+		c.inflight[string(block)] = call
+		c.mu.Unlock()
+
+		d, complete, err := attack()
+		if err == nil && complete {
+			c.Add(block, d)
+		}
+		c.mu.Lock()
+		delete(c.inflight, string(block))
+		c.mu.Unlock()
+		call.err = err
+		close(call.done)
+		return d, err
+	}
+}
+
+// Len returns the number of blocks in the corpus.
+func (c *Corpus) Len() int {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	return len(c.blocks)
+}
+
+// MarshalJSON implements the json.Marshaler interface.
+func (c *Corpus) MarshalJSON() ([]byte, error) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	m := make(map[string]string, len(c.blocks))
+	for b, v := range c.blocks {
+		m[hex.EncodeToString([]byte(b))] = hex.EncodeToString(v)
+	}
+	return json.Marshal(m)
+}
+
+// UnmarshalJSON implements the json.Unmarshaler interface. The blocks are
+// added to the ones already present in the corpus.
+func (c *Corpus) UnmarshalJSON(data []byte) error {
+	var m map[string]string
+	if err := json.Unmarshal(data, &m); err != nil {
+		return err
+	}
+	for hb, hv := range m {
+		b, err := hex.DecodeString(hb)
+		if err != nil {
+			return err
+		}
+		v, err := hex.DecodeString(hv)
+		if err != nil {
+			return err
+		}
+		c.Add(b, v)
+	}
+	return nil
+}
+
+// xorBlocks returns a xor b. Both blocks must have the same length.
+func xorBlocks(a, b []byte) []byte {
+	r := make([]byte, len(a))
+	for i := range a {
+		r[i] = a[i] ^ b[i]
+	}
+	return r
+}
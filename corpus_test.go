@@ -0,0 +1,71 @@
+package goracler
+
+import (
+	"encoding/json"
+	"testing"
+)
+
+func TestDecryptWithCorpus(t *testing.T) {
+	key := "ee581a043ac19191c7d551710bab13a9"
+	prefix := "Somewhere in la Mancha, in a place whose name "
+	corpus := NewCorpus()
+	first := prefix + "I do not care to remember"
+	if _, err := Decrypt(testCiphertext(t, first), testOracle{key: key}, nopLogger{}, WithCorpus(corpus)); err != nil {
+		t.Fatal(err)
+	}
+	if corpus.Len() != 5 {
+		t.Fatalf("got %d blocks in the corpus, want 5", corpus.Len())
+	}
+
+	// Persist and load the corpus.
+	data, err := json.Marshal(corpus)
+	if err != nil {
+		t.Fatal(err)
+	}
+	loaded := NewCorpus()
+	if err := json.Unmarshal(data, loaded); err != nil {
+		t.Fatal(err)
+	}
+
+	// The messages share the first two blocks of plaintext and the iv, so
+	// they share the first two blocks of ciphertext.
+	second := prefix + "I do not care to forget"
+	q := &countingOracle{Poracle: testOracle{key: key}}
+	got, err := DecryptUnpadded(testCiphertext(t, second), q, nopLogger{}, WithCorpus(loaded), WithoutAlwaysValidCheck())
+	if err != nil {
+		t.Fatal(err)
+	}
+	if got != second {
+		t.Errorf("DecryptUnpadded() = %q, want %q", got, second)
+	}
+	// Only the last two blocks are attacked, so it needs less queries than
+	// an attack without the corpus.
+	full := &countingOracle{Poracle: testOracle{key: key}}
+	if _, err := Decrypt(testCiphertext(t, second), full, nopLogger{}, WithoutAlwaysValidCheck()); err != nil {
+		t.Fatal(err)
+	}
+	if q.queries >= full.queries {
+		t.Errorf("got %d queries using the corpus, want less than %d", q.queries, full.queries)
+	}
+}
+
+func TestDecryptWithCorpusSkipsIncompleteBlocks(t *testing.T) {
+	key := "ee581a043ac19191c7d551710bab13a9"
+	msg := "Somewhere in la Mancha, in a place"
+	c := testCiphertext(t, msg)
+	const mask = 0x5a
+	q := maskedCheckOracle{testOracle{key: key}, mask}
+	corpus := NewCorpus()
+	// The blocks recovered with a Solver other than the CBCSolver don't
+	// contain the intermediate values.
+	got, err := DecryptUnpadded(c, q, nopLogger{}, WithSolver(maskSolver(mask)), WithClassifierCheck(false), WithCorpus(corpus))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if got != msg {
+		t.Errorf("DecryptUnpadded() = %q, want %q", got, msg)
+	}
+	if corpus.Len() != 0 {
+		t.Errorf("got %d blocks in the corpus, want 0", corpus.Len())
+	}
+}
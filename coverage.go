@@ -0,0 +1,49 @@
+package goracler
+
+// ByteRange is a range of bytes of the plaintext, from Start to End, not
+// included.
+type ByteRange struct {
+	Start, End int
+}
+
+// Coverage describes how complete the recovery of the plaintext is.
+type Coverage struct {
+	// RecoveredBytes is the number of bytes of plaintext recovered and
+	// TotalBytes the length of the whole plaintext, including the pad and
+	// the blocks not attacked.
+	RecoveredBytes int
+	TotalBytes     int
+
+	// Recovered contains the ranges of bytes of the plaintext recovered, in
+	// order. The rest of the bytes are unknown.
+	Recovered []ByteRange
+}
+
+// Rate returns the ratio of bytes of the plaintext recovered, from 0 to 1.
+func (c Coverage) Rate() float64 {
+	if c.TotalBytes == 0 {
+		return 0
+	}
+	return float64(c.RecoveredBytes) / float64(c.TotalBytes)
+}
+
+// newCoverage returns the Coverage for the given masks of known bytes of each
+// block of plaintext, which are nil for the blocks not recovered.
+func newCoverage(known [][]bool, blockLen int) Coverage {
+	c := Coverage{TotalBytes: len(known) * blockLen}
+	for i, mask := range known {
+		for k, ok := range mask {
+			if !ok {
+				continue
+			}
+			c.RecoveredBytes++
+			pos := i*blockLen + k
+			if n := len(c.Recovered); n > 0 && c.Recovered[n-1].End == pos {
+				c.Recovered[n-1].End++
+				continue
+			}
+			c.Recovered = append(c.Recovered, ByteRange{pos, pos + 1})
+		}
+	}
+	return c
+}
@@ -0,0 +1,102 @@
+package goracler
+
+import "bytes"
+
+// WithCrib sets a fragment of plaintext known to appear somewhere in the
+// message, at an unknown offset. Before searching the value of each byte of a
+// block, the attack computes the alignments of the crib that are consistent
+// with the bytes of the block already recovered, and tries first the
+// candidates that produce the byte of the crib at that position in those
+// alignments, the ones matching more recovered bytes first. The rest of the
+// candidates are tried afterwards, so it's a heuristic that reduces the
+// number of queries when the crib is in the block and falls back to the full
+// search when it's not. Each recovered block is also searched for the crib,
+// together with the block before it when it's recovered, and its offset is
+// logged when found. Only alignments within a block are considered, and the
+// crib is matched against the plaintext as it's decrypted, before applying
+// WithBlockByteOrder or removing the salt set with WithPerBlockSalt. It has
+// no effect with a Solver other than CBCSolver.
+func WithCrib(crib []byte) Option {
+	return func(c *config) {
+		c.crib = crib
+	}
+}
+
+// candidates returns the 256 candidate values for the byte at the position p
+// of a block in the order they must be tried, given the bytes of plaintext
+// already recovered in mi.
+func candidates(prev, mi []byte, p int, cfg *config) []byte {
+	order := make([]byte, 0, 256)
+	if len(cfg.crib) > 0 && cfg.solver == CBCSolver {
+		order = cribCandidates(order, prev, mi, p, cfg)
+	}
+	var tried [256]bool
+	for _, g := range order {
+		tried[g] = true
+	}
+	for g := 0; g < 256; g++ {
+		if !tried[g] {
+			order = append(order, byte(g))
+		}
+	}
+	return order
+}
+
+// cribCandidates appends to order the candidates for the byte at the position
+// p that produce the byte of the crib at that position, for the alignments of
+// the crib consistent with the bytes recovered after p.
+func cribCandidates(order, prev, mi []byte, p int, cfg *config) []byte {
+	crib := cfg.crib
+	// matches holds, for each plaintext value, the maximum number of
+	// recovered bytes matched by an alignment placing it at p, plus one.
+	var matches [256]int
+	best := 0
+	for s := p - len(crib) + 1; s <= p; s++ {
+		n := 0
+		consistent := true
+		for k := p + 1; k <= cfg.padEnd() && k-s < len(crib); k++ {
+			if crib[k-s] != mi[k] {
+				consistent = false
+				break
+			}
+			n++
+		}
+		if !consistent {
+			continue
+		}
+		b := crib[p-s]
+		if n+1 > matches[b] {
+			matches[b] = n + 1
+		}
+		if n+1 > best {
+			best = n + 1
+		}
+	}
+	pad := cfg.padding.PadByte(cfg.padLen(p), 0)
+	for n := best; n > 0; n-- {
+		for b := 0; b < 256; b++ {
+			if matches[b] == n {
+				order = append(order, byte(b)^prev[p]^pad)
+			}
+		}
+	}
+	return order
+}
+
+// locateCrib logs the offset of the crib in the block of plaintext at index i,
+// if found. The search includes the block before it, when given, to find
+// cribs spanning both blocks.
+func locateCrib(crib, before, block []byte, i int, l Logger) {
+	if len(crib) == 0 {
+		return
+	}
+	m := append(append([]byte{}, before...), block...)
+	if k := bytes.Index(m, crib); k >= 0 {
+		k -= len(before)
+		if k < 0 {
+			l.Printf("\ncrib found spanning blocks %d and %d", i, i+1)
+			return
+		}
+		l.Printf("\ncrib found in block %d at offset %d", i+1, k)
+	}
+}
@@ -0,0 +1,30 @@
+package goracler
+
+import (
+	"testing"
+)
+
+func TestDecryptWithCrib(t *testing.T) {
+	key := "ee581a043ac19191c7d551710bab13a9"
+	msg := "Somewhere in la Mancha, in a place whose name I do not care to remember"
+	c := testCiphertext(t, msg)
+	decrypt := func(opts ...Option) int64 {
+		q := &countingOracle{Poracle: testOracle{key: key}}
+		opts = append(opts, WithSequentialExecution())
+		got, err := DecryptUnpadded(c, q, nopLogger{}, opts...)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if got != msg {
+			t.Errorf("DecryptUnpadded() = %q, want %q", got, msg)
+		}
+		return q.queries
+	}
+	without := decrypt()
+	with := decrypt(WithCrib([]byte("a place whose name")))
+	if with >= without {
+		t.Errorf("got %d queries with the crib, want less than the %d queries without it", with, without)
+	}
+	// A crib not present in the plaintext must still recover it.
+	decrypt(WithCrib([]byte("El ingenioso hidalgo")))
+}
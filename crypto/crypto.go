@@ -3,13 +3,25 @@ package crypto
 import (
 	"bytes"
 	"crypto/aes"
+	"crypto/cipher"
 	"crypto/rand"
 	"encoding/hex"
 	"errors"
 )
 
 var (
+	// ErrInvalidPad is returned when a message doesn't end with a valid
+	// PKCS#7 pad.
 	ErrInvalidPad = errors.New("error invalid pad")
+
+	// ErrInvalidIV is returned when the length of an iv doesn't match the
+	// block size of the cipher.
+	ErrInvalidIV = errors.New("invalid iv length")
+
+	// ErrInvalidCiphertextLen is returned when the length of a ciphertext is
+	// not a multiple of the block size or it doesn't contain at least the
+	// iv and one block.
+	ErrInvalidCiphertextLen = errors.New("invalid ciphertext length")
 )
 
 // GenerateKey generates a 16 bytes key and returns its hex representation.
@@ -33,28 +45,54 @@ func CBCEncrypt(hiv, key, msg string) (string, error) {
 	if err != nil {
 		return "", err
 	}
-	// Implement the CBC mode. c[i] = e(k,c[i-1] + m[i]), c[-1] = iv.
-	// Where len(m[i]) = 16 bytes.
 	iv, err := hex.DecodeString(hiv)
 	if err != nil {
 		return "", err
 	}
+	cypertxt, err := CBCEncryptWithCipher(c, iv, []byte(msg))
+	if err != nil {
+		return "", err
+	}
+	out := hex.EncodeToString(cypertxt)
+	return out, nil
+}
+
+// CBCEncryptWithCipher encrypts the msg in CBC mode using the given block
+// cipher and iv, and returns iv||ciphertext. The msg is padded using PKCS#7
+// and the block size of the cipher.
+func CBCEncryptWithCipher(block cipher.Block, iv, msg []byte) ([]byte, error) {
+	bs := block.BlockSize()
+	if len(iv) != bs {
+		return nil, ErrInvalidIV
+	}
+	// Implement the CBC mode. c[i] = e(k,c[i-1] + m[i]), c[-1] = iv.
+	// Where len(m[i]) = block size.
 	prev := iv
-	m := PCKCS5Pad([]byte(msg))
+	m := PKCS7Pad(msg, bs)
 	// ciphertext = iv||c[0]..c[n-1].
-	var ct = bytes.NewBuffer(make([]byte, 0, len(m)+16))
+	var ct = bytes.NewBuffer(make([]byte, 0, len(m)+bs))
 	// Prepend the iv to the ciphertext.
 	ct.Write(iv)
-	for i := 0; i < (len(m) / 16); i++ {
-		b := m[i*16 : (i*16)+16]
+	for i := 0; i < (len(m) / bs); i++ {
+		b := m[i*bs : (i*bs)+bs]
 		x := BlockXOR(b, prev)
-		c.Encrypt(x, x)
+		block.Encrypt(x, x)
 		ct.Write(x)
 		prev = x
 	}
-	cypertxt := ct.Bytes()
-	out := hex.EncodeToString(cypertxt)
-	return out, nil
+	return ct.Bytes(), nil
+}
+
+// MakeValidPaddedCiphertext returns a hex encoded iv||ciphertext, using a
+// random iv, of the given payload with a valid PKCS#7 pad. It's useful to test
+// that an oracle, or the classifier of its responses, reports valid pads as
+// valid.
+func MakeValidPaddedCiphertext(key string, payload []byte) (string, error) {
+	iv, err := GenerateKey()
+	if err != nil {
+		return "", err
+	}
+	return CBCEncrypt(iv, key, string(payload))
 }
 
 // CBCDecrypt accepts a key and ciphertext in the form: iv||cypher returns a
@@ -73,20 +111,31 @@ func CBCDecrypt(key, ciphertext string) (string, error) {
 	if err != nil {
 		return "", err
 	}
-	iv := d[0:16]
-	c := d[16:len(d)]
+	ctremoved, err := CBCDecryptWithCipher(bc, d)
+	return string(ctremoved), err
+}
+
+// CBCDecryptWithCipher accepts a ciphertext in the form iv||cypher, decrypts it
+// in CBC mode using the given block cipher and returns the message without
+// the PKCS#7 pad. WARNING: This function is vulnerable to padding oracle
+// attacks and should only be used for test pourposes.
+func CBCDecryptWithCipher(block cipher.Block, ciphertext []byte) ([]byte, error) {
+	bs := block.BlockSize()
+	if len(ciphertext) < 2*bs || len(ciphertext)%bs != 0 {
+		return nil, ErrInvalidCiphertextLen
+	}
+	iv := ciphertext[0:bs]
+	c := ciphertext[bs:]
 	m := bytes.NewBuffer(make([]byte, 0, len(c)))
 	prev := iv
-	for i := 0; i < (len(c) / 16); i++ {
-		ci := c[i*16 : (16*i)+16]
-		aux := make([]byte, 16, 16)
-		bc.Decrypt(aux, ci)
+	for i := 0; i < (len(c) / bs); i++ {
+		ci := c[i*bs : (bs*i)+bs]
+		aux := make([]byte, bs, bs)
+		block.Decrypt(aux, ci)
 		m.Write(BlockXOR(aux, prev))
 		prev = ci
 	}
-	ct := m.Bytes()
-	ctremoved, err := DecryptRemovePCKCS5Pad(ct)
-	return string(ctremoved), err
+	return RemovePKCS7Pad(m.Bytes(), bs)
 }
 
 // BlockXOR xors a block with a given "key". The key length must be grater or
@@ -101,9 +150,14 @@ func BlockXOR(block, key []byte) []byte {
 
 // PCKCS5Pad  pads the given array to size 16.
 func PCKCS5Pad(m []byte) []byte {
+	return PKCS7Pad(m, 16)
+}
+
+// PKCS7Pad pads the given array to a multiple of the given block size.
+func PKCS7Pad(m []byte, blockSize int) []byte {
 	var b bytes.Buffer
 	b.Write(m)
-	r := 16 - len(m)%16
+	r := blockSize - len(m)%blockSize
 	for i := 0; i < r; i++ {
 		b.WriteByte(byte(r))
 	}
@@ -112,8 +166,17 @@ func PCKCS5Pad(m []byte) []byte {
 
 // DecryptRemovePCKCS5Pad remove the 16 size pad from given array.
 func DecryptRemovePCKCS5Pad(m []byte) ([]byte, error) {
+	return RemovePKCS7Pad(m, 16)
+}
+
+// RemovePKCS7Pad removes the pad, for the given block size, from the given
+// array.
+func RemovePKCS7Pad(m []byte, blockSize int) ([]byte, error) {
+	if len(m) == 0 {
+		return nil, ErrInvalidPad
+	}
 	p := int(m[len(m)-1])
-	if p > 16 || p < 1 {
+	if p > blockSize || p < 1 || p > len(m) {
 		return nil, ErrInvalidPad
 	}
 	// Check the pad
@@ -128,20 +191,11 @@ func DecryptRemovePCKCS5Pad(m []byte) ([]byte, error) {
 	return m, nil
 }
 
+// RemovePCKCS5Pad removes the 16 size pad from the given string.
 func RemovePCKCS5Pad(s string) (string, error) {
-	m := []byte(s)
-	p := int(m[len(m)-1])
-	if p > 16 || p < 1 {
-		return "", ErrInvalidPad
-	}
-	// Check the pad
-	j := p
-	for j > 0 {
-		if m[len(m)-1] != byte(p) {
-			return "", ErrInvalidPad
-		}
-		m = m[0 : len(m)-1]
-		j--
+	m, err := RemovePKCS7Pad([]byte(s), 16)
+	if err != nil {
+		return "", err
 	}
 	return string(m), nil
 }
@@ -0,0 +1,162 @@
+package crypto
+
+import (
+	"crypto/des"
+	"encoding/hex"
+	"testing"
+)
+
+func TestMakeValidPaddedCiphertext(t *testing.T) {
+	key := "ee581a043ac19191c7d551710bab13a9"
+	tests := []struct {
+		name    string
+		payload []byte
+	}{
+		{
+			name:    "PartialBlock",
+			payload: []byte("Hello world"),
+		},
+		{
+			name:    "FullBlock",
+			payload: []byte("Somewhere in la "),
+		},
+		{
+			name:    "Empty",
+			payload: nil,
+		},
+	}
+	for _, tt := range tests {
+		tt := tt
+		t.Run(tt.name, func(t *testing.T) {
+			ct, err := MakeValidPaddedCiphertext(key, tt.payload)
+			if err != nil {
+				t.Fatal(err)
+			}
+			c, err := hex.DecodeString(ct)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if len(c) < 32 || len(c)%16 != 0 {
+				t.Fatalf("invalid ciphertext length %d", len(c))
+			}
+			got, err := CBCDecrypt(key, ct)
+			if err != nil {
+				t.Fatalf("CBCDecrypt() error = %v", err)
+			}
+			if got != string(tt.payload) {
+				t.Errorf("CBCDecrypt() = %q, want %q", got, tt.payload)
+			}
+		})
+	}
+}
+
+func TestCBCWithCipherDES(t *testing.T) {
+	block, err := des.NewCipher([]byte("8bytekey"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	iv := []byte("initvect")
+	msgs := []string{"", "short", "exactly8", "a message longer than a few blocks"}
+	for _, msg := range msgs {
+		ct, err := CBCEncryptWithCipher(block, iv, []byte(msg))
+		if err != nil {
+			t.Fatal(err)
+		}
+		if len(ct)%block.BlockSize() != 0 || len(ct) <= len(msg)+len(iv) {
+			t.Errorf("invalid ciphertext length %d for message %q", len(ct), msg)
+		}
+		got, err := CBCDecryptWithCipher(block, ct)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if string(got) != msg {
+			t.Errorf("CBCDecryptWithCipher() = %q, want %q", got, msg)
+		}
+	}
+	if _, err := CBCEncryptWithCipher(block, make([]byte, 16), nil); err != ErrInvalidIV {
+		t.Errorf("CBCEncryptWithCipher() error = %v, want %v", err, ErrInvalidIV)
+	}
+}
+
+// The CBC-AES128 vectors from NIST SP 800-38A, appendix F.2.1 and F.2.2.
+const (
+	nistKey        = "2b7e151628aed2a6abf7158809cf4f3c"
+	nistIV         = "000102030405060708090a0b0c0d0e0f"
+	nistPlaintext  = "6bc1bee22e409f96e93d7e117393172aae2d8a571e03ac9c9eb76fac45af8e5130c81c46a35ce411e5fbc1191a0a52eff69f2445df4f9b17ad2b417be66c3710"
+	nistCiphertext = "7649abac8119b246cee98e9b12e9197d5086cb9b507219ee95db113a917678b273bed6b8e3c1743b7116e69e222295163ff1caa1681fac09120eca307586e1a7"
+)
+
+func TestCBCNISTVectors(t *testing.T) {
+	m, err := hex.DecodeString(nistPlaintext)
+	if err != nil {
+		t.Fatal(err)
+	}
+	ct, err := CBCEncrypt(nistIV, nistKey, string(m))
+	if err != nil {
+		t.Fatal(err)
+	}
+	// The plaintext is aligned to the block size, so the ciphertext is
+	// iv||vector||a full block of pad.
+	want := nistIV + nistCiphertext
+	if len(ct) != len(want)+32 || ct[:len(want)] != want {
+		t.Fatalf("CBCEncrypt() = %s, want it to start with %s followed by one block", ct, want)
+	}
+	got, err := CBCDecrypt(nistKey, ct)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if got != string(m) {
+		t.Errorf("CBCDecrypt() = %x, want %s", got, nistPlaintext)
+	}
+}
+
+// TestPKCS7 checks the pad defined in RFC 5652, section 6.3: the input is
+// padded with k-(l mod k) octets all having value k-(l mod k), where k is the
+// block size and l the length of the input.
+func TestPKCS7(t *testing.T) {
+	tests := []struct {
+		name   string
+		m      string
+		padded string
+	}{
+		{"Empty", "", "\x08\x08\x08\x08\x08\x08\x08\x08"},
+		{"OneByte", "a", "a\x07\x07\x07\x07\x07\x07\x07"},
+		{"OneByteLeft", "abcdefg", "abcdefg\x01"},
+		{"FullBlock", "abcdefgh", "abcdefgh\x08\x08\x08\x08\x08\x08\x08\x08"},
+		{"MoreThanABlock", "abcdefghi", "abcdefghi\x07\x07\x07\x07\x07\x07\x07"},
+	}
+	for _, tt := range tests {
+		tt := tt
+		t.Run(tt.name, func(t *testing.T) {
+			got := PKCS7Pad([]byte(tt.m), 8)
+			if string(got) != tt.padded {
+				t.Fatalf("PKCS7Pad() = %q, want %q", got, tt.padded)
+			}
+			m, err := RemovePKCS7Pad(got, 8)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if string(m) != tt.m {
+				t.Errorf("RemovePKCS7Pad() = %q, want %q", m, tt.m)
+			}
+		})
+	}
+}
+
+func TestRemovePadInvalid(t *testing.T) {
+	invalid := []string{
+		"",
+		"\x05",
+		"abcdefghijklmno\x00",
+		"abcdefghijklmno\x11",
+		"abcdefghijklm\x03\x02\x03",
+	}
+	for _, m := range invalid {
+		if _, err := RemovePKCS7Pad([]byte(m), 16); err != ErrInvalidPad {
+			t.Errorf("RemovePKCS7Pad(%q) error = %v, want %v", m, err, ErrInvalidPad)
+		}
+		if _, err := RemovePCKCS5Pad(m); err != ErrInvalidPad {
+			t.Errorf("RemovePCKCS5Pad(%q) error = %v, want %v", m, err, ErrInvalidPad)
+		}
+	}
+}
@@ -0,0 +1,89 @@
+package goracler
+
+import "sync/atomic"
+
+// ErrorAsInvalid returns a Poracle that queries q and, when q returns an error
+// for which match returns true, reports the pad as invalid instead of
+// returning the error. It's useful for oracles that signal an invalid pad by
+// failing, for instance, by resetting the connection.
+func ErrorAsInvalid(q Poracle, match func(error) bool) Poracle {
+	return errorAsInvalidOracle{q, match}
+}
+
+type errorAsInvalidOracle struct {
+	Poracle
+	match func(error) bool
+}
+
+func (o errorAsInvalidOracle) Do(c []byte) (int, error) {
+	res, err := o.Poracle.Do(c)
+	if err != nil && o.match(err) {
+		return 0, nil
+	}
+	return res, err
+}
+
+// transformOracle is a Poracle that transforms the ciphertexts before sending
+// them to the wrapped oracle.
+type transformOracle struct {
+	Poracle
+	transform func([]byte) []byte
+}
+
+func (o transformOracle) Do(c []byte) (int, error) {
+	return o.Poracle.Do(o.transform(c))
+}
+
+// headerOracle is a Poracle that prefixes the ciphertexts with a header
+// before sending them to the wrapped oracle.
+type headerOracle struct {
+	Poracle
+	header []byte
+}
+
+func (o headerOracle) Do(c []byte) (int, error) {
+	m := make([]byte, 0, len(o.header)+len(c))
+	m = append(m, o.header...)
+	return o.Poracle.Do(append(m, c...))
+}
+
+// WithDecoyTraffic makes the attack call the decoy function, that must send a
+// request unrelated to the attack, the given number of times per query sent
+// to the oracle, so the traffic of the attack is interleaved with benign
+// requests. It's meant to evaluate whether the detection capabilities of a
+// target spot the attack among the usual traffic. For instance, a ratio of
+// 0.5 sends a decoy every two queries, and a ratio of 2 sends two decoys per
+// query. The decoys are sent by the same goroutines sending the queries,
+// right after them, so they count toward the limits on the concurrent
+// requests, like the one set by WithPerHostConcurrency, and any rate limit
+// applied by the oracle, but they don't count toward the query budget. The
+// errors returned by the decoy function are ignored, so the decoys don't
+// affect the results of the attack. Ratios lower or equal than 0 disable the
+// decoys.
+func WithDecoyTraffic(ratio float64, decoy func() error) Option {
+	return func(c *config) {
+		if ratio > 0 && decoy != nil {
+			c.decoy = &decoyOracle{ratio: ratio, decoy: decoy, queries: new(int64)}
+		}
+	}
+}
+
+// decoyOracle is a Poracle that calls the decoy function after querying the
+// wrapped oracle, as many times as needed to keep the given ratio of decoys
+// per query.
+type decoyOracle struct {
+	Poracle
+	ratio   float64
+	decoy   func() error
+	queries *int64
+}
+
+func (o decoyOracle) Do(c []byte) (int, error) {
+	res, err := o.Poracle.Do(c)
+	n := atomic.AddInt64(o.queries, 1)
+	due := int64(float64(n)*o.ratio) - int64(float64(n-1)*o.ratio)
+	for i := int64(0); i < due; i++ {
+		_ = o.decoy()
+	}
+	return res, err
+}
@@ -0,0 +1,121 @@
+package goracler
+
+import (
+	"context"
+	"encoding/hex"
+	"errors"
+	"sync/atomic"
+	"testing"
+
+	"github.com/manelmontilla/goracler/crypto"
+)
+
+func xorMask(c []byte) []byte {
+	t := make([]byte, len(c))
+	for i := range c {
+		t[i] = c[i] ^ byte(0x5a+i)
+	}
+	return t
+}
+
+// maskOracle simulates a target that xors the ciphertexts it receives with a
+// position dependent mask before decrypting them.
+type maskOracle struct {
+	testOracle
+}
+
+func (o maskOracle) Do(c []byte) (int, error) {
+	return o.testOracle.Do(xorMask(c))
+}
+
+func TestCiphertextTransform(t *testing.T) {
+	key := "ee581a043ac19191c7d551710bab13a9"
+	msg := "Somewhere in la Mancha"
+	c := testCiphertext(t, msg)
+	// The ciphertext as sent to the target.
+	wire := xorMask(c)
+	q := maskOracle{testOracle{key: key}}
+	opt := WithCiphertextTransform(xorMask, xorMask)
+	got, err := DecryptUnpadded(wire, q, nopLogger{}, opt)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if got != msg {
+		t.Errorf("DecryptUnpadded() = %q, want %q", got, msg)
+	}
+
+	forged, err := Encrypt([]byte("forged"), q, nopLogger{}, opt)
+	if err != nil {
+		t.Fatal(err)
+	}
+	m, err := crypto.CBCDecrypt(key, hex.EncodeToString(xorMask(forged)))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if m != "forged" {
+		t.Errorf("forged ciphertext decrypts to %q, want %q", m, "forged")
+	}
+}
+
+type sessionKey struct{}
+
+// sessionOracle is a ContextPoracle that only accepts queries made with a
+// valid session in the context.
+type sessionOracle struct {
+	testOracle
+	session string
+}
+
+func (o sessionOracle) DoCtx(ctx context.Context, c []byte) (int, error) {
+	if s, _ := ctx.Value(sessionKey{}).(string); s != o.session {
+		return 0, errors.New("invalid session")
+	}
+	return o.testOracle.Do(c)
+}
+
+func TestWithOracleContext(t *testing.T) {
+	key := "ee581a043ac19191c7d551710bab13a9"
+	msg := "Hello world"
+	c := testCiphertext(t, msg)
+	q := sessionOracle{testOracle{key: key}, "s3cr3t"}
+	ctx := context.WithValue(context.Background(), sessionKey{}, "s3cr3t")
+	got, err := DecryptUnpadded(c, q, nopLogger{}, WithOracleContext(ctx))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if got != msg {
+		t.Errorf("DecryptUnpadded() = %q, want %q", got, msg)
+	}
+	if _, err := DecryptUnpadded(c, q, nopLogger{}); err == nil {
+		t.Errorf("got no error without a session in the context")
+	}
+}
+
+func TestWithDecoyTraffic(t *testing.T) {
+	key := "ee581a043ac19191c7d551710bab13a9"
+	msg := "Somewhere in la Mancha"
+	c := testCiphertext(t, msg)
+	for _, ratio := range []float64{0.25, 2} {
+		var decoys int64
+		decoy := func() error {
+			atomic.AddInt64(&decoys, 1)
+			return errors.New("decoy errors are ignored")
+		}
+		q := &countingOracle{Poracle: testOracle{key: key}}
+		budget := NewQueryBudget(100000)
+		got, err := DecryptUnpadded(c, q, nopLogger{}, WithDecoyTraffic(ratio, decoy), WithQueryBudget(budget))
+		if err != nil {
+			t.Fatal(err)
+		}
+		if got != msg {
+			t.Errorf("DecryptUnpadded() = %q, want %q", got, msg)
+		}
+		want := int64(float64(q.queries) * ratio)
+		if decoys != want {
+			t.Errorf("got %d decoys for %d queries with ratio %v, want %d", decoys, q.queries, ratio, want)
+		}
+		if used := 100000 - budget.Remaining(); used != q.queries {
+			t.Errorf("got %d queries charged to the budget, want %d", used, q.queries)
+		}
+	}
+}
@@ -0,0 +1,68 @@
+package goracler
+
+import "sync"
+
+// EncryptAll forges, concurrently, a ciphertext for each one of the given
+// messages in the same way Encrypt does. The results are returned
+// positionally: the ciphertext and the error of the message at index i are at
+// the index i of the returned slices.
+//
+// The attacks share the oracle, the concurrency and the query budget: at
+// most the number of queries set by WithConcurrency are sent to the oracle at
+// the same time by all the attacks together, and the budget set by
+// WithMaxQueries, or WithQueryBudget, limits the queries of all of them. The
+// attacks also share a Corpus, the one set by WithCorpus or a new one, with
+// the intermediate values recovered. As Encrypt forges the ciphertexts
+// backwards from the same last block, the intermediate value of that block is
+// recovered only once, and the messages ending with the same blocks of
+// plaintext share the blocks forged for them, and their intermediate values.
+// So, forging k messages of n blocks ending with the same m blocks, with
+// m < n, needs the queries to attack k*(n-m-1)+m+1 blocks instead of the k*n
+// blocks attacked by separate calls to Encrypt.
+func EncryptAll(msgs []string, q Poracle, l Logger, opts ...Option) ([][]byte, []error) {
+	cfg := newConfig(opts)
+	var shared []Option
+	if cfg.corpus == nil {
+		shared = append(shared, WithCorpus(NewCorpus()))
+	}
+	if cfg.budget != nil {
+		shared = append(shared, WithQueryBudget(cfg.budget))
+	}
+	shared = append(shared, withSharedConcurrency(make(chan struct{}, cfg.concurrency)))
+	opts = append(append([]Option(nil), opts...), shared...)
+
+	cts := make([][]byte, len(msgs))
+	errs := make([]error, len(msgs))
+	var wg sync.WaitGroup
+	for i, msg := range msgs {
+		wg.Add(1)
+		go func(i int, msg string) {
+			defer wg.Done()
+			cts[i], errs[i] = Encrypt([]byte(msg), q, l, opts...)
+		}(i, msg)
+	}
+	wg.Wait()
+	return cts, errs
+}
+
+// withSharedConcurrency makes the attack hold a slot of the given semaphore
+// while each query is sent, so the attacks sharing it never send more queries
+// at the same time than its capacity.
+func withSharedConcurrency(sem chan struct{}) Option {
+	return func(c *config) {
+		c.sharedSem = sem
+	}
+}
+
+// limitOracle is a Poracle that limits the number of concurrent queries to the
+// wrapped oracle to the capacity of the sem channel.
+type limitOracle struct {
+	Poracle
+	sem chan struct{}
+}
+
+func (o limitOracle) Do(c []byte) (int, error) {
+	o.sem <- struct{}{}
+	defer func() { <-o.sem }()
+	return o.Poracle.Do(c)
+}
@@ -0,0 +1,61 @@
+package goracler
+
+import (
+	"encoding/hex"
+	"testing"
+
+	"github.com/manelmontilla/goracler/crypto"
+)
+
+func TestEncryptAll(t *testing.T) {
+	key := "ee581a043ac19191c7d551710bab13a9"
+	suffix := ";city=la Mancha;country=Spain;"
+	msgs := []string{
+		"name=Alonso Quij" + suffix,
+		"name=Sancho Panz" + suffix,
+		"name=Dulcinea Tb" + suffix,
+	}
+	q := &countingOracle{Poracle: testOracle{key: key}}
+	cts, errs := EncryptAll(msgs, q, nopLogger{}, WithoutAlwaysValidCheck())
+	for i, msg := range msgs {
+		if errs[i] != nil {
+			t.Fatalf("got error %v forging message %d", errs[i], i)
+		}
+		got, err := crypto.CBCDecrypt(key, hex.EncodeToString(cts[i]))
+		if err != nil {
+			t.Fatal(err)
+		}
+		if got != msg {
+			t.Errorf("got forged plaintext %q, want %q", got, msg)
+		}
+	}
+
+	separate := &countingOracle{Poracle: testOracle{key: key}}
+	for _, msg := range msgs {
+		if _, err := Encrypt([]byte(msg), separate, nopLogger{}, WithoutAlwaysValidCheck()); err != nil {
+			t.Fatal(err)
+		}
+	}
+	// The messages have 3 blocks, ending with the same 2 blocks, so
+	// EncryptAll attacks 3 blocks instead of 9.
+	if q.queries >= separate.queries/2 {
+		t.Errorf("got %d queries, want less than half of the %d queries of separate attacks", q.queries, separate.queries)
+	}
+}
+
+func TestEncryptAllDoesntModifyTheOptions(t *testing.T) {
+	key := "ee581a043ac19191c7d551710bab13a9"
+	// The spare capacity of the slice must not be used to append the
+	// shared options.
+	opts := make([]Option, 1, 4)
+	opts[0] = WithoutAlwaysValidCheck()
+	_, errs := EncryptAll([]string{"Hello world"}, testOracle{key: key}, nopLogger{}, opts...)
+	if errs[0] != nil {
+		t.Fatal(errs[0])
+	}
+	for i, opt := range opts[1:cap(opts)] {
+		if opt != nil {
+			t.Errorf("got option appended at index %d of the caller's slice", i+1)
+		}
+	}
+}
@@ -0,0 +1,60 @@
+package goracler
+
+import (
+	"errors"
+	"math"
+)
+
+// ErrRandomPlaintext is returned by Decrypt when the entropy guard, set with
+// the WithEntropyGuard option, detects that the recovered plaintext looks
+// random.
+var ErrRandomPlaintext = errors.New("the recovered plaintext looks random")
+
+// WithEntropyGuard makes Decrypt abort the attack, returning
+// ErrRandomPlaintext, when the Shannon entropy, in bits per byte, of the
+// plaintext recovered so far is greater than maxEntropy, once at least
+// afterBytes bytes have been recovered. The check is performed each time a
+// block is recovered. A recovered plaintext that looks random usually means
+// that the oracle, or its classifier, doesn't report the pads correctly, so
+// the guard saves the queries of the rest of the attack. The maximum entropy
+// of n bytes is log2(n), and 8 for n >= 256, while text usually has an
+// entropy between 4 and 5. The guard produces false positives for plaintexts
+// that are random, compressed or encrypted, so it must not be used for them.
+func WithEntropyGuard(maxEntropy float64, afterBytes int) Option {
+	return func(c *config) {
+		c.maxEntropy = maxEntropy
+		c.entropyAfter = afterBytes
+	}
+}
+
+// byteFrequencies counts the occurrences of each byte value in a message
+// built incrementally.
+type byteFrequencies struct {
+	freq [256]int
+	n    int
+}
+
+// add adds the bytes of m to the counts.
+func (f *byteFrequencies) add(m []byte) {
+	for _, b := range m {
+		f.freq[b]++
+	}
+	f.n += len(m)
+}
+
+// entropy returns the Shannon entropy, in bits per byte, of the bytes added.
+func (f *byteFrequencies) entropy() float64 {
+	if f.n == 0 {
+		return 0
+	}
+	var h float64
+	n := float64(f.n)
+	for _, c := range f.freq {
+		if c == 0 {
+			continue
+		}
+		p := float64(c) / n
+		h -= p * math.Log2(p)
+	}
+	return h
+}
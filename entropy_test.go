@@ -0,0 +1,39 @@
+package goracler
+
+import (
+	"crypto/rand"
+	"testing"
+)
+
+func TestDecryptWithEntropyGuard(t *testing.T) {
+	key := "ee581a043ac19191c7d551710bab13a9"
+	random := make([]byte, 80)
+	if _, err := rand.Read(random); err != nil {
+		t.Fatal(err)
+	}
+	tests := []struct {
+		name    string
+		msg     string
+		wantErr error
+	}{
+		{
+			name: "AcceptsText",
+			msg:  "Somewhere in la Mancha, in a place whose name I do not care to remember",
+		},
+		{
+			name:    "AbortsOnRandomPlaintext",
+			msg:     string(random),
+			wantErr: ErrRandomPlaintext,
+		},
+	}
+	for _, tt := range tests {
+		tt := tt
+		t.Run(tt.name, func(t *testing.T) {
+			c := testCiphertext(t, tt.msg)
+			_, err := Decrypt(c, testOracle{key: key}, nopLogger{}, WithEntropyGuard(5, 64))
+			if err != tt.wantErr {
+				t.Errorf("Decrypt() error = %v, want %v", err, tt.wantErr)
+			}
+		})
+	}
+}
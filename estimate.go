@@ -0,0 +1,30 @@
+package goracler
+
+import "time"
+
+// avgCandidates is the average number of values tried to find the one
+// producing a valid pad for a byte.
+const avgCandidates = 128
+
+// EstimateDuration returns an estimation of the wall-clock time needed to
+// decrypt the ciphertext c with the given block size, concurrency and average
+// latency of the oracle. The model used assumes that:
+//   - An average of 128 values are tried for each byte.
+//   - The bytes of a block are decrypted sequentially, as each one depends on
+//     the previous ones.
+//   - The values for a byte are tried in parallel by concurrency workers.
+//   - The blocks are decrypted one after another, as Decrypt does.
+//
+// It returns 0 if the ciphertext doesn't contain at least two blocks or the
+// parameters are not positive.
+func EstimateDuration(c []byte, blockSize int, avgLatency time.Duration, concurrency int) time.Duration {
+	if blockSize <= 0 || concurrency <= 0 || avgLatency <= 0 {
+		return 0
+	}
+	blocks := len(c)/blockSize - 1
+	if blocks < 1 {
+		return 0
+	}
+	rounds := (avgCandidates + concurrency - 1) / concurrency
+	return time.Duration(blocks*blockSize*rounds) * avgLatency
+}
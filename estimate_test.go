@@ -0,0 +1,59 @@
+package goracler
+
+import (
+	"testing"
+	"time"
+)
+
+func TestEstimateDuration(t *testing.T) {
+	tests := []struct {
+		name        string
+		c           []byte
+		blockSize   int
+		latency     time.Duration
+		concurrency int
+		want        time.Duration
+	}{
+		{
+			name:        "SequentialQueries",
+			c:           make([]byte, 48),
+			blockSize:   16,
+			latency:     time.Millisecond,
+			concurrency: 1,
+			want:        2 * 16 * 128 * time.Millisecond,
+		},
+		{
+			name:        "ConcurrentQueries",
+			c:           make([]byte, 48),
+			blockSize:   16,
+			latency:     time.Millisecond,
+			concurrency: 20,
+			want:        2 * 16 * 7 * time.Millisecond,
+		},
+		{
+			name:        "ConcurrencyAboveCandidates",
+			c:           make([]byte, 24),
+			blockSize:   8,
+			latency:     time.Second,
+			concurrency: 256,
+			want:        2 * 8 * time.Second,
+		},
+		{
+			name:        "SingleBlock",
+			c:           make([]byte, 16),
+			blockSize:   16,
+			latency:     time.Second,
+			concurrency: 1,
+			want:        0,
+		},
+	}
+	for _, tt := range tests {
+		tt := tt
+		t.Run(tt.name, func(t *testing.T) {
+			got := EstimateDuration(tt.c, tt.blockSize, tt.latency, tt.concurrency)
+			if got != tt.want {
+				t.Errorf("EstimateDuration() = %s, want %s", got, tt.want)
+			}
+		})
+	}
+}
@@ -0,0 +1,86 @@
+package goracler
+
+import (
+	"encoding/binary"
+	"errors"
+	"fmt"
+
+	"github.com/manelmontilla/goracler/crypto"
+)
+
+// ErrInvalidPayloadLength is returned by the extractors that read a length
+// field when the length does not fit in the recovered plaintext.
+var ErrInvalidPayloadLength = errors.New("invalid payload length")
+
+// PayloadExtractor extracts the payload from the plaintext recovered by an
+// attack. It's applied at the end of the Decrypt function when it's set using
+// the WithPayloadExtractor option.
+//
+// The most common framings of the plaintext are:
+//   - data || pad: handled by the PKCS7Extractor.
+//   - length || data || pad: handled by the extractor returned by
+//     LengthPrefixedExtractor.
+//
+// Other framings can be supported by writing a custom PayloadExtractor.
+type PayloadExtractor func(decrypted []byte) ([]byte, error)
+
+// PKCS7Extractor removes the PKCS#7 pad, for blocks of the length defined in
+// the var CipherBlockLen, from the decrypted plaintext. It returns
+// ErrInvalidRecoveredPad if the pad is not valid. The extractor returned by
+// NewPKCS7Extractor must be used for other block lengths.
+func PKCS7Extractor(decrypted []byte) ([]byte, error) {
+	return NewPKCS7Extractor(CipherBlockLen)(decrypted)
+}
+
+// NewPKCS7Extractor returns a PayloadExtractor that removes the PKCS#7 pad,
+// for blocks of the given length, from the decrypted plaintext. It returns
+// ErrInvalidRecoveredPad if the pad is not valid.
+func NewPKCS7Extractor(blockLen int) PayloadExtractor {
+	return func(decrypted []byte) ([]byte, error) {
+		if len(decrypted) == 0 {
+			return nil, ErrInvalidRecoveredPad
+		}
+		m, err := crypto.RemovePKCS7Pad(decrypted, blockLen)
+		if err != nil {
+			return nil, ErrInvalidRecoveredPad
+		}
+		return m, nil
+	}
+}
+
+// LengthPrefixedExtractor returns a PayloadExtractor for plaintexts with the
+// shape: length || data || pad. The length field has a size of n bytes, that
+// must be 1, 2, 4 or 8, and it's read using the given byte order. The returned
+// extractor first removes the PKCS#7 pad and then returns the number of bytes
+// of data specified in the length field.
+func LengthPrefixedExtractor(n int, order binary.ByteOrder) (PayloadExtractor, error) {
+	if n != 1 && n != 2 && n != 4 && n != 8 {
+		return nil, fmt.Errorf("invalid length field size %d", n)
+	}
+	e := func(decrypted []byte) ([]byte, error) {
+		m, err := PKCS7Extractor(decrypted)
+		if err != nil {
+			return nil, err
+		}
+		if len(m) < n {
+			return nil, ErrInvalidPayloadLength
+		}
+		var size uint64
+		switch n {
+		case 1:
+			size = uint64(m[0])
+		case 2:
+			size = uint64(order.Uint16(m))
+		case 4:
+			size = uint64(order.Uint32(m))
+		case 8:
+			size = order.Uint64(m)
+		}
+		data := m[n:]
+		if size > uint64(len(data)) {
+			return nil, ErrInvalidPayloadLength
+		}
+		return data[:size], nil
+	}
+	return e, nil
+}
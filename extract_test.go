@@ -0,0 +1,104 @@
+package goracler
+
+import (
+	"bytes"
+	"encoding/binary"
+	"io/ioutil"
+	"log"
+	"testing"
+
+	"github.com/manelmontilla/goracler/crypto"
+)
+
+func TestLengthPrefixedExtractor(t *testing.T) {
+	tests := []struct {
+		name    string
+		n       int
+		order   binary.ByteOrder
+		in      []byte
+		want    []byte
+		wantErr bool
+	}{
+		{
+			name:  "ExtractsPayloadWithOneByteLength",
+			n:     1,
+			order: binary.BigEndian,
+			in:    crypto.PCKCS5Pad([]byte{3, 'a', 'b', 'c', 'x'}),
+			want:  []byte("abc"),
+		},
+		{
+			name:  "ExtractsPayloadWithTwoBytesLittleEndianLength",
+			n:     2,
+			order: binary.LittleEndian,
+			in:    crypto.PCKCS5Pad([]byte{2, 0, 'a', 'b', 'c'}),
+			want:  []byte("ab"),
+		},
+		{
+			name:    "ReturnsErrorWhenLengthIsTooBig",
+			n:       4,
+			order:   binary.BigEndian,
+			in:      crypto.PCKCS5Pad([]byte{0, 0, 0, 9, 'a'}),
+			wantErr: true,
+		},
+		{
+			name:    "ReturnsErrorWhenPadIsInvalid",
+			n:       1,
+			order:   binary.BigEndian,
+			in:      []byte{1, 'a', 0},
+			wantErr: true,
+		},
+	}
+	for _, tt := range tests {
+		tt := tt
+		t.Run(tt.name, func(t *testing.T) {
+			e, err := LengthPrefixedExtractor(tt.n, tt.order)
+			if err != nil {
+				t.Fatal(err)
+			}
+			got, err := e(tt.in)
+			if (err != nil) != tt.wantErr {
+				t.Errorf("extractor error = %v, wantErr %v", err, tt.wantErr)
+				return
+			}
+			if !bytes.Equal(got, tt.want) {
+				t.Errorf("extractor = %q, want %q", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestNewPKCS7Extractor(t *testing.T) {
+	m := append([]byte("Hello w"), bytes.Repeat([]byte{9}, 9)...)
+	if _, err := PKCS7Extractor(m); err != nil {
+		t.Errorf("PKCS7Extractor() error = %v", err)
+	}
+	if _, err := NewPKCS7Extractor(8)(m); err != ErrInvalidRecoveredPad {
+		t.Errorf("NewPKCS7Extractor(8)() error = %v, want %v", err, ErrInvalidRecoveredPad)
+	}
+	got, err := NewPKCS7Extractor(8)(append([]byte("Hello w"), 1))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if string(got) != "Hello w" {
+		t.Errorf("NewPKCS7Extractor(8)() = %q, want %q", got, "Hello w")
+	}
+}
+
+func TestDecryptWithPayloadExtractor(t *testing.T) {
+	key := "ee581a043ac19191c7d551710bab13a9"
+	msg := "\x05hellotrailing data"
+	c := testCiphertext(t, msg)
+	e, err := LengthPrefixedExtractor(1, binary.BigEndian)
+	if err != nil {
+		t.Fatal(err)
+	}
+	var l log.Logger
+	l.SetOutput(ioutil.Discard)
+	got, err := Decrypt(c, testOracle{key: key}, &l, WithPayloadExtractor(e))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if got != "hello" {
+		t.Errorf("Decrypt() = %q, want %q", got, "hello")
+	}
+}
@@ -0,0 +1,140 @@
+package goracler
+
+import "sync"
+
+var (
+	// SequentialFallbackWindow is the number of most recent queries used by
+	// WithSequentialFallback to compute the error rate of the oracle.
+	SequentialFallbackWindow = 32
+
+	// SequentialRecoveryQueries is the number of consecutive successful
+	// queries an attack using WithSequentialFallback must send in
+	// sequential mode before querying the oracle concurrently again. It's
+	// doubled each time the attack falls back to sequential mode again.
+	// Zero makes the fallback permanent.
+	SequentialRecoveryQueries = 256
+)
+
+// WithSequentialFallback makes the attack stop querying the oracle
+// concurrently when it returns errors at a rate higher than the given one, a
+// value between 0 and 1, for oracles that fail under parallel load. The rate
+// is computed over the last SequentialFallbackWindow queries, and only once
+// that many queries have been sent. A rate of 0 or lower disables the
+// fallback.
+//
+// When the rate is exceeded the attack falls back to sequential mode: each
+// query waits for the ones in flight to finish and is sent alone, as if the
+// concurrency was 1. After SequentialRecoveryQueries consecutive successful
+// queries the attack queries the oracle concurrently again and, if the errors
+// return, falls back again, waiting twice as many queries before the next
+// recovery. While querying concurrently, a query returning an error is
+// repeated once in sequential mode, so the attack only fails when the oracle
+// also fails with no other query in flight. The repetitions don't count
+// toward the query budget.
+func WithSequentialFallback(errorRateThreshold float64) Option {
+	return func(c *config) {
+		c.fallbackThreshold = errorRateThreshold
+	}
+}
+
+// fallbackOracle is a Poracle that serializes the queries to the wrapped
+// oracle when its error rate exceeds a threshold.
+type fallbackOracle struct {
+	Poracle
+	// serial is held for reading by the concurrent queries and for writing
+	// by the sequential ones.
+	serial sync.RWMutex
+
+	mu         sync.Mutex
+	threshold  float64
+	window     []bool
+	next       int
+	filled     int
+	errors     int
+	sequential bool
+	succeeded  int
+	recovery   int
+}
+
+func newFallbackOracle(q Poracle, threshold float64) *fallbackOracle {
+	return &fallbackOracle{
+		Poracle:   q,
+		threshold: threshold,
+		window:    make([]bool, SequentialFallbackWindow),
+		recovery:  SequentialRecoveryQueries,
+	}
+}
+
+func (o *fallbackOracle) Do(c []byte) (int, error) {
+	if o.isSequential() {
+		return o.doSequential(c)
+	}
+	o.serial.RLock()
+	res, err := o.Poracle.Do(c)
+	o.serial.RUnlock()
+	o.record(err)
+	if err == nil {
+		return res, nil
+	}
+	return o.doSequential(c)
+}
+
+// doSequential queries the oracle with no other query in flight.
+func (o *fallbackOracle) doSequential(c []byte) (int, error) {
+	o.serial.Lock()
+	res, err := o.Poracle.Do(c)
+	o.serial.Unlock()
+	o.record(err)
+	return res, err
+}
+
+func (o *fallbackOracle) isSequential() bool {
+	o.mu.Lock()
+	defer o.mu.Unlock()
+	return o.sequential
+}
+
+// record adds the result of a query to the window and switches the mode of
+// the oracle when needed.
+func (o *fallbackOracle) record(err error) {
+	o.mu.Lock()
+	defer o.mu.Unlock()
+	if o.sequential {
+		if err != nil {
+			o.succeeded = 0
+			return
+		}
+		o.succeeded++
+		if o.recovery > 0 && o.succeeded >= o.recovery {
+			o.sequential = false
+			o.recovery *= 2
+			o.resetWindow()
+		}
+		return
+	}
+	if len(o.window) == 0 {
+		return
+	}
+	if o.filled == len(o.window) && o.window[o.next] {
+		o.errors--
+	}
+	o.window[o.next] = err != nil
+	if err != nil {
+		o.errors++
+	}
+	o.next = (o.next + 1) % len(o.window)
+	if o.filled < len(o.window) {
+		o.filled++
+	}
+	if o.filled == len(o.window) && float64(o.errors)/float64(o.filled) > o.threshold {
+		o.sequential = true
+		o.succeeded = 0
+	}
+}
+
+func (o *fallbackOracle) resetWindow() {
+	for i := range o.window {
+		o.window[i] = false
+	}
+	o.next, o.filled, o.errors = 0, 0, 0
+}
@@ -0,0 +1,72 @@
+package goracler
+
+import (
+	"errors"
+	"runtime"
+	"sync/atomic"
+	"testing"
+)
+
+var errOverloaded = errors.New("overloaded")
+
+// overloadedOracle simulates an oracle that fails when it receives more than
+// two concurrent queries.
+type overloadedOracle struct {
+	Poracle
+	inflight int64
+}
+
+func (o *overloadedOracle) Do(c []byte) (int, error) {
+	defer atomic.AddInt64(&o.inflight, -1)
+	if atomic.AddInt64(&o.inflight, 1) > 2 {
+		return 0, errOverloaded
+	}
+	runtime.Gosched()
+	return o.Poracle.Do(c)
+}
+
+func TestDecryptWithSequentialFallback(t *testing.T) {
+	key := "ee581a043ac19191c7d551710bab13a9"
+	c := testCiphertext(t, "Somewhere in la Mancha")
+	if _, err := Decrypt(c, &overloadedOracle{Poracle: testOracle{key: key}}, nopLogger{}, WithConcurrency(16)); err != errOverloaded {
+		t.Fatalf("Decrypt() error = %v, want %v", err, errOverloaded)
+	}
+	got, err := DecryptUnpadded(c, &overloadedOracle{Poracle: testOracle{key: key}}, nopLogger{}, WithConcurrency(16), WithSequentialFallback(0.1))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if want := "Somewhere in la Mancha"; got != want {
+		t.Errorf("DecryptUnpadded() = %q, want %q", got, want)
+	}
+}
+
+func TestFallbackOracleRecovery(t *testing.T) {
+	defer func(w, r int) {
+		SequentialFallbackWindow, SequentialRecoveryQueries = w, r
+	}(SequentialFallbackWindow, SequentialRecoveryQueries)
+	SequentialFallbackWindow, SequentialRecoveryQueries = 4, 2
+	o := newFallbackOracle(constantOracle(1), 0.5)
+	o.record(nil)
+	o.record(errOverloaded)
+	o.record(errOverloaded)
+	if o.isSequential() {
+		t.Fatal("fallbackOracle switched to sequential before filling the window")
+	}
+	o.record(errOverloaded)
+	if !o.isSequential() {
+		t.Fatal("fallbackOracle didn't switch to sequential")
+	}
+	o.record(nil)
+	o.record(errOverloaded)
+	o.record(nil)
+	if !o.isSequential() {
+		t.Fatal("fallbackOracle recovered without enough consecutive successful queries")
+	}
+	o.record(nil)
+	if o.isSequential() {
+		t.Fatal("fallbackOracle didn't recover")
+	}
+	if o.recovery != 4 {
+		t.Errorf("fallbackOracle recovery = %d, want 4", o.recovery)
+	}
+}
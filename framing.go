@@ -0,0 +1,93 @@
+package goracler
+
+import (
+	"encoding/binary"
+	"errors"
+	"fmt"
+)
+
+// ErrInvalidFraming is returned by DecryptFramed when the blob doesn't match
+// the framing spec.
+var ErrInvalidFraming = errors.New("invalid framing")
+
+// FramingSpec describes a blob containing several records, each one with its
+// own IV, encrypted independently. The supported layout is a sequence of
+// records with the shape:
+//
+//	header || iv || ciphertext
+//
+// where the header has a fixed length and contains a length field with the
+// length of iv || ciphertext.
+type FramingSpec struct {
+	// HeaderLen is the length of the header of each record.
+	HeaderLen int
+	// LengthOffset is the offset of the length field inside the header.
+	LengthOffset int
+	// LengthSize is the size in bytes of the length field: 1, 2, 4 or 8.
+	LengthSize int
+	// Order is the byte order of the length field. When nil, big endian is
+	// used.
+	Order binary.ByteOrder
+}
+
+// records splits the blob in the iv || ciphertext of each record.
+func (f FramingSpec) records(blob []byte) ([][]byte, error) {
+	if f.LengthOffset < 0 || f.LengthOffset+f.LengthSize > f.HeaderLen {
+		return nil, fmt.Errorf("%w: length field outside of the header", ErrInvalidFraming)
+	}
+	order := f.Order
+	if order == nil {
+		order = binary.BigEndian
+	}
+	var records [][]byte
+	for len(blob) > 0 {
+		if len(blob) < f.HeaderLen {
+			return nil, fmt.Errorf("%w: truncated header", ErrInvalidFraming)
+		}
+		field := blob[f.LengthOffset : f.LengthOffset+f.LengthSize]
+		var n uint64
+		switch f.LengthSize {
+		case 1:
+			n = uint64(field[0])
+		case 2:
+			n = uint64(order.Uint16(field))
+		case 4:
+			n = uint64(order.Uint32(field))
+		case 8:
+			n = order.Uint64(field)
+		default:
+			return nil, fmt.Errorf("%w: invalid length field size %d", ErrInvalidFraming, f.LengthSize)
+		}
+		blob = blob[f.HeaderLen:]
+		if n > uint64(len(blob)) {
+			return nil, fmt.Errorf("%w: truncated record", ErrInvalidFraming)
+		}
+		records = append(records, blob[:n])
+		blob = blob[n:]
+	}
+	return records, nil
+}
+
+// DecryptFramed splits the blob in records according to the framing spec and
+// performs a decrypt attack against each one of them. It returns the
+// plaintext of each record in the same order they appear in the blob.
+func DecryptFramed(blob []byte, framing FramingSpec, q Poracle, l Logger, opts ...Option) ([]string, error) {
+	records, err := framing.records(blob)
+	if err != nil {
+		return nil, err
+	}
+	cfg := newConfig(opts)
+	var res []string
+	for i, r := range records {
+		l.Printf("\ndecrypting record %d of %d", i+1, len(records))
+		if len(r) < cfg.blockLen {
+			return nil, ErrInvalidCiphertext
+		}
+		m, err := DecryptWithIV(r[:cfg.blockLen], r[cfg.blockLen:], q, l, opts...)
+		if err != nil {
+			return nil, err
+		}
+		res = append(res, m)
+	}
+	return res, nil
+}
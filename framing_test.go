@@ -0,0 +1,51 @@
+package goracler
+
+import (
+	"encoding/binary"
+	"encoding/hex"
+	"errors"
+	"testing"
+
+	"github.com/manelmontilla/goracler/crypto"
+)
+
+func TestDecryptFramed(t *testing.T) {
+	key := "ee581a043ac19191c7d551710bab13a9"
+	ivs := []string{"91db4482c4ffa9858338ab0e98ddf96c", "0102030405060708090a0b0c0d0e0f10"}
+	msgs := []string{"Hello world", "Somewhere in la Mancha"}
+	// Each record has a 4 bytes header: a type byte, a version byte and a
+	// 2 bytes little endian length.
+	spec := FramingSpec{HeaderLen: 4, LengthOffset: 2, LengthSize: 2, Order: binary.LittleEndian}
+	var blob []byte
+	for i := range msgs {
+		ct, err := crypto.CBCEncrypt(ivs[i], key, msgs[i])
+		if err != nil {
+			t.Fatal(err)
+		}
+		c, err := hex.DecodeString(ct)
+		if err != nil {
+			t.Fatal(err)
+		}
+		header := []byte{0x17, 0x03, 0, 0}
+		binary.LittleEndian.PutUint16(header[2:], uint16(len(c)))
+		blob = append(blob, header...)
+		blob = append(blob, c...)
+	}
+	got, err := DecryptFramed(blob, spec, testOracle{key: key}, nopLogger{}, WithPayloadExtractor(PKCS7Extractor))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(got) != len(msgs) {
+		t.Fatalf("DecryptFramed() returned %d records, want %d", len(got), len(msgs))
+	}
+	for i := range msgs {
+		if got[i] != msgs[i] {
+			t.Errorf("record %d = %q, want %q", i, got[i], msgs[i])
+		}
+	}
+
+	_, err = DecryptFramed(blob[:len(blob)-1], spec, testOracle{key: key}, nopLogger{})
+	if !errors.Is(err, ErrInvalidFraming) {
+		t.Errorf("DecryptFramed() error = %v, want %v", err, ErrInvalidFraming)
+	}
+}
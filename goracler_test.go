@@ -1,16 +1,43 @@
 package goracler
 
 import (
+	"bytes"
+	"context"
+	"crypto/aes"
+	"crypto/cipher"
+	"crypto/des"
+	"encoding/binary"
 	"encoding/hex"
+	"errors"
 	"fmt"
 	"io/ioutil"
 	"log"
+	"reflect"
+	"regexp"
+	"runtime"
 	"strings"
+	"sync"
+	"sync/atomic"
 	"testing"
+	"time"
 
 	"github.com/manelmontilla/goracler/crypto"
 )
 
+// testCiphertext returns the given message encrypted with the key and the IV
+// used by the tests, prepending the IV.
+func testCiphertext(t testing.TB, msg string) []byte {
+	ct, err := crypto.CBCEncrypt("91db4482c4ffa9858338ab0e98ddf96c", "ee581a043ac19191c7d551710bab13a9", msg)
+	if err != nil {
+		t.Fatal(err)
+	}
+	c, err := hex.DecodeString(ct)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return c
+}
+
 type testOracle struct {
 	key string
 }
@@ -46,7 +73,7 @@ func Test_decryptBlock(t *testing.T) {
 	}
 	var l log.Logger
 	l.SetOutput(ioutil.Discard)
-	m, err := decryptBlock(c[0:CipherBlockLen], c[CipherBlockLen:CipherBlockLen*2], oracle, &l)
+	m, err := decryptBlock(c[0:CipherBlockLen], c[CipherBlockLen:CipherBlockLen*2], oracle, &l, newConfig(nil))
 	if err != nil {
 		t.Error(err)
 		t.FailNow()
@@ -119,6 +146,60 @@ func TestDecrypt(t *testing.T) {
 	}
 }
 
+func TestDecryptUnpadded(t *testing.T) {
+	type args struct {
+		c []byte
+		q Poracle
+		l Logger
+	}
+	tests := []struct {
+		name        string
+		argsBuilder func(*testing.T) args
+		want        string
+		wantErr     bool
+	}{
+		{
+			name: "DecryptsAndUnpadsMessage",
+			argsBuilder: func(t *testing.T) args {
+				key := "ee581a043ac19191c7d551710bab13a9"
+				msg := "Somewhere in la Mancha"
+				iv := "91db4482c4ffa9858338ab0e98ddf96c"
+				ct, err := crypto.CBCEncrypt(iv, key, msg)
+				if err != nil {
+					t.Error(err)
+					t.FailNow()
+				}
+				q := testOracle{
+					key: key,
+				}
+				c, err := hex.DecodeString(ct)
+				if err != nil {
+					t.Error(err)
+					t.FailNow()
+				}
+				var l log.Logger
+				l.SetOutput(ioutil.Discard)
+				return args{c, q, &l}
+			},
+			want: "Somewhere in la Mancha",
+		},
+	}
+	for _, tt := range tests {
+		tt := tt
+		t.Run(tt.name, func(t *testing.T) {
+			args := tt.argsBuilder(t)
+			got, err := DecryptUnpadded(args.c, args.q, args.l)
+			if (err != nil) != tt.wantErr {
+				t.Errorf("DecryptUnpadded() error = %v, wantErr %v", err, tt.wantErr)
+				return
+			}
+			if got != tt.want {
+				t.Errorf("DecryptUnpadded() = %q, want %q", got, tt.want)
+			}
+		})
+	}
+}
+
 func TestEncrypt(t *testing.T) {
 	type args struct {
 		p []byte
@@ -181,3 +262,1050 @@ func TestEncrypt(t *testing.T) {
 		})
 	}
 }
+
+// lengthCheckOracle simulates an oracle that rejects, with an invalid pad
+// response, the messages not having the expected length.
+type lengthCheckOracle struct {
+	testOracle
+	length int
+}
+
+func (o lengthCheckOracle) Do(c []byte) (int, error) {
+	if len(c) != o.length {
+		return 0, nil
+	}
+	return o.testOracle.Do(c)
+}
+
+func TestDecryptWithFullMessageProbe(t *testing.T) {
+	key := "ee581a043ac19191c7d551710bab13a9"
+	msg := "Somewhere in la Mancha, in a place whose name"
+	c := testCiphertext(t, msg)
+	q := lengthCheckOracle{testOracle{key: key}, len(c)}
+	var l log.Logger
+	l.SetOutput(ioutil.Discard)
+	got, err := DecryptUnpadded(c, q, &l, WithFullMessageProbe(c))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if got != msg {
+		t.Errorf("DecryptUnpadded() = %q, want %q", got, msg)
+	}
+}
+
+// prefixOracle simulates an oracle that rejects the messages not having the
+// expected length and only checks the pad of the first block of ciphertext.
+type prefixOracle struct {
+	testOracle
+	length int
+}
+
+func (o prefixOracle) Do(c []byte) (int, error) {
+	if len(c) != o.length {
+		return 0, nil
+	}
+	return o.testOracle.Do(c[:2*CipherBlockLen])
+}
+
+func TestDecryptWithValidBlockCount(t *testing.T) {
+	key := "ee581a043ac19191c7d551710bab13a9"
+	msg := "Somewhere in la Mancha, in a place whose name"
+	c := testCiphertext(t, msg)
+	q := prefixOracle{testOracle{key: key}, len(c)}
+	if _, err := DecryptUnpadded(c, q, nopLogger{}, WithFullMessageProbe(c)); err == nil {
+		t.Fatal("got no error without the hint")
+	}
+	got, err := DecryptUnpadded(c, q, nopLogger{}, WithFullMessageProbe(c), WithValidBlockCount(2))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if got != msg {
+		t.Errorf("DecryptUnpadded() = %q, want %q", got, msg)
+	}
+	if _, err := Decrypt(c, q, nopLogger{}, WithValidBlockCount(1)); err != ErrInvalidBlockCount {
+		t.Errorf("got error %v, want %v", err, ErrInvalidBlockCount)
+	}
+}
+
+func TestDecryptWithFixedLengthProbe(t *testing.T) {
+	key := "ee581a043ac19191c7d551710bab13a9"
+	msg := "Somewhere in la Mancha, in a place whose name"
+	c := testCiphertext(t, msg)
+	q := lengthCheckOracle{testOracle{key: key}, len(c)}
+	if _, err := DecryptUnpadded(c, q, nopLogger{}); err == nil {
+		t.Fatal("got no error without fixed length probes")
+	}
+	got, err := DecryptUnpadded(c, q, nopLogger{}, WithFixedLengthProbe(len(c)))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if got != msg {
+		t.Errorf("DecryptUnpadded() = %q, want %q", got, msg)
+	}
+	for _, n := range []int{CipherBlockLen, len(c) + 1} {
+		if _, err := Decrypt(c, q, nopLogger{}, WithFixedLengthProbe(n)); err != ErrInvalidCiphertext {
+			t.Errorf("WithFixedLengthProbe(%d) error = %v, want %v", n, err, ErrInvalidCiphertext)
+		}
+	}
+}
+
+func TestDecryptBlockOrder(t *testing.T) {
+	key := "ee581a043ac19191c7d551710bab13a9"
+	msg := "Somewhere in la Mancha, in a place whose name"
+	c := testCiphertext(t, msg)
+	padded := string(crypto.PCKCS5Pad([]byte(msg)))
+	tests := []struct {
+		name      string
+		opts      []Option
+		want      string
+		wantOrder []int
+		wantErr   error
+	}{
+		{
+			name:      "DecryptsInTheGivenOrder",
+			opts:      []Option{WithBlockOrder([]int{2, 0, 1})},
+			want:      padded,
+			wantOrder: []int{2, 0, 1},
+		},
+		{
+			name:      "DecryptsOnlyTheLastBlock",
+			opts:      []Option{WithReverseBlocks(), WithMaxBlocks(1)},
+			want:      padded[2*CipherBlockLen:],
+			wantOrder: []int{2},
+		},
+		{
+			name:    "ReturnsErrorWithRepeatedBlocks",
+			opts:    []Option{WithBlockOrder([]int{0, 0})},
+			wantErr: ErrInvalidBlockOrder,
+		},
+		{
+			name:    "ReturnsErrorWithOutOfRangeBlocks",
+			opts:    []Option{WithBlockOrder([]int{3})},
+			wantErr: ErrInvalidBlockOrder,
+		},
+	}
+	for _, tt := range tests {
+		tt := tt
+		t.Run(tt.name, func(t *testing.T) {
+			var order []int
+			cb := func(i int, block []byte) {
+				order = append(order, i)
+				if string(block) != padded[i*CipherBlockLen:(i+1)*CipherBlockLen] {
+					t.Errorf("block %d = %q", i, block)
+				}
+			}
+			opts := append(tt.opts, WithBlockCallback(cb))
+			got, err := Decrypt(c, testOracle{key: key}, nopLogger{}, opts...)
+			if err != tt.wantErr {
+				t.Fatalf("Decrypt() error = %v, want %v", err, tt.wantErr)
+			}
+			if got != tt.want {
+				t.Errorf("Decrypt() = %q, want %q", got, tt.want)
+			}
+			if fmt.Sprint(order) != fmt.Sprint(tt.wantOrder) {
+				t.Errorf("blocks decrypted in order %v, want %v", order, tt.wantOrder)
+			}
+		})
+	}
+}
+
+func TestDecryptWithProgressCallback(t *testing.T) {
+	key := "ee581a043ac19191c7d551710bab13a9"
+	c := testCiphertext(t, "Somewhere in la Mancha, in a place")
+	type progress struct{ block, blocks, pos int }
+	var got []progress
+	cb := func(blockIndex, totalBlocks, byteIndex int) {
+		got = append(got, progress{blockIndex, totalBlocks, byteIndex})
+	}
+	if _, err := Decrypt(c, testOracle{key: key}, nopLogger{}, WithProgressCallback(cb), WithReverseBlocks()); err != nil {
+		t.Fatal(err)
+	}
+	var want []progress
+	for i := 2; i >= 0; i-- {
+		for p := CipherBlockLen - 1; p >= 0; p-- {
+			want = append(want, progress{i, 3, p})
+		}
+	}
+	if !reflect.DeepEqual(got, want) {
+		t.Errorf("Decrypt() reported progress %v, want %v", got, want)
+	}
+
+	got = nil
+	if _, err := Encrypt([]byte("Hello world"), testOracle{key: key}, nopLogger{}, WithProgressCallback(cb)); err != nil {
+		t.Fatal(err)
+	}
+	if len(got) != CipherBlockLen || got[0] != (progress{0, 1, CipherBlockLen - 1}) {
+		t.Errorf("Encrypt() reported progress %v", got)
+	}
+}
+
+// desOracle is a padding oracle using DES, so its block length is 8 bytes.
+type desOracle struct {
+	block cipher.Block
+}
+
+func (o desOracle) Do(c []byte) (int, error) {
+	_, err := crypto.CBCDecryptWithCipher(o.block, c)
+	if err == crypto.ErrInvalidPad {
+		return 0, nil
+	}
+	if err != nil {
+		return 0, err
+	}
+	return 1, nil
+}
+
+func TestDecryptDES(t *testing.T) {
+	defer func(n int) { CipherBlockLen = n }(CipherBlockLen)
+	CipherBlockLen = des.BlockSize
+	block, err := des.NewCipher([]byte("8bytekey"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	msg := "Somewhere in la Mancha"
+	c, err := crypto.CBCEncryptWithCipher(block, []byte("initvect"), []byte(msg))
+	if err != nil {
+		t.Fatal(err)
+	}
+	got, err := Decrypt(c, desOracle{block}, nopLogger{})
+	if err != nil {
+		t.Fatal(err)
+	}
+	m, err := crypto.RemovePKCS7Pad([]byte(got), des.BlockSize)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if string(m) != msg {
+		t.Errorf("Decrypt() = %q, want %q", m, msg)
+	}
+}
+
+func TestBlockLenPerAttack(t *testing.T) {
+	desBlock, err := des.NewCipher([]byte("8bytekey"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	k, err := hex.DecodeString("ee581a043ac19191c7d551710bab13a9")
+	if err != nil {
+		t.Fatal(err)
+	}
+	aesBlock, err := aes.NewCipher(k)
+	if err != nil {
+		t.Fatal(err)
+	}
+	ciphers := []struct {
+		name  string
+		block cipher.Block
+		opts  []Option
+	}{
+		{"DES", desBlock, []Option{WithBlockLen(des.BlockSize)}},
+		// The AES attacks use the default block length.
+		{"AES", aesBlock, nil},
+	}
+	msg := "Somewhere in la Mancha"
+	var wg sync.WaitGroup
+	errs := make(chan error, 2*2*len(ciphers))
+	for i := 0; i < 2; i++ {
+		for _, c := range ciphers {
+			c := c
+			wg.Add(2)
+			go func() {
+				defer wg.Done()
+				ct, err := crypto.CBCEncryptWithCipher(c.block, make([]byte, c.block.BlockSize()), []byte(msg))
+				if err != nil {
+					errs <- err
+					return
+				}
+				got, err := Decrypt(ct, desOracle{c.block}, nopLogger{}, c.opts...)
+				if err != nil {
+					errs <- fmt.Errorf("%s: Decrypt() error = %w", c.name, err)
+					return
+				}
+				if want := crypto.PKCS7Pad([]byte(msg), c.block.BlockSize()); got != string(want) {
+					errs <- fmt.Errorf("%s: Decrypt() = %q, want %q", c.name, got, want)
+				}
+			}()
+			go func() {
+				defer wg.Done()
+				ct, err := Encrypt([]byte(msg), desOracle{c.block}, nopLogger{}, c.opts...)
+				if err != nil {
+					errs <- fmt.Errorf("%s: Encrypt() error = %w", c.name, err)
+					return
+				}
+				got, err := crypto.CBCDecryptWithCipher(c.block, ct)
+				if err != nil {
+					errs <- fmt.Errorf("%s: decrypting the forged ciphertext: %w", c.name, err)
+					return
+				}
+				if string(got) != msg {
+					errs <- fmt.Errorf("%s: Encrypt() forged %q, want %q", c.name, got, msg)
+				}
+			}()
+		}
+	}
+	wg.Wait()
+	close(errs)
+	for err := range errs {
+		t.Error(err)
+	}
+	if _, err := Decrypt(make([]byte, 32), desOracle{desBlock}, nopLogger{}, WithBlockLen(1)); err != ErrInvalidBlockLen {
+		t.Errorf("Decrypt() error = %v, want %v", err, ErrInvalidBlockLen)
+	}
+}
+
+// slowOracle simulates an oracle that takes longer to answer invalid pads
+// than valid ones.
+type slowOracle struct {
+	testOracle
+	valid, invalid time.Duration
+}
+
+func (o slowOracle) Do(c []byte) (int, error) {
+	res, err := o.testOracle.Do(c)
+	if res > 0 {
+		time.Sleep(o.valid)
+	} else {
+		time.Sleep(o.invalid)
+	}
+	return res, err
+}
+
+func TestHelpersWithBlockLen(t *testing.T) {
+	block, err := des.NewCipher([]byte("8bytekey"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	msg := "Somewhere in la Mancha"
+	c, err := crypto.CBCEncryptWithCipher(block, []byte("initvect"), []byte(msg))
+	if err != nil {
+		t.Fatal(err)
+	}
+	q := desOracle{block}
+	opt := WithBlockLen(des.BlockSize)
+	got, err := DecryptUnpadded(c, q, nopLogger{}, opt)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if got != msg {
+		t.Errorf("DecryptUnpadded() = %q, want %q", got, msg)
+	}
+	if err := HealthCheck(q, opt); err != nil {
+		t.Errorf("HealthCheck() error = %v", err)
+	}
+	if ok, err := IsCBCExploitable(c, q, opt); err != nil || !ok {
+		t.Errorf("IsCBCExploitable() = %t, %v, want true", ok, err)
+	}
+	p, err := MinimalProbe(c, 1, opt)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !bytes.Equal(p, c[des.BlockSize:3*des.BlockSize]) {
+		t.Errorf("MinimalProbe() = %x, want %x", p, c[des.BlockSize:3*des.BlockSize])
+	}
+	report, err := Diagnose(c, q, opt)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !strings.Contains(report, "the leading block looks like the IV") {
+		t.Errorf("Diagnose() report:\n%s", report)
+	}
+	if _, err := AutoTune(q, c, opt); err != nil {
+		t.Errorf("AutoTune() error = %v", err)
+	}
+}
+
+// inflightOracle records the maximum number of queries in flight at the same
+// time.
+type inflightOracle struct {
+	Poracle
+	inflight, max int64
+}
+
+func (o *inflightOracle) Do(c []byte) (int, error) {
+	n := atomic.AddInt64(&o.inflight, 1)
+	defer atomic.AddInt64(&o.inflight, -1)
+	for {
+		max := atomic.LoadInt64(&o.max)
+		if n <= max || atomic.CompareAndSwapInt64(&o.max, max, n) {
+			break
+		}
+	}
+	return o.Poracle.Do(c)
+}
+
+func TestDecryptWithSpeculation(t *testing.T) {
+	key := "ee581a043ac19191c7d551710bab13a9"
+	msg := "Hello world"
+	c := testCiphertext(t, msg)
+	concurrency := 64
+	for _, speculate := range []bool{false, true} {
+		q := &inflightOracle{Poracle: slowOracle{testOracle{key: key}, time.Millisecond, 10 * time.Millisecond}}
+		opts := []Option{WithConcurrency(concurrency)}
+		if speculate {
+			opts = append(opts, WithSpeculation(CipherBlockLen))
+		}
+		got, err := DecryptUnpadded(c, q, nopLogger{}, opts...)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if got != msg {
+			t.Errorf("DecryptUnpadded() = %q, want %q", got, msg)
+		}
+		// Without speculation the search of a byte waits for all the
+		// queries of the previous one. With it, the next search starts
+		// while the slower invalid queries of the previous one are still
+		// in flight.
+		if overlap := q.max > int64(concurrency); overlap != speculate {
+			t.Errorf("speculation %t: got a maximum of %d queries in flight with concurrency %d", speculate, q.max, concurrency)
+		}
+	}
+}
+
+func TestDecryptLastByteAmbiguity(t *testing.T) {
+	key := "ee581a043ac19191c7d551710bab13a9"
+	tests := []struct {
+		name string
+		msg  string
+	}{
+		{
+			name: "FinalByte0x01",
+			msg:  "Somewhere in la",
+		},
+		{
+			name: "FinalByte0x01AfterByte0x02",
+			msg:  "Somewhere in l\x02",
+		},
+		{
+			name: "FinalByte0x02",
+			msg:  "Somewhere in ",
+		},
+		{
+			name: "FinalByte0x10",
+			msg:  "Somewhere in la ",
+		},
+	}
+	for _, tt := range tests {
+		tt := tt
+		t.Run(tt.name, func(t *testing.T) {
+			c := testCiphertext(t, tt.msg)
+			got, err := DecryptUnpadded(c, testOracle{key: key}, nopLogger{})
+			if err != nil {
+				t.Fatal(err)
+			}
+			if got != tt.msg {
+				t.Errorf("DecryptUnpadded() = %q, want %q", got, tt.msg)
+			}
+		})
+	}
+}
+
+func TestDecryptBlockLastByteFalsePositive(t *testing.T) {
+	key := "ee581a043ac19191c7d551710bab13a9"
+	k, err := hex.DecodeString(key)
+	if err != nil {
+		t.Fatal(err)
+	}
+	bc, err := aes.NewCipher(k)
+	if err != nil {
+		t.Fatal(err)
+	}
+	// Craft a block whose plaintext ends with 0x02 X, so two values of the
+	// last byte of the previous block produce a valid pad: g1, producing
+	// 0x02 0x01, and g2, producing 0x02 0x02. The current block is chosen so
+	// g2 is lower than g1, and thus found first when the candidates are tried
+	// in order.
+	current := make([]byte, CipherBlockLen)
+	d := make([]byte, CipherBlockLen)
+	for {
+		bc.Decrypt(d, current)
+		if d[CipherBlockLen-1]&0x02 != 0 {
+			break
+		}
+		current[0]++
+	}
+	want := []byte("Somewhere in l\x02A")
+	prev := xorBlocks(d, want)
+	g1 := d[CipherBlockLen-1] ^ 0x01
+	g2 := d[CipherBlockLen-1] ^ 0x02
+	q := testOracle{key: key}
+	wrong := append([]byte(nil), prev...)
+	wrong[CipherBlockLen-1] = g2
+	if res, err := q.Do(append(wrong, current...)); err != nil || res == 0 || g2 > g1 {
+		t.Fatalf("crafted block doesn't produce a false positive: res %d, err %v, g1 %d, g2 %d", res, err, g1, g2)
+	}
+	got, err := decryptBlock(prev, current, q, nopLogger{}, newConfig([]Option{WithSequentialExecution()}))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !bytes.Equal(got, want) {
+		t.Errorf("decryptBlock() = %q, want %q", got, want)
+	}
+}
+
+func TestDecryptWithBlockCooldown(t *testing.T) {
+	key := "ee581a043ac19191c7d551710bab13a9"
+	msg := "Somewhere in la Mancha, in a place"
+	c := testCiphertext(t, msg)
+	cooldown := 100 * time.Millisecond
+	start := time.Now()
+	got, err := DecryptUnpadded(c, testOracle{key: key}, nopLogger{}, WithBlockCooldown(cooldown))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if got != msg {
+		t.Errorf("DecryptUnpadded() = %q, want %q", got, msg)
+	}
+	// The ciphertext contains three blocks, so the attack waits twice.
+	if d := time.Since(start); d < 2*cooldown {
+		t.Errorf("attack took %s, want at least %s", d, 2*cooldown)
+	}
+}
+
+func TestDecryptContext(t *testing.T) {
+	key := "ee581a043ac19191c7d551710bab13a9"
+	c := testCiphertext(t, "Somewhere in la Mancha, in a place")
+	goroutines := runtime.NumGoroutine()
+	q := &countingOracle{Poracle: slowOracle{testOracle{key: key}, time.Millisecond, time.Millisecond}}
+	ctx, cancel := context.WithTimeout(context.Background(), 30*time.Millisecond)
+	defer cancel()
+	start := time.Now()
+	if _, err := DecryptContext(ctx, c, q, nopLogger{}, WithConcurrency(8)); err != context.DeadlineExceeded {
+		t.Fatalf("DecryptContext() error = %v, want %v", err, context.DeadlineExceeded)
+	}
+	if d := time.Since(start); d > time.Second {
+		t.Errorf("DecryptContext() returned %s after the context was done", d)
+	}
+	// The workers exit once the queries in flight are answered.
+	deadline := time.Now().Add(time.Second)
+	for runtime.NumGoroutine() > goroutines && time.Now().Before(deadline) {
+		time.Sleep(time.Millisecond)
+	}
+	if n := runtime.NumGoroutine(); n > goroutines {
+		t.Errorf("%d goroutines running after the attack was cancelled, want %d", n, goroutines)
+	}
+	sent := atomic.LoadInt64(&q.queries)
+	time.Sleep(10 * time.Millisecond)
+	if n := atomic.LoadInt64(&q.queries); n != sent {
+		t.Errorf("%d queries sent after the attack was cancelled", n-sent)
+	}
+
+	ctx, cancel = context.WithCancel(context.Background())
+	cancel()
+	q = &countingOracle{Poracle: testOracle{key: key}}
+	if _, err := EncryptContext(ctx, []byte("Hello world"), q, nopLogger{}); err != context.Canceled {
+		t.Errorf("EncryptContext() error = %v, want %v", err, context.Canceled)
+	}
+	if q.queries != 0 {
+		t.Errorf("EncryptContext() sent %d queries with a cancelled context", q.queries)
+	}
+}
+
+// blockFailingOracle simulates an oracle that fails when queried to attack a
+// given block.
+type blockFailingOracle struct {
+	testOracle
+	block []byte
+}
+
+func (o blockFailingOracle) Do(c []byte) (int, error) {
+	if bytes.Equal(c[len(c)-CipherBlockLen:], o.block) {
+		return 0, errOverloaded
+	}
+	return o.testOracle.Do(c)
+}
+
+func TestDecryptPartialPlaintext(t *testing.T) {
+	key := "ee581a043ac19191c7d551710bab13a9"
+	msg := "Somewhere in la Mancha, in a place"
+	c := testCiphertext(t, msg)
+	q := blockFailingOracle{testOracle{key: key}, c[2*CipherBlockLen : 3*CipherBlockLen]}
+	got, err := DecryptUnpadded(c, q, nopLogger{})
+	if err != errOverloaded {
+		t.Fatalf("DecryptUnpadded() error = %v, want %v", err, errOverloaded)
+	}
+	if want := msg[:CipherBlockLen]; got != want {
+		t.Errorf("DecryptUnpadded() = %q, want %q", got, want)
+	}
+	// The attack can be resumed from the failed block.
+	rest, err := DecryptUnpadded(c[len(got):], testOracle{key: key}, nopLogger{})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if got+rest != msg {
+		t.Errorf("resumed attack recovered %q, want %q", got+rest, msg)
+	}
+
+	// Cancelling the attack while waiting before the next block also
+	// returns the blocks recovered.
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	cb := func(index int, block []byte) { cancel() }
+	got, err = DecryptContext(ctx, c, testOracle{key: key}, nopLogger{}, WithBlockCallback(cb), WithBlockCooldown(time.Hour))
+	if err != context.Canceled {
+		t.Fatalf("DecryptContext() error = %v, want %v", err, context.Canceled)
+	}
+	if want := msg[:CipherBlockLen]; got != want {
+		t.Errorf("DecryptContext() = %q, want %q", got, want)
+	}
+}
+
+func TestDecryptWithSequentialExecution(t *testing.T) {
+	key := "ee581a043ac19191c7d551710bab13a9"
+	msg := "Somewhere in la Mancha, in a place"
+	c := testCiphertext(t, msg)
+	want, err := Decrypt(c, testOracle{key: key}, nopLogger{})
+	if err != nil {
+		t.Fatal(err)
+	}
+	var queries []int64
+	for i := 0; i < 2; i++ {
+		q := &countingOracle{Poracle: testOracle{key: key}}
+		got, err := Decrypt(c, q, nopLogger{}, WithSequentialExecution())
+		if err != nil {
+			t.Fatal(err)
+		}
+		if got != want {
+			t.Errorf("Decrypt() = %q, want %q", got, want)
+		}
+		queries = append(queries, q.queries)
+	}
+	if queries[0] != queries[1] {
+		t.Errorf("got %d and %d queries, want the same number in both attacks", queries[0], queries[1])
+	}
+}
+
+func TestDecryptDuplicateBlocks(t *testing.T) {
+	key := "ee581a043ac19191c7d551710bab13a9"
+	msg := "Somewhere in la Mancha, in a place"
+	c := testCiphertext(t, msg)
+	// Repeat the pair made of the first and the second blocks of
+	// ciphertext: iv c1 c2 c1 c2 c3.
+	b := func(i int) []byte { return c[i*CipherBlockLen : (i+1)*CipherBlockLen] }
+	dup := joinBlocks([][]byte{b(0), b(1), b(2), b(1), b(2), b(3)})
+	var out bytes.Buffer
+	got, err := Decrypt(dup, testOracle{key: key}, log.New(&out, "", 0))
+	if err != nil {
+		t.Fatal(err)
+	}
+	k, err := hex.DecodeString(key)
+	if err != nil {
+		t.Fatal(err)
+	}
+	bc, err := aes.NewCipher(k)
+	if err != nil {
+		t.Fatal(err)
+	}
+	// The third block of plaintext is the first block of ciphertext
+	// decrypted and xored with the second one.
+	third := make([]byte, CipherBlockLen)
+	bc.Decrypt(third, b(1))
+	third = xorBlocks(third, b(2))
+	padded := pad(PKCS7Padding, []byte(msg), CipherBlockLen)
+	want := joinBlocks([][]byte{padded[:2*CipherBlockLen], third, padded[CipherBlockLen:]})
+	if got != string(want) {
+		t.Errorf("Decrypt() = %q, want %q", got, want)
+	}
+	if !strings.Contains(out.String(), "block 4 is identical to block 2, reusing its plaintext") {
+		t.Errorf("the duplicated block was not reused, log:\n%s", out.String())
+	}
+}
+
+func TestDecryptChunks(t *testing.T) {
+	key := "ee581a043ac19191c7d551710bab13a9"
+	msg := "Somewhere in la Mancha, in a place"
+	c := testCiphertext(t, msg)
+	var chunks [][]byte
+	for i := 0; i < len(c); i += CipherBlockLen {
+		chunks = append(chunks, c[i:i+CipherBlockLen])
+	}
+	got, err := DecryptChunks(chunks, testOracle{key: key}, nopLogger{}, WithPayloadExtractor(PKCS7Extractor))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if got != msg {
+		t.Errorf("DecryptChunks() = %q, want %q", got, msg)
+	}
+	chunks[1] = chunks[1][:CipherBlockLen-1]
+	if _, err := DecryptChunks(chunks, testOracle{key: key}, nopLogger{}); err != ErrInvalidCiphertext {
+		t.Errorf("got error %v for an invalid chunk, want %v", err, ErrInvalidCiphertext)
+	}
+}
+
+func TestEncryptWithBlockForgedCallback(t *testing.T) {
+	key := "ee581a043ac19191c7d551710bab13a9"
+	msg := "Somewhere in la Mancha, in a place"
+	var indexes []int
+	blocks := make(map[int][]byte)
+	cb := func(i int, b []byte) {
+		indexes = append(indexes, i)
+		blocks[i] = append([]byte(nil), b...)
+	}
+	c, err := Encrypt([]byte(msg), testOracle{key: key}, nopLogger{}, WithBlockForgedCallback(cb))
+	if err != nil {
+		t.Fatal(err)
+	}
+	// The padded message has 3 blocks, so the ciphertext has 4, including
+	// the IV, forged from the last to the first.
+	want := []int{3, 2, 1, 0}
+	if fmt.Sprint(indexes) != fmt.Sprint(want) {
+		t.Fatalf("got callbacks for the blocks %v, want %v", indexes, want)
+	}
+	for i, b := range blocks {
+		if got := c[i*CipherBlockLen : (i+1)*CipherBlockLen]; !bytes.Equal(got, b) {
+			t.Errorf("got block %d %x in the callback, want %x", i, b, got)
+		}
+	}
+}
+
+// glitchOracle simulates an oracle that, once, reports as invalid a valid pad
+// of the probes for the target block.
+type glitchOracle struct {
+	testOracle
+	target   []byte
+	glitched *int32
+}
+
+func (o glitchOracle) Do(c []byte) (int, error) {
+	res, err := o.testOracle.Do(c)
+	if err != nil || res == 0 || !bytes.Equal(c[len(c)-CipherBlockLen:], o.target) {
+		return res, err
+	}
+	if atomic.CompareAndSwapInt32(o.glitched, 0, 1) {
+		return 0, nil
+	}
+	return res, nil
+}
+
+func TestDecryptWithBlockRetries(t *testing.T) {
+	key := "ee581a043ac19191c7d551710bab13a9"
+	msg := "Somewhere in la Mancha"
+	c := testCiphertext(t, msg)
+	target := c[CipherBlockLen : 2*CipherBlockLen]
+	q := glitchOracle{testOracle{key: key}, target, new(int32)}
+	if _, err := Decrypt(c, q, nopLogger{}); !errors.Is(err, ErrNoValidByte) {
+		t.Fatalf("Decrypt() without retries error = %v, want %v", err, ErrNoValidByte)
+	}
+
+	q = glitchOracle{testOracle{key: key}, target, new(int32)}
+	got, err := DecryptUnpadded(c, q, nopLogger{}, WithBlockRetries(1))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if got != msg {
+		t.Errorf("DecryptUnpadded() = %q, want %q", got, msg)
+	}
+}
+
+func TestDecryptWithIVFunc(t *testing.T) {
+	key := "ee581a043ac19191c7d551710bab13a9"
+	// The IV of each message is its index as a big endian counter.
+	counterIV := func(i int) []byte {
+		iv := make([]byte, CipherBlockLen)
+		binary.BigEndian.PutUint64(iv[CipherBlockLen-8:], uint64(i))
+		return iv
+	}
+	msgs := []string{"Hello world", "Somewhere in la Mancha"}
+	for i, msg := range msgs {
+		ct, err := crypto.CBCEncrypt(hex.EncodeToString(counterIV(i)), key, msg)
+		if err != nil {
+			t.Fatal(err)
+		}
+		c, err := hex.DecodeString(ct)
+		if err != nil {
+			t.Fatal(err)
+		}
+		got, err := DecryptWithIVFunc(c[CipherBlockLen:], i, counterIV, testOracle{key: key}, nopLogger{}, WithPayloadExtractor(PKCS7Extractor))
+		if err != nil {
+			t.Fatal(err)
+		}
+		if got != msg {
+			t.Errorf("DecryptWithIVFunc() = %q, want %q", got, msg)
+		}
+	}
+	short := func(int) []byte { return make([]byte, CipherBlockLen-1) }
+	if _, err := DecryptWithIVFunc(make([]byte, CipherBlockLen), 0, short, testOracle{key: key}, nopLogger{}); err != ErrInvalidCiphertext {
+		t.Errorf("DecryptWithIVFunc() error = %v, want %v", err, ErrInvalidCiphertext)
+	}
+}
+
+func TestDecryptWithBlockByteOrder(t *testing.T) {
+	key := "ee581a043ac19191c7d551710bab13a9"
+	msg := "Somewhere in la Mancha, in a pla"
+	// The target stores the bytes of each block of plaintext reversed
+	// before encrypting it.
+	stored := make([]byte, 0, len(msg))
+	for i := 0; i < len(msg); i += CipherBlockLen {
+		stored = append(stored, reverseBytes([]byte(msg[i:i+CipherBlockLen]))...)
+	}
+	c := testCiphertext(t, string(stored))
+	// The whole last block is pad, so it's the same in both orders.
+	got, err := DecryptUnpadded(c, testOracle{key: key}, nopLogger{}, WithBlockByteOrder(ReversedByteOrder))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if got != msg {
+		t.Errorf("DecryptUnpadded() = %q, want %q", got, msg)
+	}
+}
+
+// headeredOracle simulates a target receiving messages with a fixed header
+// before the ciphertext.
+type headeredOracle struct {
+	testOracle
+	header []byte
+}
+
+func (o headeredOracle) Do(c []byte) (int, error) {
+	if !bytes.HasPrefix(c, o.header) {
+		return 0, errors.New("invalid header")
+	}
+	return o.testOracle.Do(c[len(o.header):])
+}
+
+func TestDecryptAtOffset(t *testing.T) {
+	key := "ee581a043ac19191c7d551710bab13a9"
+	msg := "Somewhere in la Mancha"
+	c := testCiphertext(t, msg)
+	header := []byte("MSGv1\x00\x2a")
+	data := append(append([]byte{}, header...), c...)
+	q := headeredOracle{testOracle{key: key}, header}
+	got, err := DecryptAtOffset(data, len(header), q, nopLogger{}, WithPayloadExtractor(PKCS7Extractor))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if got != msg {
+		t.Errorf("DecryptAtOffset() = %q, want %q", got, msg)
+	}
+	for _, offset := range []int{-1, len(header) - 1, len(data) + 1} {
+		if _, err := DecryptAtOffset(data, offset, q, nopLogger{}); err != ErrInvalidCiphertext {
+			t.Errorf("DecryptAtOffset(data, %d) error = %v, want %v", offset, err, ErrInvalidCiphertext)
+		}
+	}
+}
+
+func TestDecryptWithStopOnMatch(t *testing.T) {
+	key := "ee581a043ac19191c7d551710bab13a9"
+	msg := "token=abc123 and the rest of the message"
+	c := testCiphertext(t, msg)
+	var report DecryptReport
+	q := &countingOracle{Poracle: testOracle{key: key}}
+	re := regexp.MustCompile(`token=[a-z0-9]{6}`)
+	got, err := Decrypt(c, q, nopLogger{}, WithStopOnMatch(re), WithReport(&report))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if want := msg[:CipherBlockLen]; got != want {
+		t.Errorf("Decrypt() = %q, want %q", got, want)
+	}
+	if !report.StoppedOnMatch {
+		t.Error("got StoppedOnMatch false, want true")
+	}
+	full := &countingOracle{Poracle: testOracle{key: key}}
+	if _, err := Decrypt(c, full, nopLogger{}); err != nil {
+		t.Fatal(err)
+	}
+	if q.queries >= full.queries {
+		t.Errorf("got %d queries stopping on match, want less than %d", q.queries, full.queries)
+	}
+}
+
+func TestDecryptWithPerBlockSalt(t *testing.T) {
+	key := "ee581a043ac19191c7d551710bab13a9"
+	msg := "Somewhere in la Mancha"
+	// The target prepends a salt of 4 bytes to each 12 bytes of data.
+	salted := "\x8a\x01\x5f\xe3" + msg[:12] + "\x17\xc4\x02\x9b" + msg[12:]
+	c := testCiphertext(t, salted)
+	got, err := DecryptUnpadded(c, testOracle{key: key}, nopLogger{}, WithPerBlockSalt(4))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if got != msg {
+		t.Errorf("DecryptUnpadded() = %q, want %q", got, msg)
+	}
+	if _, err := Decrypt(c, testOracle{key: key}, nopLogger{}, WithPerBlockSalt(CipherBlockLen)); err != ErrInvalidSaltLength {
+		t.Errorf("got error %v, want %v", err, ErrInvalidSaltLength)
+	}
+}
+
+// shortPadOracle simulates an oracle that, for the target block, only reports
+// as valid the pads shorter than a given length.
+type shortPadOracle struct {
+	testOracle
+	target []byte
+	maxPad int
+}
+
+func (o shortPadOracle) Do(c []byte) (int, error) {
+	n := len(c)
+	current := c[n-CipherBlockLen:]
+	if bytes.Equal(current, o.target) {
+		k, err := hex.DecodeString(o.key)
+		if err != nil {
+			return 0, err
+		}
+		bc, err := aes.NewCipher(k)
+		if err != nil {
+			return 0, err
+		}
+		m := make([]byte, CipherBlockLen)
+		bc.Decrypt(m, current)
+		if int(m[CipherBlockLen-1]^c[n-CipherBlockLen-1]) > o.maxPad {
+			return 0, nil
+		}
+	}
+	return o.testOracle.Do(c)
+}
+
+func TestDecryptPositionPolicies(t *testing.T) {
+	key := "ee581a043ac19191c7d551710bab13a9"
+	msg := "Somewhere in la Mancha"
+	c := testCiphertext(t, msg)
+	target := c[CipherBlockLen : 2*CipherBlockLen]
+
+	t.Run("RetriesPositions", func(t *testing.T) {
+		q := glitchOracle{testOracle{key: key}, target, new(int32)}
+		got, err := DecryptUnpadded(c, q, nopLogger{}, WithPositionRetry(1))
+		if err != nil {
+			t.Fatal(err)
+		}
+		if got != msg {
+			t.Errorf("DecryptUnpadded() = %q, want %q", got, msg)
+		}
+	})
+
+	t.Run("SkipsUnrecoverableBytes", func(t *testing.T) {
+		q := shortPadOracle{testOracle{key: key}, target, 3}
+		if _, err := Decrypt(c, q, nopLogger{}); !errors.Is(err, ErrNoValidByte) {
+			t.Fatalf("Decrypt() without skipping error = %v, want %v", err, ErrNoValidByte)
+		}
+		got, err := DecryptUnpadded(c, q, nopLogger{}, WithSkipUnrecoverableBytes(true))
+		if err != nil {
+			t.Fatal(err)
+		}
+		// The byte at position 12 needs a pad of length 4, so it and the
+		// bytes before it in the block are skipped.
+		want := strings.Repeat(string(FailedBytePlaceholder), 13) + msg[13:]
+		if got != want {
+			t.Errorf("DecryptUnpadded() = %q, want %q", got, want)
+		}
+	})
+}
+
+func TestDecryptTruncated(t *testing.T) {
+	key := "ee581a043ac19191c7d551710bab13a9"
+	msg := "Somewhere in la Mancha, in a place whose name I do not care to remember"
+	c := testCiphertext(t, msg)
+	q := testOracle{key: key}
+	// The last block and part of the one before it are missing.
+	truncated := c[:len(c)-CipherBlockLen-5]
+	var report DecryptReport
+	got, err := DecryptTruncated(truncated, len(c), q, nopLogger{}, WithReport(&report))
+	if err != ErrTruncatedCiphertext {
+		t.Fatalf("DecryptTruncated() error = %v, want %v", err, ErrTruncatedCiphertext)
+	}
+	n := len(c)/CipherBlockLen - 1
+	if want := msg[:(n-2)*CipherBlockLen]; got != want {
+		t.Errorf("DecryptTruncated() = %q, want %q", got, want)
+	}
+	if want := []int{n - 2, n - 1}; !reflect.DeepEqual(report.MissingBlocks, want) {
+		t.Errorf("got missing blocks %v, want %v", report.MissingBlocks, want)
+	}
+
+	got, err = DecryptTruncated(c, len(c), q, nopLogger{}, WithPayloadExtractor(PKCS7Extractor))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if got != msg {
+		t.Errorf("DecryptTruncated() = %q, want %q", got, msg)
+	}
+	if _, err := DecryptTruncated(c, len(c)-1, q, nopLogger{}); err != ErrInvalidCiphertext {
+		t.Errorf("DecryptTruncated() error = %v, want %v", err, ErrInvalidCiphertext)
+	}
+}
+
+func TestEncryptWithForgedPadding(t *testing.T) {
+	key := "ee581a043ac19191c7d551710bab13a9"
+	k, err := hex.DecodeString(key)
+	if err != nil {
+		t.Fatal(err)
+	}
+	b, err := aes.NewCipher(k)
+	if err != nil {
+		t.Fatal(err)
+	}
+	q := testOracle{key: key}
+	tests := []struct {
+		name    string
+		payload string
+		padding []byte
+	}{
+		{
+			name:    "ForgesASpecificPadLength",
+			payload: "role=admin",
+			padding: []byte("XXX\x03\x03\x03"),
+		},
+		{
+			name:    "ForgesAFullBlockPad",
+			payload: "Somewhere in la Mancha, in a pla",
+			padding: bytes.Repeat([]byte{0x10}, CipherBlockLen),
+		},
+	}
+	for _, tt := range tests {
+		tt := tt
+		t.Run(tt.name, func(t *testing.T) {
+			c, err := Encrypt([]byte(tt.payload), q, nopLogger{}, WithForgedPadding(tt.padding))
+			if err != nil {
+				t.Fatal(err)
+			}
+			m := make([]byte, len(c)-CipherBlockLen)
+			cipher.NewCBCDecrypter(b, c[:CipherBlockLen]).CryptBlocks(m, c[CipherBlockLen:])
+			if want := tt.payload + string(tt.padding); string(m) != want {
+				t.Errorf("forged plaintext %q, want %q", m, want)
+			}
+		})
+	}
+	if _, err := Encrypt([]byte("role=admin"), q, nopLogger{}, WithForgedPadding([]byte{0x01})); err != ErrInvalidForgedPadding {
+		t.Errorf("Encrypt() error = %v, want %v", err, ErrInvalidForgedPadding)
+	}
+}
+
+func TestNoValidByteErrorLikelyCause(t *testing.T) {
+	key := "ee581a043ac19191c7d551710bab13a9"
+	c := testCiphertext(t, "Somewhere in la Mancha, in a place")
+	tests := []struct {
+		name string
+		q    Poracle
+		opts []Option
+		want NoValidByteError
+	}{
+		{
+			name: "SetupErrorWhenNoByteIsRecovered",
+			q:    constantOracle(0),
+			opts: []Option{WithClassifierCheck(false)},
+			want: NoValidByteError{Block: 0, Position: CipherBlockLen - 1, Likely: SetupError},
+		},
+		{
+			name: "TransientOracleAfterRecoveringBytes",
+			// The oracle stops reporting the pads longer than 3 of the
+			// second block.
+			q:    shortPadOracle{testOracle{key: key}, c[2*CipherBlockLen : 3*CipherBlockLen], 3},
+			want: NoValidByteError{Block: 1, Position: 12, Likely: TransientOracle},
+		},
+	}
+	for _, tt := range tests {
+		tt := tt
+		t.Run(tt.name, func(t *testing.T) {
+			_, err := Decrypt(c, tt.q, nopLogger{}, tt.opts...)
+			var nvb *NoValidByteError
+			if !errors.As(err, &nvb) {
+				t.Fatalf("Decrypt() error = %v, want a *NoValidByteError", err)
+			}
+			if *nvb != tt.want {
+				t.Errorf("Decrypt() error = %+v, want %+v", *nvb, tt.want)
+			}
+			if !errors.Is(err, ErrNoValidByte) {
+				t.Errorf("Decrypt() error = %v, want it to wrap %v", err, ErrNoValidByte)
+			}
+		})
+	}
+}
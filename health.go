@@ -0,0 +1,151 @@
+package goracler
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"sync"
+	"time"
+)
+
+var (
+	// ErrUnhealthyOracle is returned when the oracle doesn't behave as
+	// expected for a PKCS#7 pad.
+	ErrUnhealthyOracle = errors.New("unhealthy oracle")
+
+	// HealthCheckRetries is the number of times a failed health check is
+	// repeated during an attack before aborting it.
+	HealthCheckRetries = 5
+
+	// HealthCheckBackoff is the time to wait before repeating a failed
+	// health check the first time. It's doubled after each retry.
+	HealthCheckBackoff = time.Second
+)
+
+// HealthCheck verifies that the oracle is reachable and behaves as expected
+// for a PKCS#7 pad. It uses ValidatePKCS7Behavior, so it sends to the oracle
+// the queries needed to decrypt a block plus a few more. It returns the error
+// returned by the oracle, if any, or an error wrapping ErrUnhealthyOracle with
+// the report of ValidatePKCS7Behavior if the oracle doesn't behave as
+// expected. The block length is the one set with the WithBlockLen option, the
+// rest of options are ignored.
+func HealthCheck(q Poracle, opts ...Option) error {
+	report, ok, err := ValidatePKCS7Behavior(q, newConfig(opts).blockLen)
+	if err != nil {
+		return err
+	}
+	if !ok {
+		return fmt.Errorf("%w:\n%s", ErrUnhealthyOracle, report)
+	}
+	return nil
+}
+
+// WithHealthCheckInterval makes Decrypt check the health of the oracle every
+// d, and each time the oracle returns an error. The check sends two queries
+// built from the last two blocks of the ciphertext: the blocks as they are,
+// which the oracle must report as valid, and the blocks with the pad made
+// invalid, which the oracle must report as invalid. The queries sent by the
+// checks count toward the query budget.
+//
+// While the oracle is unhealthy the attack is paused: no queries are sent
+// except the ones of the check, which is repeated up to HealthCheckRetries
+// times, waiting HealthCheckBackoff before the first retry and doubling the
+// wait after each one. When the oracle recovers, the attack resumes and the
+// query that returned an error is sent again, once. Otherwise, the attack is
+// aborted with an error wrapping ErrUnhealthyOracle.
+func WithHealthCheckInterval(d time.Duration) Option {
+	return func(c *config) {
+		c.healthInterval = d
+	}
+}
+
+// healthOracle is a Poracle that checks the health of the wrapped oracle
+// periodically and when it returns an error.
+type healthOracle struct {
+	Poracle
+	prev, current []byte
+	cfg           *config
+	l             Logger
+
+	// gate is held for writing while checking the health of the oracle, so
+	// no other queries are sent.
+	gate sync.RWMutex
+	// mu serializes the checks and protects last and err.
+	mu   sync.Mutex
+	last time.Time
+	err  error
+}
+
+func newHealthOracle(q Poracle, prev, current []byte, l Logger, cfg *config) *healthOracle {
+	return &healthOracle{Poracle: q, prev: prev, current: current, cfg: cfg, l: l, last: time.Now()}
+}
+
+func (o *healthOracle) Do(c []byte) (int, error) {
+	if err := o.check(time.Now().Add(-o.cfg.healthInterval)); err != nil {
+		return 0, err
+	}
+	for retried := false; ; retried = true {
+		start := time.Now()
+		o.gate.RLock()
+		res, err := o.Poracle.Do(c)
+		o.gate.RUnlock()
+		if err == nil || retried || !isOracleFailure(err) {
+			return res, err
+		}
+		o.l.Printf("\nthe oracle returned an error: %s, checking its health", err)
+		if err := o.check(start); err != nil {
+			return 0, err
+		}
+	}
+}
+
+// check checks the health of the oracle unless it was already checked after
+// the given time.
+func (o *healthOracle) check(after time.Time) error {
+	o.mu.Lock()
+	defer o.mu.Unlock()
+	if o.err != nil {
+		return o.err
+	}
+	if o.last.After(after) {
+		return nil
+	}
+	o.gate.Lock()
+	defer o.gate.Unlock()
+	wait := HealthCheckBackoff
+	for attempt := 0; ; attempt++ {
+		err := o.probe()
+		if err == nil {
+			o.last = time.Now()
+			return nil
+		}
+		if attempt >= HealthCheckRetries {
+			o.err = fmt.Errorf("%w: %v", ErrUnhealthyOracle, err)
+			return o.err
+		}
+		o.l.Printf("\nthe oracle is unhealthy: %s, checking again in %s", err, wait)
+		if err := sleepContext(o.cfg.attackContext(), wait); err != nil {
+			return err
+		}
+		wait *= 2
+	}
+}
+
+// probe sends the queries of a health check.
+func (o *healthOracle) probe() error {
+	valid, invalid, err := queryKnownPad(o.Poracle, o.prev, o.current, o.cfg)
+	if err != nil {
+		return err
+	}
+	if valid == 0 || invalid != 0 {
+		return errors.New("the oracle returned wrong results for a known pad")
+	}
+	return nil
+}
+
+// isOracleFailure returns true if the error was returned by the oracle, and
+// not by the budgets or the context of the attack.
+func isOracleFailure(err error) bool {
+	return !errors.Is(err, ErrQueryBudgetExhausted) && !errors.Is(err, errBlockBudgetExhausted) &&
+		!errors.Is(err, context.Canceled) && !errors.Is(err, context.DeadlineExceeded)
+}
@@ -0,0 +1,74 @@
+package goracler
+
+import (
+	"errors"
+	"sync"
+	"sync/atomic"
+	"testing"
+	"time"
+)
+
+func TestHealthCheck(t *testing.T) {
+	key := "ee581a043ac19191c7d551710bab13a9"
+	if err := HealthCheck(testOracle{key: key}); err != nil {
+		t.Errorf("HealthCheck() error = %v for a healthy oracle", err)
+	}
+	if err := HealthCheck(lastByteOracle{key: key}); !errors.Is(err, ErrUnhealthyOracle) {
+		t.Errorf("HealthCheck() error = %v, want %v", err, ErrUnhealthyOracle)
+	}
+	q := &downOracle{testOracle: testOracle{key: key}, downFor: time.Hour}
+	if err := HealthCheck(q); err != errOracleDown {
+		t.Errorf("HealthCheck() error = %v, want %v", err, errOracleDown)
+	}
+}
+
+var errOracleDown = errors.New("connection refused")
+
+// downOracle simulates an oracle that goes down after receiving a given
+// number of queries and recovers after being down for the given time.
+type downOracle struct {
+	testOracle
+	downAfter int64
+	downFor   time.Duration
+	queries   int64
+
+	once  sync.Once
+	until time.Time
+}
+
+func (o *downOracle) Do(c []byte) (int, error) {
+	if atomic.AddInt64(&o.queries, 1) > o.downAfter {
+		o.once.Do(func() { o.until = time.Now().Add(o.downFor) })
+		if time.Now().Before(o.until) {
+			return 0, errOracleDown
+		}
+	}
+	return o.testOracle.Do(c)
+}
+
+func TestWithHealthCheckInterval(t *testing.T) {
+	defer func(b time.Duration) { HealthCheckBackoff = b }(HealthCheckBackoff)
+	HealthCheckBackoff = time.Millisecond
+	key := "ee581a043ac19191c7d551710bab13a9"
+	msg := "Somewhere in la Mancha"
+	c := testCiphertext(t, msg)
+
+	t.Run("RecoversFromOutages", func(t *testing.T) {
+		q := &downOracle{testOracle: testOracle{key: key}, downAfter: 500, downFor: 10 * time.Millisecond}
+		got, err := DecryptUnpadded(c, q, nopLogger{}, WithHealthCheckInterval(time.Millisecond))
+		if err != nil {
+			t.Fatal(err)
+		}
+		if got != msg {
+			t.Errorf("DecryptUnpadded() = %q, want %q", got, msg)
+		}
+	})
+
+	t.Run("AbortsWhenTheOracleDoesNotRecover", func(t *testing.T) {
+		q := &downOracle{testOracle: testOracle{key: key}, downAfter: 500, downFor: time.Hour}
+		_, err := Decrypt(c, q, nopLogger{}, WithHealthCheckInterval(time.Minute))
+		if !errors.Is(err, ErrUnhealthyOracle) {
+			t.Errorf("Decrypt() error = %v, want %v", err, ErrUnhealthyOracle)
+		}
+	})
+}
@@ -0,0 +1,118 @@
+package goracler
+
+import (
+	"sync"
+	"time"
+)
+
+var (
+	// CandidateHistogramBounds are the bounds of the buckets of the
+	// CandidatesPerByte histogram of the DecryptReport.
+	CandidateHistogramBounds = []float64{16, 32, 48, 64, 80, 96, 112, 128, 144, 160, 176, 192, 208, 224, 240, 256}
+
+	// LatencyHistogramBounds are the bounds, in milliseconds, of the
+	// buckets of the LatencyMillis histogram of the DecryptReport.
+	LatencyHistogramBounds = []float64{1, 2, 5, 10, 20, 50, 100, 200, 500, 1000, 2000, 5000}
+)
+
+// Histogram counts values in buckets.
+type Histogram struct {
+	// Bounds are the inclusive upper bounds of the buckets, in increasing
+	// order.
+	Bounds []float64
+	// Counts contains the number of values in each bucket: Counts[i] is the
+	// number of values greater than Bounds[i-1] and lower or equal than
+	// Bounds[i]. It has an extra element at the end with the number of
+	// values greater than the last bound.
+	Counts []int64
+}
+
+func newHistogram(bounds []float64) *Histogram {
+	b := make([]float64, len(bounds))
+	copy(b, bounds)
+	return &Histogram{Bounds: b, Counts: make([]int64, len(bounds)+1)}
+}
+
+// add adds a value to the histogram.
+func (h *Histogram) add(v float64) {
+	i := 0
+	for i < len(h.Bounds) && v > h.Bounds[i] {
+		i++
+	}
+	h.Counts[i]++
+}
+
+// Total returns the number of values in the histogram.
+func (h *Histogram) Total() int64 {
+	var n int64
+	for _, c := range h.Counts {
+		n += c
+	}
+	return n
+}
+
+// WithHistograms makes Decrypt fill the CandidatesPerByte and the
+// LatencyMillis histograms of the report set with WithReport. They are not
+// computed by default to avoid their overhead, and the option has no effect
+// without a report.
+func WithHistograms() Option {
+	return func(c *config) {
+		c.histograms = true
+	}
+}
+
+// latencyOracle is a Poracle that records the time the wrapped oracle takes
+// to answer each query in a histogram.
+type latencyOracle struct {
+	Poracle
+	mu *sync.Mutex
+	h  *Histogram
+}
+
+func (o latencyOracle) Do(c []byte) (int, error) {
+	start := time.Now()
+	res, err := o.Poracle.Do(c)
+	ms := float64(time.Since(start)) / float64(time.Millisecond)
+	o.mu.Lock()
+	o.h.add(ms)
+	o.mu.Unlock()
+	return res, err
+}
+
+// collectHistograms prepares the config to collect the histograms of a
+// decrypt attack. It returns a function that, when called, fills the
+// histograms of the report. It does nothing if the histograms are not
+// enabled or there is no report.
+func collectHistograms(cfg *config) func() {
+	if !cfg.histograms || cfg.report == nil {
+		return func() {}
+	}
+	latency := newHistogram(LatencyHistogramBounds)
+	mu := &sync.Mutex{}
+	cfg.latency = func(q Poracle) Poracle { return latencyOracle{q, mu, latency} }
+	// The candidates tried are counted using a trace, the one set by the
+	// caller, if any, from the blocks added by this attack.
+	if cfg.trace == nil {
+		cfg.trace = &Trace{}
+	}
+	t := cfg.trace
+	t.mu.Lock()
+	start := len(t.Blocks)
+	t.mu.Unlock()
+	return func() {
+		candidates := newHistogram(CandidateHistogramBounds)
+		t.mu.Lock()
+		for _, b := range t.Blocks[start:] {
+			for _, p := range b.Positions {
+				if p.Found {
+					candidates.add(float64(p.Tried))
+				}
+			}
+		}
+		t.mu.Unlock()
+		mu.Lock()
+		cfg.report.LatencyMillis = latency
+		mu.Unlock()
+		cfg.report.CandidatesPerByte = candidates
+	}
+}
@@ -0,0 +1,50 @@
+package goracler
+
+import "sync"
+
+// HostPoracle is implemented by the oracles that send the queries to a single
+// target host. Host returns an identifier of the target, like "host:port",
+// used to limit the concurrent queries sent to the same host.
+type HostPoracle interface {
+	Poracle
+	Host() string
+}
+
+// WithPerHostConcurrency limits to n the number of queries sent at the same
+// time to each target host by the oracles implementing HostPoracle. The
+// limit applies to all the attacks using the Option returned by a single
+// call to WithPerHostConcurrency, so the attacks run by EncryptAll, or
+// concurrent calls to Decrypt using the same Option with different oracles
+// for the same host, never send more than n queries at the same time to that
+// host. The limit is applied on top of the one set by WithConcurrency, which
+// defaults to MaxGoroutines, so each attack sends at most the minimum of both
+// values to a host. The oracles not implementing HostPoracle are not limited.
+// Values lower than 1 are ignored.
+func WithPerHostConcurrency(n int) Option {
+	if n < 1 {
+		return func(*config) {}
+	}
+	h := &hostLimiter{n: n, sems: make(map[string]chan struct{})}
+	return func(c *config) {
+		c.hosts = h
+	}
+}
+
+// hostLimiter holds a semaphore for each target host.
+type hostLimiter struct {
+	mu   sync.Mutex
+	n    int
+	sems map[string]chan struct{}
+}
+
+// sem returns the semaphore for the given host.
+func (h *hostLimiter) sem(host string) chan struct{} {
+	h.mu.Lock()
+	defer h.mu.Unlock()
+	s, ok := h.sems[host]
+	if !ok {
+		s = make(chan struct{}, h.n)
+		h.sems[host] = s
+	}
+	return s
+}
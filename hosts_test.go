@@ -0,0 +1,91 @@
+package goracler
+
+import (
+	"runtime"
+	"sync"
+	"sync/atomic"
+	"testing"
+)
+
+// hostOracle simulates an oracle for a host that records the maximum number of
+// queries it received at the same time.
+type hostOracle struct {
+	testOracle
+	host     string
+	inflight *int64
+	max      *int64
+}
+
+func (o hostOracle) Host() string {
+	return o.host
+}
+
+func (o hostOracle) Do(c []byte) (int, error) {
+	n := atomic.AddInt64(o.inflight, 1)
+	defer atomic.AddInt64(o.inflight, -1)
+	for {
+		m := atomic.LoadInt64(o.max)
+		if n <= m || atomic.CompareAndSwapInt64(o.max, m, n) {
+			break
+		}
+	}
+	runtime.Gosched()
+	return o.testOracle.Do(c)
+}
+
+func TestWithPerHostConcurrency(t *testing.T) {
+	key := "ee581a043ac19191c7d551710bab13a9"
+	msg := "Somewhere in la Mancha"
+	c := testCiphertext(t, msg)
+	const perHost = 3
+	opt := WithPerHostConcurrency(perHost)
+	hosts := []string{"a.example:443", "b.example:443"}
+	inflight := make(map[string]*int64)
+	max := make(map[string]*int64)
+	for _, h := range hosts {
+		inflight[h], max[h] = new(int64), new(int64)
+	}
+	// Two attacks per host, sharing the option, so the limit must hold for
+	// all the queries sent to a host by different attacks.
+	var wg sync.WaitGroup
+	errs := make(chan error, 2*len(hosts))
+	for _, h := range hosts {
+		for i := 0; i < 2; i++ {
+			q := hostOracle{testOracle{key: key}, h, inflight[h], max[h]}
+			wg.Add(1)
+			go func() {
+				defer wg.Done()
+				got, err := DecryptUnpadded(c, q, nopLogger{}, opt, WithConcurrency(16))
+				if err == nil && got != msg {
+					t.Errorf("DecryptUnpadded() = %q, want %q", got, msg)
+				}
+				errs <- err
+			}()
+		}
+	}
+	wg.Wait()
+	close(errs)
+	for err := range errs {
+		if err != nil {
+			t.Fatal(err)
+		}
+	}
+	for _, h := range hosts {
+		if m := atomic.LoadInt64(max[h]); m > perHost || m == 0 {
+			t.Errorf("got a maximum of %d concurrent queries to %s, want at most %d", m, h, perHost)
+		}
+	}
+
+	// The attacks run by EncryptAll share the limit too.
+	var encInflight, encMax int64
+	q := hostOracle{testOracle{key: key}, "c.example:443", &encInflight, &encMax}
+	_, encErrs := EncryptAll([]string{"Hello world", "Hello moon", "Hello sun"}, q, nopLogger{}, opt, WithConcurrency(16))
+	for _, err := range encErrs {
+		if err != nil {
+			t.Fatal(err)
+		}
+	}
+	if m := atomic.LoadInt64(&encMax); m > perHost || m == 0 {
+		t.Errorf("got a maximum of %d concurrent queries from EncryptAll, want at most %d", m, perHost)
+	}
+}
@@ -0,0 +1,132 @@
+package goracler
+
+import (
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+)
+
+// IntermediatesFormat and IntermediatesVersion identify the format written
+// by ExportIntermediates.
+const (
+	IntermediatesFormat  = "goracler-intermediates"
+	IntermediatesVersion = 1
+)
+
+var (
+	// ErrInvalidIntermediates is returned by ImportIntermediates when the
+	// data read is not in the format written by ExportIntermediates.
+	ErrInvalidIntermediates = errors.New("invalid intermediates file")
+
+	// ErrMissingIntermediate is returned by ForgeOffline when the corpus
+	// doesn't contain the intermediate value of one of the blocks needed to
+	// forge the ciphertext.
+	ErrMissingIntermediate = errors.New("intermediate value not found in the corpus")
+)
+
+// intermediatesFile is the document written by ExportIntermediates.
+type intermediatesFile struct {
+	Format    string `json:"format"`
+	Version   int    `json:"version"`
+	BlockSize int    `json:"block_size"`
+	// Intermediates maps the hex encoded blocks of ciphertext to their hex
+	// encoded intermediate values.
+	Intermediates map[string]string `json:"intermediates"`
+}
+
+// ExportIntermediates writes the intermediate values stored in the corpus to
+// w, so they can be shared and loaded using ImportIntermediates. The data is
+// a JSON object like:
+//
+//	{
+//	  "format": "goracler-intermediates",
+//	  "version": 1,
+//	  "block_size": 16,
+//	  "intermediates": {
+//	    "<hex encoded block of ciphertext>": "<hex encoded intermediate value>"
+//	  }
+//	}
+//
+// The blocks of ciphertext, and not a hash of them, are used as keys, because
+// forging a ciphertext needs the blocks themselves. The block size written is
+// the one set with the WithBlockLen option, the rest of options are ignored.
+func (c *Corpus) ExportIntermediates(w io.Writer, opts ...Option) error {
+	f := intermediatesFile{
+		Format:        IntermediatesFormat,
+		Version:       IntermediatesVersion,
+		BlockSize:     newConfig(opts).blockLen,
+		Intermediates: make(map[string]string),
+	}
+	c.mu.Lock()
+	for b, v := range c.blocks {
+		f.Intermediates[hex.EncodeToString([]byte(b))] = hex.EncodeToString(v)
+	}
+	c.mu.Unlock()
+	enc := json.NewEncoder(w)
+	enc.SetIndent("", "  ")
+	return enc.Encode(f)
+}
+
+// ImportIntermediates adds to the corpus the intermediate values read from r,
+// that must be in the format written by ExportIntermediates. It returns
+// ErrInvalidIntermediates if the format or the version don't match the ones
+// supported by the package, or the block size doesn't match the one set with
+// the WithBlockLen option. The rest of options are ignored.
+func (c *Corpus) ImportIntermediates(r io.Reader, opts ...Option) error {
+	blockLen := newConfig(opts).blockLen
+	var f intermediatesFile
+	if err := json.NewDecoder(r).Decode(&f); err != nil {
+		return err
+	}
+	if f.Format != IntermediatesFormat || f.Version != IntermediatesVersion {
+		return ErrInvalidIntermediates
+	}
+	if f.BlockSize != blockLen {
+		return fmt.Errorf("%w: block size %d doesn't match the block length %d", ErrInvalidIntermediates, f.BlockSize, blockLen)
+	}
+	blocks := make(map[string][]byte, len(f.Intermediates))
+	for hb, hv := range f.Intermediates {
+		b, err := hex.DecodeString(hb)
+		if err != nil {
+			return err
+		}
+		v, err := hex.DecodeString(hv)
+		if err != nil {
+			return err
+		}
+		if len(b) != blockLen || len(v) != blockLen {
+			return ErrInvalidIntermediates
+		}
+		blocks[string(b)] = v
+	}
+	for b, v := range blocks {
+		c.Add([]byte(b), v)
+	}
+	return nil
+}
+
+// ForgeOffline forges a ciphertext for the payload in the same way Encrypt
+// does, but using only the intermediate values stored in the corpus, so it
+// doesn't query any oracle. As Encrypt always forges the ciphertexts
+// backwards from the same last block, the corpus contains the intermediate
+// values needed to forge the payload when it was filled by calls to Encrypt,
+// using WithCorpus, for payloads ending with the same blocks, for instance,
+// by importing the intermediates exported by someone who forged them. It
+// returns ErrMissingIntermediate if any of them is missing. The options
+// setting the padding scheme, the ciphertext transformation and the
+// interleaved tags are honoured, and the ones related to the queries are
+// ignored.
+func ForgeOffline(payload []byte, corpus *Corpus, opts ...Option) ([]byte, error) {
+	opts = append(opts, WithCorpus(corpus), WithoutAlwaysValidCheck())
+	return Encrypt(payload, offlineOracle{}, nopLogger{}, opts...)
+}
+
+// offlineOracle is a Poracle that fails all the queries, used to forge
+// ciphertexts without querying an oracle.
+type offlineOracle struct{}
+
+func (offlineOracle) Do(c []byte) (int, error) {
+	return 0, ErrMissingIntermediate
+}
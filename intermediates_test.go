@@ -0,0 +1,92 @@
+package goracler
+
+import (
+	"bytes"
+	"encoding/hex"
+	"errors"
+	"strings"
+	"testing"
+
+	"github.com/manelmontilla/goracler/crypto"
+)
+
+func TestExportImportIntermediates(t *testing.T) {
+	key := "ee581a043ac19191c7d551710bab13a9"
+	tail := "the tail of the message"
+	corpus := NewCorpus()
+	if _, err := Encrypt([]byte("AAAAAAAAAAAAAAAA"+tail), testOracle{key: key}, nopLogger{}, WithCorpus(corpus)); err != nil {
+		t.Fatal(err)
+	}
+	var buf bytes.Buffer
+	if err := corpus.ExportIntermediates(&buf); err != nil {
+		t.Fatal(err)
+	}
+	imported := NewCorpus()
+	if err := imported.ImportIntermediates(bytes.NewReader(buf.Bytes())); err != nil {
+		t.Fatal(err)
+	}
+	if imported.Len() != corpus.Len() {
+		t.Fatalf("got %d imported blocks, want %d", imported.Len(), corpus.Len())
+	}
+
+	// The payload ends with the same blocks as the one forged online, so
+	// it can be forged offline.
+	msg := "BBBBBBBBBBBBBBBB" + tail
+	c, err := ForgeOffline([]byte(msg), imported)
+	if err != nil {
+		t.Fatal(err)
+	}
+	got, err := crypto.CBCDecrypt(key, hex.EncodeToString(c))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if got != msg {
+		t.Errorf("got forged plaintext %q, want %q", got, msg)
+	}
+
+	longer := "CCCCCCCCCCCCCCCC" + msg
+	if _, err := ForgeOffline([]byte(longer), imported); err != ErrMissingIntermediate {
+		t.Errorf("ForgeOffline() error = %v, want %v", err, ErrMissingIntermediate)
+	}
+}
+
+func TestImportIntermediatesInvalid(t *testing.T) {
+	block := strings.Repeat("00", CipherBlockLen)
+	tests := []struct {
+		name string
+		data string
+	}{
+		{"Format", `{"format":"other","version":1,"block_size":16,"intermediates":{}}`},
+		{"Version", `{"format":"goracler-intermediates","version":2,"block_size":16,"intermediates":{}}`},
+		{"BlockSize", `{"format":"goracler-intermediates","version":1,"block_size":8,"intermediates":{}}`},
+		{"Length", `{"format":"goracler-intermediates","version":1,"block_size":16,"intermediates":{"` + block + `":"00"}}`},
+	}
+	for _, tt := range tests {
+		tt := tt
+		t.Run(tt.name, func(t *testing.T) {
+			c := NewCorpus()
+			err := c.ImportIntermediates(strings.NewReader(tt.data))
+			if !errors.Is(err, ErrInvalidIntermediates) {
+				t.Errorf("ImportIntermediates() error = %v, want %v", err, ErrInvalidIntermediates)
+			}
+			if c.Len() != 0 {
+				t.Errorf("got %d blocks imported from invalid data", c.Len())
+			}
+		})
+	}
+}
+
+func TestImportIntermediatesWithBlockLen(t *testing.T) {
+	data := `{"format":"goracler-intermediates","version":1,"block_size":8,"intermediates":{"0001020304050607":"0706050403020100"}}`
+	c := NewCorpus()
+	if err := c.ImportIntermediates(strings.NewReader(data), WithBlockLen(8)); err != nil {
+		t.Fatal(err)
+	}
+	var buf bytes.Buffer
+	if err := c.ExportIntermediates(&buf, WithBlockLen(8)); err != nil {
+		t.Fatal(err)
+	}
+	if err := NewCorpus().ImportIntermediates(bytes.NewReader(buf.Bytes()), WithBlockLen(8)); err != nil {
+		t.Errorf("ImportIntermediates() error = %v importing the exported data", err)
+	}
+}
@@ -0,0 +1,65 @@
+package goracler
+
+import (
+	"bufio"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"io"
+	"strings"
+	"sync"
+)
+
+// ErrNoAnswer is returned by a ManualOracle when the input ends before
+// answering a query.
+var ErrNoAnswer = errors.New("the input ended without answering the query")
+
+// ManualOracle is a Poracle that asks a person whether the pad of each
+// candidate is valid. For each query it writes the hex encoded candidate to
+// the output and reads the answer, "y" for a valid pad or "n" for an invalid
+// one, from the input, asking again when the answer is not valid. It's meant
+// for interactive and educational use, for instance, to understand how a new
+// target behaves while checking the candidates by hand using other tools, and
+// it's usually created with:
+//
+//	q := NewManualOracle(os.Stdin, os.Stdout)
+//
+// The queries are asked one at a time, so it's safe for concurrent use,
+// although the attack should be run using WithConcurrency(1) to ask for the
+// candidates in order.
+type ManualOracle struct {
+	mu  sync.Mutex
+	in  *bufio.Scanner
+	out io.Writer
+}
+
+// NewManualOracle returns a ManualOracle reading the answers from in and
+// writing the candidates to out.
+func NewManualOracle(in io.Reader, out io.Writer) *ManualOracle {
+	return &ManualOracle{in: bufio.NewScanner(in), out: out}
+}
+
+// Do implements the Poracle interface. It returns ErrNoAnswer if the input
+// ends before a valid answer is read.
+func (o *ManualOracle) Do(c []byte) (int, error) {
+	o.mu.Lock()
+	defer o.mu.Unlock()
+	if _, err := fmt.Fprintf(o.out, "%s\nvalid pad? [y/n]: ", hex.EncodeToString(c)); err != nil {
+		return 0, err
+	}
+	for o.in.Scan() {
+		switch strings.ToLower(strings.TrimSpace(o.in.Text())) {
+		case "y", "yes":
+			return 1, nil
+		case "n", "no":
+			return 0, nil
+		}
+		if _, err := fmt.Fprint(o.out, "please answer y or n: "); err != nil {
+			return 0, err
+		}
+	}
+	if err := o.in.Err(); err != nil {
+		return 0, err
+	}
+	return 0, ErrNoAnswer
+}
@@ -0,0 +1,33 @@
+package goracler
+
+import (
+	"bytes"
+	"strings"
+	"testing"
+)
+
+func TestManualOracle(t *testing.T) {
+	var out bytes.Buffer
+	q := NewManualOracle(strings.NewReader("y\nmaybe\n\n N \n"), &out)
+	c := []byte{0xca, 0xfe}
+	tests := []struct {
+		want    int
+		wantErr error
+	}{
+		{1, nil},
+		{0, nil},
+		{0, ErrNoAnswer},
+	}
+	for i, tt := range tests {
+		got, err := q.Do(c)
+		if got != tt.want || err != tt.wantErr {
+			t.Errorf("query %d: Do() = %d, %v, want %d, %v", i, got, err, tt.want, tt.wantErr)
+		}
+	}
+	if n := strings.Count(out.String(), "cafe\n"); n != 3 {
+		t.Errorf("got %d candidates written, want 3:\n%s", n, out.String())
+	}
+	if n := strings.Count(out.String(), "please answer y or n"); n != 2 {
+		t.Errorf("got %d retries, want 2:\n%s", n, out.String())
+	}
+}
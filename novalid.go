@@ -0,0 +1,61 @@
+package goracler
+
+import "fmt"
+
+// NoValidByteCause is the most likely cause of a NoValidByteError.
+type NoValidByteCause int
+
+const (
+	// TransientOracle means that the attack already recovered some bytes
+	// when it failed, so the setup is probably right and the oracle failed
+	// to report a valid pad, for instance because it's flaky or it's rate
+	// limiting the queries. Retrying, maybe using the WithPositionRetry or
+	// WithBlockRetries options, may help.
+	TransientOracle NoValidByteCause = iota
+	// SetupError means that the attack failed before recovering any byte,
+	// which suggests a problem with the setup of the attack, like a wrong
+	// block size, a wrong classifier or a ciphertext the target doesn't
+	// decrypt.
+	SetupError
+)
+
+func (c NoValidByteCause) String() string {
+	switch c {
+	case TransientOracle:
+		return "transient oracle failure"
+	case SetupError:
+		return "setup error"
+	}
+	return fmt.Sprintf("NoValidByteCause(%d)", int(c))
+}
+
+// NoValidByteError is returned by Decrypt when none of the possible values of
+// a byte produced a valid pad. Block is the index of the block of plaintext
+// and Position the position of the byte in the block. Likely is the most
+// likely cause of the error, inferred from how far the attack got: if the
+// first byte attacked failed it's SetupError, otherwise TransientOracle. It
+// wraps ErrNoValidByte, so errors.Is(err, ErrNoValidByte) reports it.
+type NoValidByteError struct {
+	Block    int
+	Position int
+	Likely   NoValidByteCause
+}
+
+func (e *NoValidByteError) Error() string {
+	return fmt.Sprintf("%s: byte %d of block %d, likely a %s", ErrNoValidByte, e.Position, e.Block, e.Likely)
+}
+
+// Unwrap returns ErrNoValidByte.
+func (e *NoValidByteError) Unwrap() error {
+	return ErrNoValidByte
+}
+
+// newNoValidByteError returns the NoValidByteError for a failure attacking
+// the block at the index i.
+func newNoValidByteError(i int, cfg *config) *NoValidByteError {
+	likely := TransientOracle
+	if cfg.bytesFound == 0 {
+		likely = SetupError
+	}
+	return &NoValidByteError{Block: i, Position: cfg.failedPos, Likely: likely}
+}
@@ -0,0 +1,769 @@
+package goracler
+
+import (
+	"context"
+	"io"
+	"regexp"
+	"time"
+
+	"golang.org/x/text/encoding"
+)
+
+// Option configures the attacks performed by the library.
+type Option func(*config)
+
+type config struct {
+	extractor   PayloadExtractor
+	concurrency int
+	fullMessage []byte
+	budget      *QueryBudget
+	report      *DecryptReport
+	order       []int
+	reverse     bool
+	maxBlocks   int
+	onBlock     BlockCallback
+	progress    ProgressCallback
+	speculation int
+	sink        io.Writer
+	forward     func([]byte) []byte
+	inverse     func([]byte) []byte
+
+	maxBlockQueries      int64
+	skipAlwaysValidCheck bool
+	blockCooldown        time.Duration
+	corpus               *Corpus
+	padding              PaddingScheme
+	sequential           bool
+	trace                *Trace
+	ctx                  context.Context
+	attackCtx            context.Context
+	sharedSem            chan struct{}
+	maxEntropy           float64
+	entropyAfter         int
+	lowMemory            bool
+	warmup               int
+	solver               Solver
+	validBlocks          int
+	onForged             BlockCallback
+	skipClassifierCheck  bool
+	tagLen               int
+	computeTag           func([]byte) []byte
+	hosts                *hostLimiter
+	blockRetries         int
+	decoy                *decoyOracle
+	controller           *Controller
+	healthInterval       time.Duration
+	byteOrder            BlockByteOrder
+	fixedLen             int
+	header               []byte
+	stopOnMatch          *regexp.Regexp
+	saltLen              int
+	histograms           bool
+	latency              func(Poracle) Poracle
+	positionRetries      int
+	skipUnrecoverable    bool
+	crib                 []byte
+	missingBlocks        int
+	aggregateErrors      bool
+	charset              encoding.Encoding
+	stats                *AttackStats
+	forgedPadding        []byte
+	ambiguityDepth       int
+	timingSamples        int
+	fallbackThreshold    float64
+	blockLen             int
+	maxInFlight          int
+
+	// bytesFound is the number of bytes recovered by the attack and
+	// failedPos the position of the last byte for which no valid value was
+	// found. They are used to build the NoValidByteError.
+	bytesFound int
+	failedPos  int
+
+	// block is the index of the block being attacked and blocks the number
+	// of blocks of the attack, reported to the ProgressCallback.
+	block, blocks int
+
+	// skippedBytes is the number of bytes at the start of the last block
+	// attacked that were skipped by WithSkipUnrecoverableBytes.
+	skippedBytes int
+}
+
+func newConfig(opts []Option) *config {
+	cfg := &config{
+		blockLen:    CipherBlockLen,
+		concurrency: MaxGoroutines,
+		padding:     PKCS7Padding,
+		solver:      CBCSolver,
+	}
+	for _, opt := range opts {
+		opt(cfg)
+	}
+	if cfg.forward != nil && cfg.fullMessage != nil {
+		cfg.fullMessage = cfg.forward(cfg.fullMessage)
+	}
+	return cfg
+}
+
+// validate checks the values set by the options are consistent.
+func (c *config) validate() error {
+	if c.blockLen < 2 {
+		return ErrInvalidBlockLen
+	}
+	if c.fullMessage != nil {
+		n := len(c.fullMessage)
+		if n < 2*c.blockLen || n%c.blockLen != 0 {
+			return ErrInvalidCiphertext
+		}
+	}
+	if c.fixedLen != 0 && (c.fixedLen < 2*c.blockLen || c.fixedLen%c.blockLen != 0) {
+		return ErrInvalidCiphertext
+	}
+	if c.validBlocks < 0 || c.validBlocks == 1 {
+		return ErrInvalidBlockCount
+	}
+	if c.saltLen < 0 || c.saltLen >= c.blockLen {
+		return ErrInvalidSaltLength
+	}
+	if c.padEnd() < 1 {
+		return ErrInvalidPaddingOffset
+	}
+	if c.lowMemory && c.sink == nil {
+		return ErrNoPlaintextSink
+	}
+	return nil
+}
+
+// oracle returns the oracle to be queried by the attack, that is, the oracle
+// passed in by the caller wrapped by the decorators defined by the options.
+func (c *config) oracle(q Poracle) Poracle {
+	var sem chan struct{}
+	if hq, ok := q.(HostPoracle); ok && c.hosts != nil {
+		sem = c.hosts.sem(hq.Host())
+	}
+	ctx := c.ctx
+	if ctx == nil {
+		ctx = c.attackContext()
+	}
+	if cq, ok := q.(ContextPoracle); ok {
+		q = contextOracle{cq, ctx}
+	}
+	if c.fallbackThreshold > 0 {
+		q = newFallbackOracle(q, c.fallbackThreshold)
+	}
+	if c.latency != nil {
+		q = c.latency(q)
+	}
+	if c.decoy != nil {
+		d := *c.decoy
+		d.Poracle = q
+		q = d
+	}
+	if sem != nil {
+		q = limitOracle{q, sem}
+	}
+	if c.sharedSem != nil {
+		q = limitOracle{q, c.sharedSem}
+	}
+	if c.header != nil {
+		q = headerOracle{q, c.header}
+	}
+	if c.computeTag != nil {
+		q = tagOracle{q, c.computeTag, c.blockLen}
+	}
+	if c.inverse != nil {
+		q = transformOracle{q, c.inverse}
+	}
+	if c.controller != nil {
+		q = controlledOracle{q, c.controller, ctx, c.attackContext()}
+	}
+	if c.stats != nil {
+		q = statsOracle{q, c.stats}
+	}
+	if c.budget != nil {
+		q = budgetOracle{q, c.budget, ErrQueryBudgetExhausted}
+	}
+	if c.attackCtx != nil {
+		q = cancelableOracle{q, c.attackCtx}
+	}
+	return q
+}
+
+// attackContext returns the context passed to DecryptContext or
+// EncryptContext, or context.Background() if the attack was started without
+// one.
+func (c *config) attackContext() context.Context {
+	if c.attackCtx == nil {
+		return context.Background()
+	}
+	return c.attackCtx
+}
+
+// blockOrder returns the indexes of the n blocks of plaintext in the order
+// they must be attacked.
+func (c *config) blockOrder(n int) ([]int, error) {
+	var order []int
+	if c.order != nil {
+		seen := make(map[int]bool, len(c.order))
+		for _, i := range c.order {
+			if i < 0 || i >= n || seen[i] {
+				return nil, ErrInvalidBlockOrder
+			}
+			seen[i] = true
+		}
+		order = append(order, c.order...)
+	} else {
+		for i := 0; i < n; i++ {
+			order = append(order, i)
+		}
+	}
+	if c.reverse {
+		for i, j := 0, len(order)-1; i < j; i, j = i+1, j-1 {
+			order[i], order[j] = order[j], order[i]
+		}
+	}
+	if c.maxBlocks > 0 && c.maxBlocks < len(order) {
+		order = order[:c.maxBlocks]
+	}
+	return order, nil
+}
+
+// buildProbe returns the ciphertext sent to the oracle to check if the
+// modified prev block produces a valid pad when decrypting the current block.
+func (c *config) buildProbe(prev, current []byte) []byte {
+	if c.fullMessage == nil && c.validBlocks == 0 && c.fixedLen == 0 {
+		// prev can be a slice of the ciphertext, so appending to it
+		// would overwrite the blocks after it.
+		probe := make([]byte, 0, len(prev)+len(current))
+		probe = append(probe, prev...)
+		return append(probe, current...)
+	}
+	// at is the index, counting the IV, of the block replaced by prev.
+	at := len(c.fullMessage)/c.blockLen - 2
+	switch {
+	case c.validBlocks > 0:
+		at = c.validBlocks - 2
+	case c.fullMessage == nil:
+		at = c.fixedLen/c.blockLen - 2
+	}
+	start, end := at*c.blockLen, (at+2)*c.blockLen
+	n := len(c.fullMessage)
+	if end > n {
+		n = end
+	}
+	probe := make([]byte, 0, n)
+	if start <= len(c.fullMessage) {
+		probe = append(probe, c.fullMessage[:start]...)
+	} else {
+		// The blocks before prev don't affect the pad checked, so they
+		// can have any value.
+		probe = append(probe, c.fullMessage...)
+		probe = append(probe, make([]byte, start-len(c.fullMessage))...)
+	}
+	probe = append(probe, prev...)
+	probe = append(probe, current...)
+	if end < len(c.fullMessage) {
+		probe = append(probe, c.fullMessage[end:]...)
+	}
+	return probe
+}
+
+// WithPayloadExtractor sets the PayloadExtractor applied by Decrypt to the
+// recovered plaintext before returning it. It isn't applied to the plaintext
+// recovered so far returned when the attack fails.
+func WithPayloadExtractor(e PayloadExtractor) Option {
+	return func(c *config) {
+		c.extractor = e
+	}
+}
+
+// WithBlockLen sets the length in bytes of the blocks of the cipher used by
+// the attack, so attacks on ciphers with different block lengths can run
+// concurrently. It overrides the value defined in the module var
+// CipherBlockLen, which is read when the options are applied. The attacks
+// return ErrInvalidBlockLen if n is lower than 2.
+func WithBlockLen(n int) Option {
+	return func(c *config) {
+		c.blockLen = n
+	}
+}
+
+// WithConcurrency sets the number of workers querying the oracle
+// concurrently. It overrides the value defined in the module var
+// MaxGoroutines. Values lower than 1 are ignored.
+func WithConcurrency(n int) Option {
+	return func(c *config) {
+		if n > 0 {
+			c.concurrency = n
+		}
+	}
+}
+
+// WithFullMessageProbe makes the attack send probes with the same length and
+// structure as the original ciphertext, for oracles that check the message
+// before checking the pad. Each probe is a copy of the original ciphertext
+// with its last two blocks replaced by the modified block and the block being
+// decrypted, so the oracle still checks the pad of the targeted block. The
+// original ciphertext must be block aligned and contain at least two blocks.
+func WithFullMessageProbe(original []byte) Option {
+	return func(c *config) {
+		c.fullMessage = original
+	}
+}
+
+// WithQueryBudget makes the attack consume a query from the given budget
+// before each query to the oracle. The same budget can be shared by several
+// attacks. When the budget is exhausted the attack stops, Decrypt returns the
+// plaintext of the blocks fully recovered so far together with the error
+// ErrQueryBudgetExhausted, and Encrypt returns only the error.
+func WithQueryBudget(b *QueryBudget) Option {
+	return func(c *config) {
+		c.budget = b
+	}
+}
+
+// WithMaxQueries limits the number of queries sent to the oracle by a single
+// attack to n. It's equivalent to WithQueryBudget(NewQueryBudget(n)).
+func WithMaxQueries(n int64) Option {
+	return func(c *config) {
+		c.budget = NewQueryBudget(n)
+	}
+}
+
+// WithReport makes Decrypt fill the given report with information about the
+// attack.
+func WithReport(r *DecryptReport) Option {
+	return func(c *config) {
+		c.report = r
+	}
+}
+
+// withMissingBlocks sets the number of blocks of plaintext missing at the end
+// of a truncated ciphertext.
+func withMissingBlocks(n int) Option {
+	return func(c *config) {
+		c.missingBlocks = n
+	}
+}
+
+// BlockCallback is called by Decrypt each time a block of plaintext is
+// recovered. The index is the position of the block in the plaintext, that
+// is, the block 0 is the one following the IV in the ciphertext.
+type BlockCallback func(index int, block []byte)
+
+// WithBlockCallback sets a callback called by Decrypt each time a block is
+// recovered, so the results can be streamed without waiting for the full
+// attack to finish.
+func WithBlockCallback(f BlockCallback) Option {
+	return func(c *config) {
+		c.onBlock = f
+	}
+}
+
+// ProgressCallback is called each time a byte of a block is recovered. The
+// blockIndex is the index of the block being attacked, as in BlockCallback,
+// totalBlocks the number of blocks of the attack and byteIndex the position
+// of the byte in the block. The bytes of a block are recovered from the last
+// one to the first.
+type ProgressCallback func(blockIndex, totalBlocks, byteIndex int)
+
+// WithProgressCallback sets a callback called by Decrypt and Encrypt each time
+// a byte is recovered, to report the progress of the attack. It's called from
+// the goroutine running the attack, never concurrently by the same attack, so
+// it only needs to be safe for concurrent use when shared by several attacks.
+// A byte can be reported more than once when WithSpeculation is used and the
+// search of the block is rolled back. The blocks not attacked, because they
+// are reused from a Corpus or from an identical pair of blocks, are not
+// reported.
+func WithProgressCallback(f ProgressCallback) Option {
+	return func(c *config) {
+		c.progress = f
+	}
+}
+
+// byteFound records that the byte at the position p of the block being
+// attacked was recovered.
+func (c *config) byteFound(p int) {
+	c.bytesFound++
+	if c.progress != nil {
+		c.progress(c.block, c.blocks, p)
+	}
+}
+
+// WithBlockOrder makes Decrypt attack only the given blocks of plaintext in
+// the given order. The block 0 is the one following the IV in the ciphertext.
+// Decrypt returns ErrInvalidBlockOrder if the indexes are out of range or
+// repeated.
+func WithBlockOrder(order []int) Option {
+	return func(c *config) {
+		c.order = order
+	}
+}
+
+// WithReverseBlocks makes Decrypt attack the blocks from the last one to the
+// first one. It's applied after WithBlockOrder.
+func WithReverseBlocks() Option {
+	return func(c *config) {
+		c.reverse = true
+	}
+}
+
+// BlockByteOrder defines the order of the bytes of plaintext within each
+// block.
+type BlockByteOrder int
+
+const (
+	// NormalByteOrder keeps the bytes of each block in the order they are
+	// recovered.
+	NormalByteOrder BlockByteOrder = iota
+	// ReversedByteOrder reverses the bytes of each block, so the byte at
+	// position i of a recovered block is placed at position
+	// blockLen-1-i of the block in the plaintext, where blockLen is the
+	// block length.
+	ReversedByteOrder
+)
+
+// WithBlockByteOrder sets the order of the bytes within each block of the
+// plaintext returned by Decrypt, for targets that store them in a different
+// order than the one they have when decrypted. The order is applied to each
+// block once recovered, before passing it to the BlockCallback and the
+// plaintext sink, and the blocks themselves keep their order. It doesn't
+// change the attack, so the pad is still expected at the end of the last
+// block as decrypted, and FinalPadLength of the DecryptReport refers to it.
+// The default is NormalByteOrder.
+func WithBlockByteOrder(o BlockByteOrder) Option {
+	return func(c *config) {
+		c.byteOrder = o
+	}
+}
+
+// WithMaxBlocks limits the number of blocks attacked by Decrypt to the first n
+// blocks in the attack order. For instance, using it together with
+// WithReverseBlocks and n equal to 1 only the last block is decrypted.
+func WithMaxBlocks(n int) Option {
+	return func(c *config) {
+		c.maxBlocks = n
+	}
+}
+
+// WithSpeculation makes the search of each byte finish as soon as a worker
+// finds a valid value, without waiting for the queries in flight of the other
+// workers. Those queries are checked in the background while the next bytes
+// are searched, and up to depth bytes can be pending of this confirmation. If
+// one of them finds a different valid value, the bytes searched from that
+// position on are rolled back and searched again. The last byte of each block
+// is never speculated, as it can legitimately have more than one valid value.
+//
+// It lowers the wall-clock time of attacks against slow oracles, specially
+// when the latency of the responses varies, in exchange of more queries in
+// flight at the same time and more queries in total when bytes are rolled
+// back.
+func WithSpeculation(depth int) Option {
+	return func(c *config) {
+		if depth > 0 {
+			c.speculation = depth
+		}
+	}
+}
+
+// WithPlaintextSink makes Decrypt write the plaintext to w as the blocks are
+// recovered, so it can be read before the attack finishes. The blocks are
+// always written in the order they have in the ciphertext, so a block
+// recovered before the previous ones is kept in memory until all of them are
+// written. The blocks not included in the attack, because of the
+// WithBlockOrder or WithMaxBlocks options, are skipped. If w has a Flush()
+// error method, like a bufio.Writer, it's called each time a block is written.
+// The blocks are written as recovered, that is, without applying the
+// PayloadExtractor.
+func WithPlaintextSink(w io.Writer) Option {
+	return func(c *config) {
+		c.sink = w
+	}
+}
+
+// WithForgedPadding makes Encrypt append the given bytes to the payload
+// instead of padding it using the PaddingScheme, so the forged plaintext ends
+// with exactly those bytes. The payload followed by the padding must be block
+// aligned, otherwise Encrypt returns ErrInvalidForgedPadding, and an empty
+// padding forges a block aligned payload as is. The padding is not checked, so
+// it must be valid for the target, for instance a valid PKCS#7 pad, or the
+// target will reject the forged ciphertext. It's useful for targets that
+// expect a specific pad length, for which the payload can be followed by
+// filler bytes and then the pad.
+func WithForgedPadding(padding []byte) Option {
+	return func(c *config) {
+		c.forgedPadding = padding
+		if c.forgedPadding == nil {
+			c.forgedPadding = []byte{}
+		}
+	}
+}
+
+// WithoutAlwaysValidCheck disables the check performed by Decrypt and Encrypt,
+// before starting the attack, to detect oracles that always report the pad as
+// valid, usually because of a misconfigured classifier. The check sends up to
+// three queries to the oracle.
+func WithoutAlwaysValidCheck() Option {
+	return func(c *config) {
+		c.skipAlwaysValidCheck = true
+	}
+}
+
+// WithMaxQueriesPerBlock limits the number of queries sent to the oracle to
+// decrypt each block. When a block can't be decrypted, because it exceeds the
+// limit or because no valid value is found for one of its bytes, Decrypt
+// doesn't return an error. Instead, all the bytes of the block are set to the
+// FailedBytePlaceholder, the index of the block is added to the FailedBlocks
+// of the DecryptReport, and the attack continues with the next block. It's
+// useful to recover as much plaintext as possible from flaky oracles.
+func WithMaxQueriesPerBlock(n int64) Option {
+	return func(c *config) {
+		c.maxBlockQueries = n
+	}
+}
+
+// WithPositionRetry makes the attack search again, up to n times, the value
+// of a byte when none of the candidates produced a valid pad, before giving
+// up. It helps to survive transient failures of the oracle, that make it
+// report a valid pad as invalid, at the cost of up to 256 extra queries per
+// retry.
+func WithPositionRetry(n int) Option {
+	return func(c *config) {
+		if n > 0 {
+			c.positionRetries = n
+		}
+	}
+}
+
+// WithSkipUnrecoverableBytes makes the attack, when skip is true, continue
+// instead of failing when no valid value is found for a byte of a block,
+// after the retries set by WithPositionRetry. The probes for each byte are
+// built using the values of the bytes after it in the block, so, when a
+// byte can't be recovered, none of the bytes before it in the same block can
+// be recovered either. Thus, the byte and all the bytes before it in the
+// block are set to the FailedBytePlaceholder, and the attack continues with
+// the next block. The bytes are not skipped while there are speculated bytes
+// pending confirmation, in which case the error is returned as usual.
+func WithSkipUnrecoverableBytes(skip bool) Option {
+	return func(c *config) {
+		c.skipUnrecoverable = skip
+	}
+}
+
+// WithBlockRetries makes Decrypt discard a block that looks corrupted and
+// attack it again from scratch, up to n times, before giving up. A block looks
+// corrupted when no valid value is found for one of its bytes, when the oracle
+// reports inconsistent results, or, for the last block when the default
+// Solver is used, when the recovered pad is not valid. A wrong result of the
+// oracle for a single byte poisons the rest of the bytes of the block, so
+// retrying the whole block makes the attack more reliable against flaky
+// oracles at the cost of the extra queries. Each attempt has its own budget of
+// queries when WithMaxQueriesPerBlock is used, and a block that exhausts its
+// budget is not retried. When all the attempts fail, the result of the last one
+// is handled as if there were no retries.
+func WithBlockRetries(n int) Option {
+	return func(c *config) {
+		if n > 0 {
+			c.blockRetries = n
+		}
+	}
+}
+
+// WithCiphertextTransform sets the transformation applied by the target to the
+// ciphertexts it receives before decrypting them, for instance, xoring them
+// with a fixed mask, and its inverse. The ciphertext passed to Decrypt, and
+// the one passed to WithFullMessageProbe, must be in the format sent to the
+// target, and are converted using the forward function before the attack.
+// Each probe is converted using the inverse function before sending it, so the
+// target decrypts exactly the probe built by the attack. The ciphertext
+// returned by Encrypt is also converted using the inverse function.
+//
+// Only transformations that are bijective, preserve the length of the
+// messages and are applied to the whole message received by the target are
+// compatible with the attack. Position dependent transformations, like a mask
+// applied from the first byte of the message, are supported as long as the
+// functions take the position into account.
+func WithCiphertextTransform(forward, inverse func([]byte) []byte) Option {
+	return func(c *config) {
+		c.forward = forward
+		c.inverse = inverse
+	}
+}
+
+// WithBlockCooldown makes Decrypt wait for the given duration between
+// decrypting consecutive blocks, producing bursts of queries separated by idle
+// periods instead of a continuous flow. It adds (n-1)*d to the duration of an
+// attack decrypting n blocks.
+func WithBlockCooldown(d time.Duration) Option {
+	return func(c *config) {
+		c.blockCooldown = d
+	}
+}
+
+// WithCorpus makes Decrypt and Encrypt look up each block of ciphertext in the
+// given Corpus before attacking it. The intermediate values of the blocks
+// found are taken from the corpus, without querying the oracle, and the ones
+// of the blocks recovered by the attack are added to it. The corpus must only
+// contain blocks encrypted with the same key as the ciphertext.
+func WithCorpus(c *Corpus) Option {
+	return func(cfg *config) {
+		cfg.corpus = c
+	}
+}
+
+// WithPaddingScheme sets the PaddingScheme checked by the oracle. By default
+// PKCS7Padding is used. The payload passed to Encrypt is padded using the
+// scheme. The PKCS7Extractor, and so DecryptUnpadded and DecryptStructured,
+// only support PKCS#7.
+func WithPaddingScheme(s PaddingScheme) Option {
+	return func(c *config) {
+		c.padding = s
+	}
+}
+
+// WithSequentialExecution makes the attacks try the candidates for each byte
+// one after another, in order, in the goroutine calling Decrypt or Encrypt,
+// instead of using a pool of goroutines. The queries sent to the oracle and
+// the messages logged are deterministic, which helps debugging, at the cost of
+// speed. The WithConcurrency and WithSpeculation options are ignored.
+func WithSequentialExecution() Option {
+	return func(c *config) {
+		c.sequential = true
+	}
+}
+
+// WithOracleContext sets the context passed to the DoCtx method of the oracles
+// implementing ContextPoracle, so they can access values scoped to the
+// attack, like a session. The attack itself doesn't watch the context: when it
+// is cancelled, the attack only stops if the oracle returns an error, for
+// instance, the one returned by the Err method of the context. By default
+// the context passed to DecryptContext or EncryptContext is used, or
+// context.Background() when the attack was started without one.
+func WithOracleContext(ctx context.Context) Option {
+	return func(c *config) {
+		c.ctx = ctx
+	}
+}
+
+// WithMaxInFlightBytes limits to n the bytes of recovered plaintext Decrypt
+// keeps in memory waiting to be written to the sink set with the
+// WithPlaintextSink option, which writes the blocks in the order they have in
+// the ciphertext. The blocks are attacked one at a time, so the bytes in
+// flight only grow when they are attacked in a different order, for instance
+// with WithReverseBlocks, and they are known before the attack starts:
+// if the order of the blocks requires more than n bytes, Decrypt returns
+// ErrInFlightBytesExceeded without sending any query. The block being written
+// counts toward the limit, so n must be at least the length of a block. It
+// has no effect without a sink and, unless WithLowMemory is also used, the
+// recovered plaintext is still kept in memory to be returned.
+func WithMaxInFlightBytes(n int) Option {
+	return func(c *config) {
+		c.maxInFlight = n
+	}
+}
+
+// WithLowMemory makes Decrypt write the recovered plaintext to the sink set
+// with the WithPlaintextSink option without keeping it in memory, so the
+// memory used by the attack doesn't grow with the length of the ciphertext.
+// Decrypt returns an empty string and the PayloadExtractor is not applied. As
+// the recovered blocks are discarded, the attack can't return the plaintext
+// recovered so far when it fails, for instance, when the query budget is
+// exhausted, so it can't be resumed from it. For the same reason, the pairs
+// of blocks repeated in the ciphertext are attacked every time they appear.
+// Decrypt returns ErrNoPlaintextSink if no sink is set.
+func WithLowMemory() Option {
+	return func(c *config) {
+		c.lowMemory = true
+	}
+}
+
+// WithValidBlockCount is a hint for oracles that only decrypt, and check the
+// pad of, the first n blocks of the messages, counting the IV. The probes are
+// built placing the modified block and the block being decrypted as the
+// blocks n-1 and n of the message, so the oracle checks the pad of the
+// targeted block. When used together with WithFullMessageProbe, the rest of
+// the blocks of the probes are taken from the original ciphertext, so the
+// probes keep its length. Otherwise, the probes contain only n blocks. n must
+// be at least 2, and Decrypt returns ErrInvalidBlockCount otherwise.
+func WithValidBlockCount(n int) Option {
+	return func(c *config) {
+		c.validBlocks = n
+	}
+}
+
+// WithStopOnMatch makes Decrypt stop the attack as soon as the plaintext
+// recovered matches the given regular expression, which is checked after
+// decrypting each block. The plaintext checked, and returned when it
+// matches, is the concatenation of the blocks recovered so far, so it's
+// partial and usually lacks the pad, thus the PayloadExtractor is not applied
+// to it. When the attack stops early, Decrypt returns no error and sets the
+// StoppedOnMatch field of the DecryptReport, if any. It has no effect when
+// WithLowMemory is used.
+func WithStopOnMatch(re *regexp.Regexp) Option {
+	return func(c *config) {
+		c.stopOnMatch = re
+	}
+}
+
+// WithPerBlockSalt makes Decrypt remove the first saltLen bytes of each block
+// of the recovered plaintext, for targets that prepend a salt to each block
+// before encrypting it. That is, each block of plaintext is expected to be:
+//
+//	salt (saltLen bytes) || data (block length - saltLen bytes)
+//
+// and the pad, if any, is at the end of the data of the last block. The salt
+// is removed once each block is recovered and after applying the
+// BlockByteOrder, so the BlockCallback and the plaintext sink receive only
+// the data. saltLen must be lower than the block length, and Decrypt returns
+// ErrInvalidSaltLength otherwise. The default is 0, that is, no salt.
+func WithPerBlockSalt(saltLen int) Option {
+	return func(c *config) {
+		c.saltLen = saltLen
+	}
+}
+
+// WithFixedLengthProbe makes the attack send probes with a length of
+// originalLen bytes, for oracles that reject the messages whose length
+// differs from the one of the original ciphertext. The modified block and the
+// block being decrypted are placed at the end of the probes, so the oracle
+// checks the pad of the targeted block, and the probes are filled with blocks
+// of zeros before them. The content of the filler blocks only changes the
+// plaintext of the first blocks of the message, so it's ignored by oracles
+// that only check the pad. For oracles that also check the content of the
+// message use WithFullMessageProbe instead. The originalLen must be block
+// aligned and contain at least two blocks, and Decrypt returns
+// ErrInvalidCiphertext otherwise. It's ignored when WithFullMessageProbe or
+// WithValidBlockCount are used.
+func WithFixedLengthProbe(originalLen int) Option {
+	return func(c *config) {
+		c.fixedLen = originalLen
+	}
+}
+
+// WithBlockForgedCallback sets a callback called by Encrypt each time a block
+// of the forged ciphertext is determined. As the blocks are forged backwards,
+// the callback is called in reverse order: first for the last block, which is
+// fixed before the attack starts, and last for the IV. The index is the
+// position of the block in the forged ciphertext, including the IV, that is,
+// the IV is the block 0 and the last block of a ciphertext of n blocks is the
+// block n-1. The blocks are passed before applying the inverse function set
+// with the WithCiphertextTransform option.
+func WithBlockForgedCallback(f BlockCallback) Option {
+	return func(c *config) {
+		c.onForged = f
+	}
+}
+
+// WithClassifierCheck enables or disables the check performed by Decrypt,
+// before starting the attack, to detect oracles returning the same result
+// regardless of the pad, usually because of a misconfigured classifier. The
+// check is enabled by default and sends two queries to the oracle: the last
+// two blocks of the ciphertext, which must have a valid pad, and the same
+// blocks with the pad made invalid. Decrypt returns ErrDegenerateClassifier
+// if the oracle returns the same result for both. It must be disabled to
+// decrypt ciphertexts without a valid pad.
+func WithClassifierCheck(enabled bool) Option {
+	return func(c *config) {
+		c.skipClassifierCheck = !enabled
+	}
+}
@@ -0,0 +1,233 @@
+package oracle
+
+import (
+	"bytes"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io/ioutil"
+	"net/http"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/manelmontilla/goracler"
+)
+
+// ErrInvalidOracleConfig is returned by LoadOracle when the config file
+// doesn't describe a valid oracle.
+var ErrInvalidOracleConfig = errors.New("invalid oracle config")
+
+// OracleConfig describes an HTTPOracle. It's the schema of the JSON files read
+// by LoadOracle, where the names of the fields are the ones in the json tags.
+// The durations are strings in the format accepted by time.ParseDuration,
+// like "1.5s".
+type OracleConfig struct {
+	// Method and URL are the method and the url of the requests. The method
+	// defaults to GET.
+	Method string `json:"method"`
+	URL    string `json:"url"`
+	// Body is the template of the body of the requests.
+	Body string `json:"body"`
+	// Headers are added to the requests, see WithHeader.
+	Headers map[string]string `json:"headers"`
+	// QueryParams are set in the url of the requests, see WithQueryParam.
+	QueryParams map[string]string `json:"query_params"`
+	// Encoding is the encoding of the candidates: hex, the default, base64,
+	// base64url, rawbase64url, base32 or base58.
+	Encoding string `json:"encoding"`
+	// JSONPath, if set, is the path of the field of the body where the
+	// candidate is injected, see WithJSONFieldPath. Otherwise, the
+	// Placeholder must appear in the url or in the body.
+	JSONPath string `json:"json_path"`
+	// Classifier decides if the pad of the candidates is valid.
+	Classifier ClassifierConfig `json:"classifier"`
+	// NoRedirects makes the oracle not follow redirects, see
+	// WithoutRedirects.
+	NoRedirects bool `json:"no_redirects"`
+	// AllowRemote allows targets that are not loopback addresses, see
+	// AllowRemote.
+	AllowRemote bool `json:"allow_remote"`
+	// Timeout is the timeout of each request. Zero means no timeout.
+	Timeout string `json:"timeout"`
+	// Retries and RetryBackoff configure the retries of the requests, see
+	// WithRetry.
+	Retries      int    `json:"retries"`
+	RetryBackoff string `json:"retry_backoff"`
+	// RateLimit is the maximum number of requests per second sent to the
+	// target. Zero means no limit.
+	RateLimit float64 `json:"rate_limit"`
+}
+
+// ClassifierConfig describes the Classifier of an OracleConfig. Type selects
+// the Classifier and the rest of fields are its parameters:
+//   - "status": StatusClassifier with the statuses in Valid.
+//   - "body": BodyClassifier with the string in InvalidPad.
+//   - "redirect": RedirectClassifier with ValidOnRedirect. It requires
+//     NoRedirects.
+type ClassifierConfig struct {
+	Type            string `json:"type"`
+	Valid           []int  `json:"valid"`
+	InvalidPad      string `json:"invalid_pad"`
+	ValidOnRedirect bool   `json:"valid_on_redirect"`
+}
+
+// configEncoders are the encoders that can be selected in an OracleConfig.
+var configEncoders = map[string]Encoder{
+	"hex":          HexEncoder,
+	"base64":       Base64Encoder,
+	"base64url":    Base64URLEncoder,
+	"rawbase64url": RawBase64URLEncoder,
+	"base32":       Base32Encoder,
+	"base58":       Base58Encoder,
+}
+
+// LoadOracle returns the oracle described by the JSON file at the given path,
+// with the schema defined by OracleConfig. Unknown fields are rejected. It
+// returns an error wrapping ErrInvalidOracleConfig if the config is not valid,
+// or the error returned by NewHTTPOracle.
+func LoadOracle(path string) (goracler.Poracle, error) {
+	data, err := ioutil.ReadFile(path)
+	if err != nil {
+		return nil, err
+	}
+	d := json.NewDecoder(bytes.NewReader(data))
+	d.DisallowUnknownFields()
+	var cfg OracleConfig
+	if err := d.Decode(&cfg); err != nil {
+		return nil, fmt.Errorf("%w: %s: %v", ErrInvalidOracleConfig, path, err)
+	}
+	return cfg.Oracle()
+}
+
+// Oracle returns the oracle described by the config.
+func (c OracleConfig) Oracle() (goracler.Poracle, error) {
+	invalid := func(format string, v ...interface{}) error {
+		return fmt.Errorf("%w: %s", ErrInvalidOracleConfig, fmt.Sprintf(format, v...))
+	}
+	if c.URL == "" {
+		return nil, invalid("url is required")
+	}
+	method := c.Method
+	if method == "" {
+		method = http.MethodGet
+	}
+	enc := HexEncoder
+	if c.Encoding != "" {
+		e, ok := configEncoders[strings.ToLower(c.Encoding)]
+		if !ok {
+			return nil, invalid("unknown encoding %q", c.Encoding)
+		}
+		enc = e
+	}
+	classify, err := c.Classifier.classifier()
+	if err != nil {
+		return nil, err
+	}
+	if c.Classifier.Type == "redirect" && !c.NoRedirects {
+		return nil, invalid("the redirect classifier requires no_redirects")
+	}
+	timeout, err := parseConfigDuration("timeout", c.Timeout)
+	if err != nil {
+		return nil, err
+	}
+	backoff, err := parseConfigDuration("retry_backoff", c.RetryBackoff)
+	if err != nil {
+		return nil, err
+	}
+	if c.Retries < 0 {
+		return nil, invalid("retries can't be negative")
+	}
+	if c.RateLimit < 0 {
+		return nil, invalid("rate_limit can't be negative")
+	}
+
+	opts := []HTTPOption{
+		WithEncoder(enc),
+		AllowRemote(c.AllowRemote),
+		WithClient(&http.Client{Timeout: timeout}),
+	}
+	for name, value := range c.Headers {
+		opts = append(opts, WithHeader(name, value))
+	}
+	for name, value := range c.QueryParams {
+		opts = append(opts, WithQueryParam(name, value))
+	}
+	if c.JSONPath != "" {
+		opts = append(opts, WithJSONFieldPath(c.JSONPath, enc))
+	}
+	if c.NoRedirects {
+		opts = append(opts, WithoutRedirects())
+	}
+	if c.Retries > 0 {
+		opts = append(opts, WithRetry(c.Retries, backoff))
+	}
+	o, err := NewHTTPOracle(method, c.URL, c.Body, classify, opts...)
+	if err != nil {
+		return nil, err
+	}
+	if c.RateLimit > 0 {
+		return &rateLimitedOracle{HTTPOracle: o, interval: time.Duration(float64(time.Second) / c.RateLimit)}, nil
+	}
+	return o, nil
+}
+
+// classifier returns the Classifier described by the config.
+func (c ClassifierConfig) classifier() (Classifier, error) {
+	switch c.Type {
+	case "status":
+		if len(c.Valid) == 0 {
+			return nil, fmt.Errorf("%w: the status classifier requires at least one valid status", ErrInvalidOracleConfig)
+		}
+		return StatusClassifier(c.Valid...), nil
+	case "body":
+		if c.InvalidPad == "" {
+			return nil, fmt.Errorf("%w: the body classifier requires invalid_pad", ErrInvalidOracleConfig)
+		}
+		return BodyClassifier(c.InvalidPad), nil
+	case "redirect":
+		return RedirectClassifier(c.ValidOnRedirect), nil
+	case "":
+		return nil, fmt.Errorf("%w: classifier type is required", ErrInvalidOracleConfig)
+	default:
+		return nil, fmt.Errorf("%w: unknown classifier type %q", ErrInvalidOracleConfig, c.Type)
+	}
+}
+
+// parseConfigDuration parses the duration of the field with the given name,
+// which can be empty.
+func parseConfigDuration(field, v string) (time.Duration, error) {
+	if v == "" {
+		return 0, nil
+	}
+	d, err := time.ParseDuration(v)
+	if err != nil || d < 0 {
+		return 0, fmt.Errorf("%w: invalid %s %q", ErrInvalidOracleConfig, field, v)
+	}
+	return d, nil
+}
+
+// rateLimitedOracle is an HTTPOracle that spaces the requests so no more than
+// one is sent per interval.
+type rateLimitedOracle struct {
+	*HTTPOracle
+	interval time.Duration
+
+	mu   sync.Mutex
+	next time.Time
+}
+
+func (o *rateLimitedOracle) Do(c []byte) (int, error) {
+	o.mu.Lock()
+	now := time.Now()
+	if o.next.Before(now) {
+		o.next = now
+	}
+	wait := o.next.Sub(now)
+	o.next = o.next.Add(o.interval)
+	o.mu.Unlock()
+	if wait > 0 {
+		time.Sleep(wait)
+	}
+	return o.HTTPOracle.Do(c)
+}
@@ -0,0 +1,105 @@
+package oracle
+
+import (
+	"errors"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/manelmontilla/goracler"
+)
+
+func writeConfig(t *testing.T, dir, name, content string) string {
+	path := filepath.Join(dir, name)
+	if err := ioutil.WriteFile(path, []byte(content), 0600); err != nil {
+		t.Fatal(err)
+	}
+	return path
+}
+
+func TestLoadOracle(t *testing.T) {
+	srv := newTestServer()
+	defer srv.Close()
+	dir, err := ioutil.TempDir("", "oracle")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+
+	path := writeConfig(t, dir, "oracle.json", `{
+		"method": "GET",
+		"url": "`+srv.URL+`/?c={{ciphertext}}",
+		"headers": {"User-Agent": "goracler"},
+		"encoding": "hex",
+		"classifier": {"type": "body", "invalid_pad": "invalid padding"},
+		"timeout": "5s",
+		"retries": 2,
+		"retry_backoff": "10ms",
+		"rate_limit": 10000
+	}`)
+	q, err := LoadOracle(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, ok := q.(*rateLimitedOracle); !ok {
+		t.Errorf("LoadOracle() returned a %T, want a rate limited oracle", q)
+	}
+	msg := "Hello world"
+	got, err := goracler.DecryptUnpadded(testCiphertext(t, msg), q, nopLogger{})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if got != msg {
+		t.Errorf("DecryptUnpadded() = %q, want %q", got, msg)
+	}
+
+	invalid := map[string]string{
+		"UnknownField":         `{"url": "http://127.0.0.1/?c={{ciphertext}}", "classifier": {"type": "status", "valid": [200]}, "proxy": "x"}`,
+		"MissingURL":           `{"classifier": {"type": "status", "valid": [200]}}`,
+		"UnknownEncoding":      `{"url": "http://127.0.0.1/?c={{ciphertext}}", "encoding": "rot13", "classifier": {"type": "status", "valid": [200]}}`,
+		"MissingClassifier":    `{"url": "http://127.0.0.1/?c={{ciphertext}}"}`,
+		"EmptyStatuses":        `{"url": "http://127.0.0.1/?c={{ciphertext}}", "classifier": {"type": "status"}}`,
+		"RedirectWithoutFlag":  `{"url": "http://127.0.0.1/?c={{ciphertext}}", "classifier": {"type": "redirect"}}`,
+		"InvalidTimeout":       `{"url": "http://127.0.0.1/?c={{ciphertext}}", "classifier": {"type": "status", "valid": [200]}, "timeout": "5 seconds"}`,
+		"NegativeRateLimit":    `{"url": "http://127.0.0.1/?c={{ciphertext}}", "classifier": {"type": "status", "valid": [200]}, "rate_limit": -1}`,
+		"MalformedJSONSyntax":  `{"url": `,
+		"MismatchedFieldTypes": `{"url": "http://127.0.0.1/?c={{ciphertext}}", "classifier": {"type": "status", "valid": "200"}}`,
+	}
+	for name, content := range invalid {
+		name, content := name, content
+		t.Run(name, func(t *testing.T) {
+			if _, err := LoadOracle(writeConfig(t, dir, name+".json", content)); !errors.Is(err, ErrInvalidOracleConfig) {
+				t.Errorf("LoadOracle() error = %v, want %v", err, ErrInvalidOracleConfig)
+			}
+		})
+	}
+	if _, err := LoadOracle(writeConfig(t, dir, "remote.json", `{"url": "http://192.0.2.1/?c={{ciphertext}}", "classifier": {"type": "status", "valid": [200]}}`)); err != ErrRemoteTarget {
+		t.Errorf("LoadOracle() error = %v, want %v", err, ErrRemoteTarget)
+	}
+}
+
+func TestRateLimitedOracle(t *testing.T) {
+	srv := newTestServer()
+	defer srv.Close()
+	o, err := OracleConfig{
+		URL:        srv.URL + "/?c=" + Placeholder,
+		Classifier: ClassifierConfig{Type: "body", InvalidPad: "invalid padding"},
+		RateLimit:  50,
+	}.Oracle()
+	if err != nil {
+		t.Fatal(err)
+	}
+	c := testCiphertext(t, "Hello world")
+	start := time.Now()
+	for i := 0; i < 4; i++ {
+		if _, err := o.Do(c); err != nil {
+			t.Fatal(err)
+		}
+	}
+	// The first request is sent right away and the rest 20ms apart.
+	if elapsed := time.Since(start); elapsed < 60*time.Millisecond {
+		t.Errorf("4 requests took %s, want at least 60ms", elapsed)
+	}
+}
@@ -0,0 +1,68 @@
+package oracle
+
+import (
+	"encoding/base32"
+	"encoding/base64"
+	"encoding/hex"
+	"fmt"
+	"strings"
+
+	"github.com/manelmontilla/goracler"
+)
+
+// HexEncoder encodes the candidates in hex. It's the default Encoder of the
+// oracles in this package.
+func HexEncoder(candidate []byte) string {
+	return hex.EncodeToString(candidate)
+}
+
+// Base64Encoder encodes the candidates using standard base64.
+func Base64Encoder(candidate []byte) string {
+	return base64.StdEncoding.EncodeToString(candidate)
+}
+
+// Base64URLEncoder encodes the candidates using the URL safe base64 alphabet.
+func Base64URLEncoder(candidate []byte) string {
+	return base64.URLEncoding.EncodeToString(candidate)
+}
+
+// RawBase64URLEncoder encodes the candidates using the URL safe base64
+// alphabet without padding.
+func RawBase64URLEncoder(candidate []byte) string {
+	return base64.RawURLEncoding.EncodeToString(candidate)
+}
+
+// Base64AlphabetEncoder returns an Encoder that encodes the candidates in
+// base64 using the given 64 characters alphabet. When pad is false the
+// encoded values are not padded.
+func Base64AlphabetEncoder(alphabet string, pad bool) (Encoder, error) {
+	if len(alphabet) != 64 {
+		return nil, fmt.Errorf("invalid alphabet length %d, it must be 64", len(alphabet))
+	}
+	if strings.ContainsAny(alphabet, "\r\n=") {
+		return nil, fmt.Errorf("the alphabet can't contain new lines or the padding character")
+	}
+	seen := make(map[rune]bool)
+	for _, r := range alphabet {
+		if r > 0x7f || seen[r] {
+			return nil, fmt.Errorf("invalid alphabet character %q", r)
+		}
+		seen[r] = true
+	}
+	enc := base64.NewEncoding(alphabet)
+	if !pad {
+		enc = enc.WithPadding(base64.NoPadding)
+	}
+	return enc.EncodeToString, nil
+}
+
+// Base32Encoder encodes the candidates using standard base32.
+func Base32Encoder(candidate []byte) string {
+	return base32.StdEncoding.EncodeToString(candidate)
+}
+
+// Base58Encoder encodes the candidates using base58 with the Bitcoin
+// alphabet.
+func Base58Encoder(candidate []byte) string {
+	return goracler.Base58Codec.Encode(candidate)
+}
@@ -0,0 +1,79 @@
+package oracle
+
+import (
+	"encoding/base32"
+	"encoding/base64"
+	"encoding/hex"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/manelmontilla/goracler"
+	"github.com/manelmontilla/goracler/crypto"
+)
+
+const customAlphabet = "zyxwvutsrqponmlkjihgfedcbaZYXWVUTSRQPONMLKJIHGFEDCBA9876543210-_"
+
+func TestEncoders(t *testing.T) {
+	custom, err := Base64AlphabetEncoder(customAlphabet, false)
+	if err != nil {
+		t.Fatal(err)
+	}
+	tests := []struct {
+		name   string
+		encode Encoder
+		decode func(string) ([]byte, error)
+	}{
+		{"Hex", HexEncoder, hex.DecodeString},
+		{"Base64", Base64Encoder, base64.StdEncoding.DecodeString},
+		{"Base64URL", Base64URLEncoder, base64.URLEncoding.DecodeString},
+		{"RawBase64URL", RawBase64URLEncoder, base64.RawURLEncoding.DecodeString},
+		{"Base32", Base32Encoder, base32.StdEncoding.DecodeString},
+		{"Base58", Base58Encoder, goracler.Base58Codec.Decode},
+		{
+			"CustomAlphabet",
+			custom,
+			base64.NewEncoding(customAlphabet).WithPadding(base64.NoPadding).DecodeString,
+		},
+	}
+	for _, tt := range tests {
+		tt := tt
+		t.Run(tt.name, func(t *testing.T) {
+			srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+				c, err := tt.decode(r.URL.Query().Get("c"))
+				if err != nil {
+					w.WriteHeader(http.StatusBadRequest)
+					return
+				}
+				_, err = crypto.CBCDecrypt(testKey, hex.EncodeToString(c))
+				if err != nil {
+					w.WriteHeader(http.StatusInternalServerError)
+				}
+			}))
+			defer srv.Close()
+			q, err := NewHTTPOracle(http.MethodGet, srv.URL+"/?c="+Placeholder, "",
+				StatusClassifier(http.StatusOK), WithEncoder(tt.encode))
+			if err != nil {
+				t.Fatal(err)
+			}
+			msg := "Hello world"
+			got, err := goracler.DecryptUnpadded(testCiphertext(t, msg), q, nopLogger{})
+			if err != nil {
+				t.Fatal(err)
+			}
+			if got != msg {
+				t.Errorf("DecryptUnpadded() = %q, want %q", got, msg)
+			}
+		})
+	}
+}
+
+func TestBase64AlphabetEncoderInvalidAlphabet(t *testing.T) {
+	alphabets := []string{"abc", strings.Repeat("a", 64), customAlphabet[:63] + "="}
+	for _, a := range alphabets {
+		if _, err := Base64AlphabetEncoder(a, true); err == nil {
+			t.Errorf("Base64AlphabetEncoder(%q) returned no error", a)
+		}
+	}
+}
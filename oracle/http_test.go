@@ -0,0 +1,378 @@
+package oracle
+
+import (
+	"encoding/hex"
+	"net"
+	"net/http"
+	"net/http/httptest"
+	"sync"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/manelmontilla/goracler"
+	"github.com/manelmontilla/goracler/crypto"
+)
+
+const testKey = "ee581a043ac19191c7d551710bab13a9"
+
+// newTestServer returns a server acting as a padding oracle that reads the
+// hex encoded ciphertext from the query param c and answers with a 500 status
+// code when the pad is invalid.
+func newTestServer() *httptest.Server {
+	return httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		_, err := crypto.CBCDecrypt(testKey, r.URL.Query().Get("c"))
+		if err == crypto.ErrInvalidPad {
+			w.WriteHeader(http.StatusInternalServerError)
+			w.Write([]byte("invalid padding"))
+			return
+		}
+		if err != nil {
+			w.WriteHeader(http.StatusBadRequest)
+			return
+		}
+		w.Write([]byte("ok"))
+	}))
+}
+
+func testCiphertext(t testing.TB, msg string) []byte {
+	ct, err := crypto.CBCEncrypt("91db4482c4ffa9858338ab0e98ddf96c", testKey, msg)
+	if err != nil {
+		t.Fatal(err)
+	}
+	c, err := hex.DecodeString(ct)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return c
+}
+
+type nopLogger struct{}
+
+func (nopLogger) Printf(format string, v ...interface{}) {}
+
+func TestHTTPOracle(t *testing.T) {
+	srv := newTestServer()
+	defer srv.Close()
+	tests := []struct {
+		name     string
+		classify Classifier
+	}{
+		{
+			name:     "DecryptsUsingStatusClassifier",
+			classify: StatusClassifier(http.StatusOK),
+		},
+		{
+			name:     "DecryptsUsingBodyClassifier",
+			classify: BodyClassifier("invalid padding"),
+		},
+	}
+	for _, tt := range tests {
+		tt := tt
+		t.Run(tt.name, func(t *testing.T) {
+			q, err := NewHTTPOracle(http.MethodGet, srv.URL+"/?c="+Placeholder, "", tt.classify)
+			if err != nil {
+				t.Fatal(err)
+			}
+			msg := "Hello world"
+			got, err := goracler.DecryptUnpadded(testCiphertext(t, msg), q, nopLogger{})
+			if err != nil {
+				t.Fatal(err)
+			}
+			if got != msg {
+				t.Errorf("DecryptUnpadded() = %q, want %q", got, msg)
+			}
+		})
+	}
+}
+
+func TestNewHTTPOracleNoPlaceholder(t *testing.T) {
+	_, err := NewHTTPOracle(http.MethodGet, "http://localhost/", "", StatusClassifier(http.StatusOK))
+	if err != ErrNoPlaceholder {
+		t.Errorf("NewHTTPOracle() error = %v, want %v", err, ErrNoPlaceholder)
+	}
+}
+
+func TestHTTPOracleConnectionReset(t *testing.T) {
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		_, err := crypto.CBCDecrypt(testKey, r.URL.Query().Get("c"))
+		if err == nil {
+			w.Write([]byte("ok"))
+			return
+		}
+		conn, _, err := w.(http.Hijacker).Hijack()
+		if err != nil {
+			return
+		}
+		conn.(*net.TCPConn).SetLinger(0)
+		conn.Close()
+	}))
+	defer srv.Close()
+	q, err := NewHTTPOracle(http.MethodGet, srv.URL+"/?c="+Placeholder, "", StatusClassifier(http.StatusOK))
+	if err != nil {
+		t.Fatal(err)
+	}
+	msg := "Hello world"
+	got, err := goracler.DecryptUnpadded(testCiphertext(t, msg), goracler.ErrorAsInvalid(q, IsConnectionReset), nopLogger{})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if got != msg {
+		t.Errorf("DecryptUnpadded() = %q, want %q", got, msg)
+	}
+}
+
+func TestRedirectClassifier(t *testing.T) {
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.URL.Path == "/home" {
+			w.Write([]byte("welcome"))
+			return
+		}
+		_, err := crypto.CBCDecrypt(testKey, r.URL.Query().Get("c"))
+		if err == crypto.ErrInvalidPad {
+			w.Write([]byte("invalid session"))
+			return
+		}
+		http.Redirect(w, r, "/home", http.StatusFound)
+	}))
+	defer srv.Close()
+	tests := []struct {
+		name    string
+		opts    []HTTPOption
+		wantErr error
+	}{
+		{
+			name: "DecryptsWithoutFollowingRedirects",
+			opts: []HTTPOption{WithoutRedirects()},
+		},
+		{
+			name:    "FailsWhenFollowingRedirects",
+			wantErr: ErrRedirectFollowed,
+		},
+	}
+	for _, tt := range tests {
+		tt := tt
+		t.Run(tt.name, func(t *testing.T) {
+			q, err := NewHTTPOracle(http.MethodGet, srv.URL+"/?c="+Placeholder, "", RedirectClassifier(true), tt.opts...)
+			if err != nil {
+				t.Fatal(err)
+			}
+			msg := "Hello world"
+			got, err := goracler.DecryptUnpadded(testCiphertext(t, msg), q, nopLogger{})
+			if err != tt.wantErr {
+				t.Fatalf("DecryptUnpadded() error = %v, want %v", err, tt.wantErr)
+			}
+			if err == nil && got != msg {
+				t.Errorf("DecryptUnpadded() = %q, want %q", got, msg)
+			}
+		})
+	}
+}
+
+func TestHTTPOracleCacheBusting(t *testing.T) {
+	var mu sync.Mutex
+	seen := make(map[string]bool)
+	requests := 0
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		mu.Lock()
+		seen[r.URL.Query().Get("nocache")] = true
+		requests++
+		mu.Unlock()
+		_, err := crypto.CBCDecrypt(testKey, r.URL.Query().Get("c"))
+		if err != nil {
+			w.WriteHeader(http.StatusInternalServerError)
+		}
+	}))
+	defer srv.Close()
+	q, err := NewHTTPOracle(http.MethodGet, srv.URL+"/?c="+Placeholder, "",
+		StatusClassifier(http.StatusOK), WithCacheBusting("nocache"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	msg := "Hello world"
+	got, err := goracler.DecryptUnpadded(testCiphertext(t, msg), q, nopLogger{})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if got != msg {
+		t.Errorf("DecryptUnpadded() = %q, want %q", got, msg)
+	}
+	mu.Lock()
+	defer mu.Unlock()
+	if seen[""] || len(seen) != requests {
+		t.Errorf("got %d different cache busting values for %d requests, want one per request", len(seen), requests)
+	}
+}
+
+func TestHTTPOracleWithQueryParam(t *testing.T) {
+	// The target decrypts the messages with the key of the tenant set in
+	// the request.
+	keys := map[string]string{
+		"acme":   testKey,
+		"globex": "2b7e151628aed2a6abf7158809cf4f3c",
+	}
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		key, ok := keys[r.URL.Query().Get("tenant")]
+		if !ok {
+			w.WriteHeader(http.StatusBadRequest)
+			return
+		}
+		if _, err := crypto.CBCDecrypt(key, r.URL.Query().Get("c")); err != nil {
+			w.WriteHeader(http.StatusInternalServerError)
+		}
+	}))
+	defer srv.Close()
+	msg := "Hello world"
+	ct, err := crypto.CBCEncrypt("91db4482c4ffa9858338ab0e98ddf96c", keys["globex"], msg)
+	if err != nil {
+		t.Fatal(err)
+	}
+	c, err := hex.DecodeString(ct)
+	if err != nil {
+		t.Fatal(err)
+	}
+	q, err := NewHTTPOracle(http.MethodGet, srv.URL+"/?tenant=acme&c="+Placeholder, "",
+		StatusClassifier(http.StatusOK), WithQueryParam("tenant", "globex"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	got, err := goracler.DecryptUnpadded(c, q, nopLogger{})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if got != msg {
+		t.Errorf("DecryptUnpadded() = %q, want %q", got, msg)
+	}
+}
+
+func TestHTTPOracleWithRetry(t *testing.T) {
+	tests := []struct {
+		name         string
+		responses    []int
+		retryAfter   string
+		wantRequests int64
+		wantValid    int
+		minElapsed   time.Duration
+	}{
+		{
+			name:         "HonorsRetryAfterOn429",
+			responses:    []int{http.StatusTooManyRequests},
+			retryAfter:   "1",
+			wantRequests: 2,
+			wantValid:    1,
+			minElapsed:   time.Second,
+		},
+		{
+			name:         "BacksOffExponentiallyOn5xx",
+			responses:    []int{http.StatusServiceUnavailable, http.StatusBadGateway},
+			wantRequests: 3,
+			wantValid:    1,
+			minElapsed:   30 * time.Millisecond,
+		},
+		{
+			name:         "DoesNotRetryInvalidPads",
+			responses:    []int{http.StatusInternalServerError},
+			wantRequests: 1,
+		},
+		{
+			name:         "ClassifiesTheLastResponseAfterTheRetries",
+			responses:    []int{429, 429, 429, 429},
+			retryAfter:   "0",
+			wantRequests: 3,
+		},
+	}
+	for _, tt := range tests {
+		tt := tt
+		t.Run(tt.name, func(t *testing.T) {
+			var requests int64
+			srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+				n := atomic.AddInt64(&requests, 1)
+				if int(n) <= len(tt.responses) {
+					if tt.retryAfter != "" {
+						w.Header().Set("Retry-After", tt.retryAfter)
+					}
+					w.WriteHeader(tt.responses[n-1])
+				}
+			}))
+			defer srv.Close()
+			q, err := NewHTTPOracle(http.MethodGet, srv.URL+"/?c="+Placeholder, "",
+				StatusClassifier(http.StatusOK), WithRetry(2, 10*time.Millisecond))
+			if err != nil {
+				t.Fatal(err)
+			}
+			start := time.Now()
+			got, err := q.Do([]byte("candidate"))
+			if err != nil {
+				t.Fatal(err)
+			}
+			if elapsed := time.Since(start); elapsed < tt.minElapsed {
+				t.Errorf("Do() took %s, want at least %s", elapsed, tt.minElapsed)
+			}
+			if got != tt.wantValid {
+				t.Errorf("Do() = %d, want %d", got, tt.wantValid)
+			}
+			if n := atomic.LoadInt64(&requests); n != tt.wantRequests {
+				t.Errorf("got %d requests, want %d", n, tt.wantRequests)
+			}
+		})
+	}
+}
+
+func TestParseRetryAfter(t *testing.T) {
+	now := time.Date(2020, 1, 1, 0, 0, 0, 0, time.UTC)
+	tests := []struct {
+		v      string
+		want   time.Duration
+		wantOK bool
+	}{
+		{"120", 2 * time.Minute, true},
+		{now.Add(30 * time.Second).Format(http.TimeFormat), 30 * time.Second, true},
+		{now.Add(-time.Minute).Format(http.TimeFormat), 0, true},
+		{"-1", 0, false},
+		{"soon", 0, false},
+		{"", 0, false},
+	}
+	for _, tt := range tests {
+		got, ok := parseRetryAfter(tt.v, now)
+		if got != tt.want || ok != tt.wantOK {
+			t.Errorf("parseRetryAfter(%q) = %s, %t, want %s, %t", tt.v, got, ok, tt.want, tt.wantOK)
+		}
+	}
+}
+
+func TestHTTPOracleAllowRemote(t *testing.T) {
+	tests := []struct {
+		name    string
+		url     string
+		opts    []HTTPOption
+		wantErr error
+	}{
+		{
+			name: "AcceptsLoopbackIP",
+			url:  "http://127.0.0.1:8080/?c=" + Placeholder,
+		},
+		{
+			name: "AcceptsLocalhost",
+			url:  "http://localhost/?c=" + Placeholder,
+		},
+		{
+			name:    "RejectsRemoteIP",
+			url:     "http://192.0.2.1/?c=" + Placeholder,
+			wantErr: ErrRemoteTarget,
+		},
+		{
+			name: "AcceptsRemoteIPWhenAllowed",
+			url:  "http://192.0.2.1/?c=" + Placeholder,
+			opts: []HTTPOption{AllowRemote(true)},
+		},
+	}
+	for _, tt := range tests {
+		tt := tt
+		t.Run(tt.name, func(t *testing.T) {
+			_, err := NewHTTPOracle(http.MethodGet, tt.url, "", StatusClassifier(http.StatusOK), tt.opts...)
+			if err != tt.wantErr {
+				t.Errorf("NewHTTPOracle() error = %v, want %v", err, tt.wantErr)
+			}
+		})
+	}
+}
@@ -0,0 +1,152 @@
+package oracle
+
+import (
+	"bytes"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"strconv"
+	"strings"
+)
+
+// ErrInvalidJSONPath is returned by NewHTTPOracle when the JSON path set with
+// WithJSONFieldPath is malformed or can't be set in the body template.
+var ErrInvalidJSONPath = errors.New("invalid JSON path")
+
+// WithJSONFieldPath makes the oracle set the candidate, encoded with the given
+// Encoder, as the value of the field at the given path of the body template,
+// which must be a JSON document. The path is a dot separated list of object
+// keys, where the elements of an array are selected with their index in
+// brackets, like in "auth.tokens[0].value", or as a key, like in
+// "auth.tokens.0.value". The last key of the path is added if the object
+// doesn't contain it, but all the other keys and the indexes must exist in the
+// template. When this option is set the body template doesn't need to contain
+// the Placeholder. If e is nil the Encoder of the oracle is used.
+//
+// For each query the template, after replacing the placeholders, is parsed,
+// the field is set and the document is serialized again, so the keys of the
+// objects are sorted and the whitespace of the template is not preserved.
+func WithJSONFieldPath(path string, e Encoder) HTTPOption {
+	return func(o *HTTPOracle) {
+		o.jsonPath = path
+		o.jsonEncode = e
+	}
+}
+
+// jsonPathSegment is a segment of a JSON path, either an object key or an
+// array index.
+type jsonPathSegment struct {
+	key   string
+	index int
+	isIdx bool
+}
+
+func (s jsonPathSegment) String() string {
+	if s.isIdx {
+		return fmt.Sprintf("[%d]", s.index)
+	}
+	return s.key
+}
+
+// parseJSONPath returns the segments of the given path.
+func parseJSONPath(path string) ([]jsonPathSegment, error) {
+	var segs []jsonPathSegment
+	for _, part := range strings.Split(path, ".") {
+		key := part
+		var indexes []int
+		if i := strings.IndexByte(part, '['); i >= 0 {
+			key = part[:i]
+			rest := part[i:]
+			for rest != "" {
+				end := strings.IndexByte(rest, ']')
+				if rest[0] != '[' || end < 0 {
+					return nil, fmt.Errorf("%w: malformed index in %q", ErrInvalidJSONPath, part)
+				}
+				n, err := strconv.Atoi(rest[1:end])
+				if err != nil || n < 0 {
+					return nil, fmt.Errorf("%w: invalid index in %q", ErrInvalidJSONPath, part)
+				}
+				indexes = append(indexes, n)
+				rest = rest[end+1:]
+			}
+		}
+		if key == "" && len(indexes) == 0 {
+			return nil, fmt.Errorf("%w: empty key in %q", ErrInvalidJSONPath, path)
+		}
+		if key != "" {
+			segs = append(segs, jsonPathSegment{key: key})
+		}
+		for _, n := range indexes {
+			segs = append(segs, jsonPathSegment{index: n, isIdx: true})
+		}
+	}
+	return segs, nil
+}
+
+// setJSONField returns the JSON document doc with the field at the given path
+// set to value.
+func setJSONField(doc string, path []jsonPathSegment, value string) (string, error) {
+	d := json.NewDecoder(strings.NewReader(doc))
+	// Numbers are decoded as json.Number so they are serialized as they are
+	// in the template.
+	d.UseNumber()
+	var root interface{}
+	if err := d.Decode(&root); err != nil {
+		return "", err
+	}
+	root, err := setJSONPath(root, path, value)
+	if err != nil {
+		return "", err
+	}
+	var b bytes.Buffer
+	e := json.NewEncoder(&b)
+	e.SetEscapeHTML(false)
+	if err := e.Encode(root); err != nil {
+		return "", err
+	}
+	return strings.TrimSuffix(b.String(), "\n"), nil
+}
+
+// setJSONPath sets the value at the given path of the node and returns the
+// node.
+func setJSONPath(node interface{}, path []jsonPathSegment, value string) (interface{}, error) {
+	if len(path) == 0 {
+		return value, nil
+	}
+	seg := path[0]
+	switch n := node.(type) {
+	case map[string]interface{}:
+		if seg.isIdx {
+			return nil, fmt.Errorf("%w: index %s applied to an object", ErrInvalidJSONPath, seg)
+		}
+		child, ok := n[seg.key]
+		if !ok && len(path) > 1 {
+			return nil, fmt.Errorf("%w: key %q not found", ErrInvalidJSONPath, seg.key)
+		}
+		v, err := setJSONPath(child, path[1:], value)
+		if err != nil {
+			return nil, err
+		}
+		n[seg.key] = v
+		return n, nil
+	case []interface{}:
+		i := seg.index
+		if !seg.isIdx {
+			var err error
+			if i, err = strconv.Atoi(seg.key); err != nil {
+				return nil, fmt.Errorf("%w: key %q applied to an array", ErrInvalidJSONPath, seg.key)
+			}
+		}
+		if i < 0 || i >= len(n) {
+			return nil, fmt.Errorf("%w: index %d out of range", ErrInvalidJSONPath, i)
+		}
+		v, err := setJSONPath(n[i], path[1:], value)
+		if err != nil {
+			return nil, err
+		}
+		n[i] = v
+		return n, nil
+	default:
+		return nil, fmt.Errorf("%w: the parent of %s is not an object or an array", ErrInvalidJSONPath, seg)
+	}
+}
@@ -0,0 +1,86 @@
+package oracle
+
+import (
+	"encoding/base64"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/manelmontilla/goracler"
+	"github.com/manelmontilla/goracler/crypto"
+)
+
+func TestHTTPOracleWithJSONFieldPath(t *testing.T) {
+	// The server reads the base64 encoded ciphertext from a nested field
+	// and rejects the requests where the rest of the document doesn't
+	// match the template.
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		var body struct {
+			Version json.Number `json:"version"`
+			Auth    struct {
+				User   string `json:"user"`
+				Tokens []struct {
+					Kind  string `json:"kind"`
+					Value string `json:"value"`
+				} `json:"tokens"`
+			} `json:"auth"`
+		}
+		if err := json.NewDecoder(r.Body).Decode(&body); err != nil {
+			w.WriteHeader(http.StatusBadRequest)
+			return
+		}
+		tokens := body.Auth.Tokens
+		if body.Version != "2.0" || body.Auth.User != "manel" || len(tokens) != 2 || tokens[0].Value != "unused" {
+			w.WriteHeader(http.StatusBadRequest)
+			return
+		}
+		c, err := base64.StdEncoding.DecodeString(tokens[1].Value)
+		if err != nil {
+			w.WriteHeader(http.StatusBadRequest)
+			return
+		}
+		_, err = crypto.CBCDecrypt(testKey, hex.EncodeToString(c))
+		if err == crypto.ErrInvalidPad {
+			w.WriteHeader(http.StatusInternalServerError)
+			return
+		}
+		if err != nil {
+			w.WriteHeader(http.StatusBadRequest)
+			return
+		}
+	}))
+	defer srv.Close()
+
+	template := `{"version": 2.0, "auth": {"user": "manel", "tokens": [{"kind": "a", "value": "unused"}, {"kind": "b"}]}}`
+	for _, path := range []string{"auth.tokens[1].value", "auth.tokens.1.value"} {
+		path := path
+		t.Run(path, func(t *testing.T) {
+			q, err := NewHTTPOracle(http.MethodPost, srv.URL, template, StatusClassifier(http.StatusOK),
+				WithJSONFieldPath(path, Base64Encoder))
+			if err != nil {
+				t.Fatal(err)
+			}
+			msg := "Hello world"
+			got, err := goracler.DecryptUnpadded(testCiphertext(t, msg), q, nopLogger{})
+			if err != nil {
+				t.Fatal(err)
+			}
+			if got != msg {
+				t.Errorf("DecryptUnpadded() = %q, want %q", got, msg)
+			}
+		})
+	}
+}
+
+func TestWithJSONFieldPathInvalid(t *testing.T) {
+	template := `{"auth": {"tokens": [{"value": ""}]}}`
+	for _, path := range []string{"auth..value", "auth.tokens[x]", "auth.tokens[1].value", "auth.missing.value", "auth[0]", "auth.tokens.value"} {
+		_, err := NewHTTPOracle(http.MethodPost, "http://127.0.0.1/", template, StatusClassifier(http.StatusOK), WithJSONFieldPath(path, nil))
+		if !errors.Is(err, ErrInvalidJSONPath) {
+			t.Errorf("NewHTTPOracle() with path %q error = %v, want %v", path, err, ErrInvalidJSONPath)
+		}
+	}
+}
@@ -0,0 +1,49 @@
+package oracle
+
+import "fmt"
+
+// Field defines a placeholder replaced by a range of bytes of the candidate
+// ciphertext, so different parts of the candidate can be injected in
+// different parts of the request. For instance, the IV and the ciphertext in
+// different query params.
+type Field struct {
+	// Placeholder is the string replaced by the encoded bytes.
+	Placeholder string
+	// Start is the index of the first byte of the range. Negative values
+	// are relative to the end of the candidate.
+	Start int
+	// End is the index after the last byte of the range. Zero means the end
+	// of the candidate and negative values are relative to it.
+	End int
+}
+
+// FieldLayout defines how the candidate ciphertext is split in fields. The
+// Placeholder is always replaced by the full candidate.
+type FieldLayout []Field
+
+// WithFieldLayout sets the layout used to inject the candidates in the
+// requests.
+func WithFieldLayout(l FieldLayout) HTTPOption {
+	return func(o *HTTPOracle) {
+		o.layout = l
+	}
+}
+
+// values returns the encoded value of each placeholder for the candidate c.
+func (l FieldLayout) values(c []byte, encode Encoder) (map[string]string, error) {
+	values := map[string]string{Placeholder: encode(c)}
+	for _, f := range l {
+		start, end := f.Start, f.End
+		if start < 0 {
+			start += len(c)
+		}
+		if end <= 0 {
+			end += len(c)
+		}
+		if start < 0 || end > len(c) || start > end {
+			return nil, fmt.Errorf("range [%d:%d] of field %s out of bounds for a candidate of length %d", f.Start, f.End, f.Placeholder, len(c))
+		}
+		values[f.Placeholder] = encode(c[start:end])
+	}
+	return values, nil
+}
@@ -0,0 +1,45 @@
+package oracle
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/manelmontilla/goracler"
+	"github.com/manelmontilla/goracler/crypto"
+)
+
+func TestHTTPOracleFieldLayout(t *testing.T) {
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		q := r.URL.Query()
+		_, err := crypto.CBCDecrypt(testKey, q.Get("iv")+q.Get("ct"))
+		if err != nil {
+			w.WriteHeader(http.StatusInternalServerError)
+		}
+	}))
+	defer srv.Close()
+	layout := FieldLayout{
+		{Placeholder: "{{iv}}", Start: 0, End: goracler.CipherBlockLen},
+		{Placeholder: "{{ct}}", Start: goracler.CipherBlockLen},
+	}
+	q, err := NewHTTPOracle(http.MethodGet, srv.URL+"/?iv={{iv}}&ct={{ct}}", "",
+		StatusClassifier(http.StatusOK), WithFieldLayout(layout))
+	if err != nil {
+		t.Fatal(err)
+	}
+	msg := "Somewhere in la Mancha"
+	got, err := goracler.DecryptUnpadded(testCiphertext(t, msg), q, nopLogger{})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if got != msg {
+		t.Errorf("DecryptUnpadded() = %q, want %q", got, msg)
+	}
+}
+
+func TestFieldLayoutOutOfBounds(t *testing.T) {
+	layout := FieldLayout{{Placeholder: "{{x}}", Start: 4, End: 40}}
+	if _, err := layout.values(make([]byte, 32), HexEncoder); err == nil {
+		t.Error("values() returned no error for an out of bounds field")
+	}
+}
@@ -0,0 +1,123 @@
+package oracle
+
+import (
+	"bufio"
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/hex"
+	"net"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/manelmontilla/goracler"
+	"github.com/manelmontilla/goracler/crypto"
+)
+
+var macKey = []byte("client side mac key")
+
+func testMAC(c []byte) []byte {
+	mac := hmac.New(sha256.New, macKey)
+	mac.Write(c)
+	return mac.Sum(nil)
+}
+
+// checkMAC returns the response of a target checking the MAC of the hex
+// encoded ciphertext c before checking its pad.
+func checkMAC(c, mac string) string {
+	ct, err := hex.DecodeString(c)
+	if err != nil {
+		return "error"
+	}
+	m, err := hex.DecodeString(mac)
+	if err != nil || !hmac.Equal(m, testMAC(ct)) {
+		return "invalid mac"
+	}
+	if _, err := crypto.CBCDecrypt(testKey, c); err != nil {
+		return "invalid pad"
+	}
+	return "ok"
+}
+
+func TestHTTPOracleMAC(t *testing.T) {
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		res := checkMAC(r.URL.Query().Get("c"), r.Header.Get("X-MAC"))
+		if res != "ok" {
+			w.WriteHeader(http.StatusForbidden)
+		}
+		w.Write([]byte(res))
+	}))
+	defer srv.Close()
+	tests := []struct {
+		name    string
+		opts    []HTTPOption
+		wantErr bool
+	}{
+		{
+			name: "DecryptsWithTheMAC",
+			opts: []HTTPOption{WithHeader("X-MAC", MACPlaceholder), WithMAC(testMAC)},
+		},
+		{
+			name:    "FailsWithoutTheMAC",
+			opts:    []HTTPOption{WithHeader("X-MAC", MACPlaceholder)},
+			wantErr: true,
+		},
+	}
+	for _, tt := range tests {
+		tt := tt
+		t.Run(tt.name, func(t *testing.T) {
+			q, err := NewHTTPOracle(http.MethodGet, srv.URL+"/?c="+Placeholder, "",
+				StatusClassifier(http.StatusOK), tt.opts...)
+			if err != nil {
+				t.Fatal(err)
+			}
+			msg := "Hello world"
+			got, err := goracler.DecryptUnpadded(testCiphertext(t, msg), q, nopLogger{})
+			if (err != nil) != tt.wantErr {
+				t.Fatalf("DecryptUnpadded() error = %v, want error %t", err, tt.wantErr)
+			}
+			if err == nil && got != msg {
+				t.Errorf("DecryptUnpadded() = %q, want %q", got, msg)
+			}
+		})
+	}
+}
+
+func TestTCPLineOracleMAC(t *testing.T) {
+	l, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer l.Close()
+	go func() {
+		for {
+			conn, err := l.Accept()
+			if err != nil {
+				return
+			}
+			go func(conn net.Conn) {
+				defer conn.Close()
+				line, err := bufio.NewReader(conn).ReadString('\n')
+				if err != nil {
+					return
+				}
+				parts := strings.SplitN(strings.TrimSpace(line), " ", 2)
+				if len(parts) != 2 {
+					conn.Write([]byte("error\n"))
+					return
+				}
+				conn.Write([]byte(checkMAC(parts[0], parts[1]) + "\n"))
+			}(conn)
+		}
+	}()
+	q := NewTCPLineOracle(l.Addr().String(), okClassifier, WithTCPMAC(testMAC, " "))
+	msg := "Hello world"
+	got, err := goracler.DecryptUnpadded(testCiphertext(t, msg), q, nopLogger{})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if got != msg {
+		t.Errorf("DecryptUnpadded() = %q, want %q", got, msg)
+	}
+}
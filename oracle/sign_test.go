@@ -0,0 +1,84 @@
+package oracle
+
+import (
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/hex"
+	"io/ioutil"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"testing"
+
+	"github.com/manelmontilla/goracler"
+	"github.com/manelmontilla/goracler/crypto"
+)
+
+func hmacSign(key []byte) func([]byte) string {
+	return func(body []byte) string {
+		mac := hmac.New(sha256.New, key)
+		mac.Write(body)
+		return hex.EncodeToString(mac.Sum(nil))
+	}
+}
+
+func TestHTTPOracleRequestSigner(t *testing.T) {
+	signKey := []byte("client side key")
+	sign := hmacSign(signKey)
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		body, err := ioutil.ReadAll(r.Body)
+		if err != nil {
+			w.WriteHeader(http.StatusBadRequest)
+			return
+		}
+		if !hmac.Equal([]byte(r.Header.Get("X-Signature")), []byte(sign(body))) {
+			w.WriteHeader(http.StatusForbidden)
+			return
+		}
+		values, err := url.ParseQuery(string(body))
+		if err != nil {
+			w.WriteHeader(http.StatusBadRequest)
+			return
+		}
+		if _, err := crypto.CBCDecrypt(testKey, values.Get("token")); err != nil {
+			w.WriteHeader(http.StatusInternalServerError)
+		}
+	}))
+	defer srv.Close()
+	tests := []struct {
+		name    string
+		opts    []HTTPOption
+		wantErr bool
+	}{
+		{
+			name: "DecryptsSignedRequests",
+			opts: []HTTPOption{
+				WithHeader("X-Signature", SignaturePlaceholder),
+				WithRequestSigner(sign),
+			},
+		},
+		{
+			name:    "FailsWithoutSigner",
+			opts:    []HTTPOption{WithHeader("X-Signature", SignaturePlaceholder)},
+			wantErr: true,
+		},
+	}
+	for _, tt := range tests {
+		tt := tt
+		t.Run(tt.name, func(t *testing.T) {
+			opts := append([]HTTPOption{WithHeader("Content-Type", "application/x-www-form-urlencoded")}, tt.opts...)
+			q, err := NewHTTPOracle(http.MethodPost, srv.URL, "token="+Placeholder, StatusClassifier(http.StatusOK), opts...)
+			if err != nil {
+				t.Fatal(err)
+			}
+			msg := "Hello world"
+			got, err := goracler.DecryptUnpadded(testCiphertext(t, msg), q, nopLogger{})
+			if (err != nil) != tt.wantErr {
+				t.Fatalf("DecryptUnpadded() error = %v, wantErr %v", err, tt.wantErr)
+			}
+			if err == nil && got != msg {
+				t.Errorf("DecryptUnpadded() = %q, want %q", got, msg)
+			}
+		})
+	}
+}
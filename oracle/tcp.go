@@ -0,0 +1,161 @@
+package oracle
+
+import (
+	"bufio"
+	"errors"
+	"io"
+	"net"
+	"strings"
+	"syscall"
+	"time"
+)
+
+// LineClassifier decides if the line returned by a TCP oracle means that the
+// pad of the candidate ciphertext was valid. It returns an error if the line
+// is not related to the pad.
+type LineClassifier func(line string) (bool, error)
+
+// TCPOption configures a TCPLineOracle.
+type TCPOption func(*TCPLineOracle)
+
+// TCPLineOracle queries an oracle exposed through a line based TCP protocol.
+// For each query it opens a new connection, unless a pool is set with
+// WithTCPConnectionPool, writes the encoded candidate followed by a new line
+// and reads a line with the response. It's safe for concurrent use.
+type TCPLineOracle struct {
+	addr     string
+	classify LineClassifier
+	encode   Encoder
+	timeout  time.Duration
+	traffic  *trafficCounter
+	mac      func(c []byte) []byte
+	macSep   string
+
+	poolSize      int
+	pipelineDepth int
+	pool          *tcpPool
+}
+
+// NewTCPLineOracle returns a TCPLineOracle connecting to the given address. By
+// default the candidates are hex encoded and the connections have a timeout
+// of 30 seconds.
+func NewTCPLineOracle(addr string, classify LineClassifier, opts ...TCPOption) *TCPLineOracle {
+	o := &TCPLineOracle{
+		addr:     addr,
+		classify: classify,
+		encode:   HexEncoder,
+		timeout:  30 * time.Second,
+	}
+	for _, opt := range opts {
+		opt(o)
+	}
+	if o.poolSize > 0 {
+		depth := o.pipelineDepth
+		if depth < 1 {
+			depth = 1
+		}
+		o.pool = newTCPPool(addr, o.timeout, o.poolSize, depth)
+	}
+	return o
+}
+
+// WithTCPEncoder sets the Encoder used to write the candidates.
+func WithTCPEncoder(e Encoder) TCPOption {
+	return func(o *TCPLineOracle) {
+		o.encode = e
+	}
+}
+
+// WithTCPMAC sets a function computing the MAC of each candidate ciphertext,
+// for targets that check a MAC of the ciphertext before decrypting it. The MAC
+// is encoded using the Encoder of the oracle and written after the candidate,
+// separated by sep. It requires knowing the key of the MAC and the exact bytes
+// it covers.
+func WithTCPMAC(mac func(c []byte) []byte, sep string) TCPOption {
+	return func(o *TCPLineOracle) {
+		o.mac = mac
+		o.macSep = sep
+	}
+}
+
+// WithTimeout sets the maximum duration of each query, including connecting
+// to the oracle.
+func WithTimeout(d time.Duration) TCPOption {
+	return func(o *TCPLineOracle) {
+		o.timeout = d
+	}
+}
+
+// Host implements the goracler.HostPoracle interface. It returns the address
+// the oracle connects to.
+func (o *TCPLineOracle) Host() string {
+	return o.addr
+}
+
+// Do implements the goracler.Poracle interface.
+func (o *TCPLineOracle) Do(c []byte) (int, error) {
+	req := o.encode(c)
+	if o.mac != nil {
+		req += o.macSep + o.encode(o.mac(c))
+	}
+	req += "\n"
+	var line string
+	var err error
+	if o.pool != nil {
+		line, err = o.pool.roundTrip(req)
+	} else {
+		line, err = o.roundTrip(req)
+	}
+	if err != nil {
+		return 0, err
+	}
+	o.traffic.add(len(req), len(line))
+	valid, err := o.classify(strings.TrimRight(line, "\r\n"))
+	if err != nil {
+		return 0, err
+	}
+	if !valid {
+		return 0, nil
+	}
+	return 1, nil
+}
+
+// roundTrip sends the request through a new connection and returns the line
+// received in response.
+func (o *TCPLineOracle) roundTrip(req string) (string, error) {
+	conn, err := net.DialTimeout("tcp", o.addr, o.timeout)
+	if err != nil {
+		return "", err
+	}
+	defer conn.Close()
+	if err := conn.SetDeadline(time.Now().Add(o.timeout)); err != nil {
+		return "", err
+	}
+	if _, err := io.WriteString(conn, req); err != nil {
+		return "", err
+	}
+	return bufio.NewReader(conn).ReadString('\n')
+}
+
+// Close closes the connections of the pool set with WithTCPConnectionPool,
+// if any.
+func (o *TCPLineOracle) Close() error {
+	if o.pool == nil {
+		return nil
+	}
+	return o.pool.close()
+}
+
+// IsConnectionReset returns true if the error was caused by the oracle
+// closing, or resetting, the connection before sending a response. Some
+// oracles signal an invalid pad in this way. The recommended setup to attack
+// them is to wrap the HTTPOracle or the TCPLineOracle using:
+//
+//	goracler.ErrorAsInvalid(q, oracle.IsConnectionReset)
+//
+// As both oracles use a new connection, or discard the broken ones, after a
+// connection is reset the next query transparently reconnects.
+func IsConnectionReset(err error) bool {
+	return errors.Is(err, syscall.ECONNRESET) || errors.Is(err, syscall.EPIPE) ||
+		errors.Is(err, io.EOF) || errors.Is(err, io.ErrUnexpectedEOF)
+}
@@ -0,0 +1,180 @@
+package oracle
+
+import (
+	"bufio"
+	"net"
+	"strings"
+	"testing"
+
+	"github.com/manelmontilla/goracler"
+	"github.com/manelmontilla/goracler/crypto"
+)
+
+// newTestTCPServer returns the address of a line based TCP padding oracle. It
+// reads lines with the hex encoded ciphertexts and answers "ok" for each one
+// with a valid pad. When the pad is invalid it answers "invalid" or, if rst is
+// true, resets the connection.
+func newTestTCPServer(t testing.TB, rst bool) (string, func()) {
+	l, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatal(err)
+	}
+	go func() {
+		for {
+			conn, err := l.Accept()
+			if err != nil {
+				return
+			}
+			go func(conn net.Conn) {
+				defer conn.Close()
+				r := bufio.NewReader(conn)
+				for {
+					line, err := r.ReadString('\n')
+					if err != nil {
+						return
+					}
+					_, err = crypto.CBCDecrypt(testKey, strings.TrimSpace(line))
+					if err == nil {
+						conn.Write([]byte("ok\n"))
+						continue
+					}
+					if rst {
+						conn.(*net.TCPConn).SetLinger(0)
+						return
+					}
+					conn.Write([]byte("invalid\n"))
+				}
+			}(conn)
+		}
+	}()
+	return l.Addr().String(), func() { l.Close() }
+}
+
+func okClassifier(line string) (bool, error) {
+	return line == "ok", nil
+}
+
+func TestTCPLineOracle(t *testing.T) {
+	tests := []struct {
+		name string
+		rst  bool
+		wrap func(goracler.Poracle) goracler.Poracle
+	}{
+		{
+			name: "DecryptsUsingLineResponses",
+			wrap: func(q goracler.Poracle) goracler.Poracle { return q },
+		},
+		{
+			name: "DecryptsWhenOracleResetsTheConnection",
+			rst:  true,
+			wrap: func(q goracler.Poracle) goracler.Poracle {
+				return goracler.ErrorAsInvalid(q, IsConnectionReset)
+			},
+		},
+	}
+	for _, tt := range tests {
+		tt := tt
+		t.Run(tt.name, func(t *testing.T) {
+			addr, stop := newTestTCPServer(t, tt.rst)
+			defer stop()
+			q := tt.wrap(NewTCPLineOracle(addr, okClassifier))
+			msg := "Hello world"
+			got, err := goracler.DecryptUnpadded(testCiphertext(t, msg), q, nopLogger{})
+			if err != nil {
+				t.Fatal(err)
+			}
+			if got != msg {
+				t.Errorf("DecryptUnpadded() = %q, want %q", got, msg)
+			}
+		})
+	}
+}
+
+func TestTCPLineOracleWithConnectionPool(t *testing.T) {
+	tests := []struct {
+		name string
+		opts []TCPOption
+	}{
+		{
+			name: "DecryptsThroughPooledConnections",
+			opts: []TCPOption{WithTCPConnectionPool(4)},
+		},
+		{
+			name: "DecryptsPipeliningCandidates",
+			opts: []TCPOption{WithTCPConnectionPool(2), WithTCPPipelining(8)},
+		},
+	}
+	for _, tt := range tests {
+		tt := tt
+		t.Run(tt.name, func(t *testing.T) {
+			addr, stop := newTestTCPServer(t, false)
+			defer stop()
+			q := NewTCPLineOracle(addr, okClassifier, tt.opts...)
+			defer q.Close()
+			msg := "Somewhere in la Mancha, in a place whose name"
+			got, err := goracler.DecryptUnpadded(testCiphertext(t, msg), q, nopLogger{}, goracler.WithConcurrency(8))
+			if err != nil {
+				t.Fatal(err)
+			}
+			if got != msg {
+				t.Errorf("DecryptUnpadded() = %q, want %q", got, msg)
+			}
+		})
+	}
+}
+
+func TestTCPLineOracleWithConnectionPoolReset(t *testing.T) {
+	addr, stop := newTestTCPServer(t, true)
+	defer stop()
+	q := NewTCPLineOracle(addr, okClassifier, WithTCPConnectionPool(4))
+	defer q.Close()
+	msg := "Hello world"
+	got, err := goracler.DecryptUnpadded(testCiphertext(t, msg), goracler.ErrorAsInvalid(q, IsConnectionReset), nopLogger{})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if got != msg {
+		t.Errorf("DecryptUnpadded() = %q, want %q", got, msg)
+	}
+}
+
+func BenchmarkTCPLineOracle(b *testing.B) {
+	benchs := []struct {
+		name string
+		opts []TCPOption
+	}{
+		{name: "ConnectionPerCandidate"},
+		{name: "Pool", opts: []TCPOption{WithTCPConnectionPool(8)}},
+		{name: "PipelinedPool", opts: []TCPOption{WithTCPConnectionPool(2), WithTCPPipelining(4)}},
+	}
+	c := testCiphertext(b, "Hello world")
+	addr, stop := newTestTCPServer(b, false)
+	defer stop()
+	for _, bb := range benchs {
+		bb := bb
+		b.Run(bb.name, func(b *testing.B) {
+			q := NewTCPLineOracle(addr, okClassifier, bb.opts...)
+			defer q.Close()
+			b.SetParallelism(8)
+			b.ResetTimer()
+			b.RunParallel(func(pb *testing.PB) {
+				for pb.Next() {
+					if _, err := q.Do(c); err != nil {
+						b.Error(err)
+						return
+					}
+				}
+			})
+		})
+	}
+}
+
+func TestTCPLineOracleResetWithoutErrorAsInvalid(t *testing.T) {
+	addr, stop := newTestTCPServer(t, true)
+	defer stop()
+	q := NewTCPLineOracle(addr, okClassifier)
+	_, err := goracler.Decrypt(testCiphertext(t, "Hello world"), q, nopLogger{})
+	if !IsConnectionReset(err) {
+		t.Errorf("Decrypt() error = %v, want a connection reset error", err)
+	}
+}
@@ -0,0 +1,229 @@
+package oracle
+
+import (
+	"bufio"
+	"errors"
+	"io"
+	"net"
+	"sync"
+	"time"
+)
+
+// errUnexpectedLine is returned when the oracle sends a line that doesn't
+// answer any of the pipelined requests.
+var errUnexpectedLine = errors.New("unexpected line received from the oracle")
+
+// WithTCPConnectionPool makes the oracle reuse up to size connections, each
+// of them sending several candidates one after the other, instead of opening
+// a connection per candidate. It requires an oracle that keeps the
+// connections open after answering a line. The pool should have the same
+// size as the concurrency of the attack, as a query waits for a free
+// connection when all of them are busy.
+func WithTCPConnectionPool(size int) TCPOption {
+	return func(o *TCPLineOracle) {
+		if size > 0 {
+			o.poolSize = size
+		}
+	}
+}
+
+// WithTCPPipelining makes each connection of the pool set with
+// WithTCPConnectionPool send up to depth candidates without waiting for the
+// responses to the previous ones. The oracle must answer the lines in the
+// same order it receives them. As a connection failure makes all the queries
+// pending in the connection fail, it must not be used with oracles that
+// signal an invalid pad by closing the connection. It has no effect without a
+// pool.
+func WithTCPPipelining(depth int) TCPOption {
+	return func(o *TCPLineOracle) {
+		if depth > 0 {
+			o.pipelineDepth = depth
+		}
+	}
+}
+
+// tcpPool is a pool of connections to a line based TCP oracle.
+type tcpPool struct {
+	addr    string
+	timeout time.Duration
+	size    int
+	depth   int
+
+	mu      sync.Mutex
+	cond    *sync.Cond
+	conns   []*pooledConn
+	dialing int
+}
+
+func newTCPPool(addr string, timeout time.Duration, size, depth int) *tcpPool {
+	p := &tcpPool{addr: addr, timeout: timeout, size: size, depth: depth}
+	p.cond = sync.NewCond(&p.mu)
+	return p
+}
+
+// pooledConn is a connection of the pool with the requests waiting for a
+// response, in the order they were sent.
+type pooledConn struct {
+	conn net.Conn
+	r    *bufio.Reader
+
+	// inflight is the number of queries using the connection, protected by
+	// the mutex of the pool.
+	inflight int
+
+	mu     sync.Mutex
+	queue  []chan lineResult
+	broken bool
+}
+
+type lineResult struct {
+	line string
+	err  error
+}
+
+// roundTrip sends the request through a connection of the pool and returns
+// the line received in response.
+func (p *tcpPool) roundTrip(req string) (string, error) {
+	pc, err := p.acquire()
+	if err != nil {
+		return "", err
+	}
+	defer p.release(pc)
+	ch, err := pc.send(req, p.timeout)
+	if err != nil {
+		p.discard(pc)
+		return "", err
+	}
+	r := <-ch
+	return r.line, r.err
+}
+
+// acquire returns the connection with the least queries in flight, opening
+// a new one when all of them are in use and the pool is not full, or waiting
+// for a connection to be released otherwise.
+func (p *tcpPool) acquire() (*pooledConn, error) {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	for {
+		var best *pooledConn
+		for _, pc := range p.conns {
+			if pc.inflight < p.depth && (best == nil || pc.inflight < best.inflight) {
+				best = pc
+			}
+		}
+		full := len(p.conns)+p.dialing >= p.size
+		if best != nil && (best.inflight == 0 || full) {
+			best.inflight++
+			return best, nil
+		}
+		if !full {
+			p.dialing++
+			p.mu.Unlock()
+			conn, err := net.DialTimeout("tcp", p.addr, p.timeout)
+			p.mu.Lock()
+			p.dialing--
+			if err != nil {
+				p.cond.Broadcast()
+				return nil, err
+			}
+			pc := &pooledConn{conn: conn, r: bufio.NewReader(conn), inflight: 1}
+			p.conns = append(p.conns, pc)
+			go p.read(pc)
+			return pc, nil
+		}
+		p.cond.Wait()
+	}
+}
+
+func (p *tcpPool) release(pc *pooledConn) {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	pc.inflight--
+	p.cond.Broadcast()
+}
+
+// discard closes the connection and removes it from the pool.
+func (p *tcpPool) discard(pc *pooledConn) {
+	p.mu.Lock()
+	for i, c := range p.conns {
+		if c == pc {
+			p.conns = append(p.conns[:i], p.conns[i+1:]...)
+			break
+		}
+	}
+	p.cond.Broadcast()
+	p.mu.Unlock()
+	pc.fail(io.ErrUnexpectedEOF)
+	pc.conn.Close()
+}
+
+// read delivers the lines received through the connection to the requests
+// waiting for them, in order, until the connection fails.
+func (p *tcpPool) read(pc *pooledConn) {
+	for {
+		line, err := pc.r.ReadString('\n')
+		if err != nil {
+			pc.fail(err)
+			p.discard(pc)
+			return
+		}
+		pc.mu.Lock()
+		if len(pc.queue) == 0 {
+			pc.mu.Unlock()
+			pc.fail(errUnexpectedLine)
+			p.discard(pc)
+			return
+		}
+		ch := pc.queue[0]
+		pc.queue = pc.queue[1:]
+		pc.mu.Unlock()
+		ch <- lineResult{line: line}
+	}
+}
+
+// send writes the request and returns the channel where the response is
+// delivered.
+func (pc *pooledConn) send(req string, timeout time.Duration) (chan lineResult, error) {
+	pc.mu.Lock()
+	defer pc.mu.Unlock()
+	if pc.broken {
+		return nil, io.ErrUnexpectedEOF
+	}
+	if err := pc.conn.SetDeadline(time.Now().Add(timeout)); err != nil {
+		return nil, err
+	}
+	if _, err := io.WriteString(pc.conn, req); err != nil {
+		return nil, err
+	}
+	ch := make(chan lineResult, 1)
+	pc.queue = append(pc.queue, ch)
+	return ch, nil
+}
+
+// fail marks the connection as broken and makes the requests waiting for a
+// response fail with the given error.
+func (pc *pooledConn) fail(err error) {
+	pc.mu.Lock()
+	defer pc.mu.Unlock()
+	pc.broken = true
+	for _, ch := range pc.queue {
+		ch <- lineResult{err: err}
+	}
+	pc.queue = nil
+}
+
+// close closes all the connections of the pool.
+func (p *tcpPool) close() error {
+	p.mu.Lock()
+	conns := p.conns
+	p.conns = nil
+	p.mu.Unlock()
+	var err error
+	for _, pc := range conns {
+		pc.fail(io.ErrUnexpectedEOF)
+		if cerr := pc.conn.Close(); cerr != nil && err == nil {
+			err = cerr
+		}
+	}
+	return err
+}
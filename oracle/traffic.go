@@ -0,0 +1,85 @@
+package oracle
+
+import (
+	"net/http"
+	"sync/atomic"
+)
+
+// trafficCounter counts the bytes sent to and received from an oracle.
+type trafficCounter struct {
+	sent     int64
+	received int64
+}
+
+func (t *trafficCounter) add(sent, received int) {
+	if t == nil {
+		return
+	}
+	atomic.AddInt64(&t.sent, int64(sent))
+	atomic.AddInt64(&t.received, int64(received))
+}
+
+func (t *trafficCounter) traffic() (sent, received int64) {
+	if t == nil {
+		return 0, 0
+	}
+	return atomic.LoadInt64(&t.sent), atomic.LoadInt64(&t.received)
+}
+
+// WithByteAccounting makes the HTTPOracle count the bytes sent and received.
+// The count is approximate: it includes the request line, the headers and the
+// body of the requests, and the status line, the headers and the body of the
+// responses, but not the headers added or removed by the http.Client, nor the
+// overhead of the connections.
+func WithByteAccounting() HTTPOption {
+	return func(o *HTTPOracle) {
+		o.traffic = &trafficCounter{}
+	}
+}
+
+// Traffic returns the bytes sent to and received from the oracle when the
+// WithByteAccounting option is used, and zero otherwise. It implements the
+// goracler.TrafficCounter interface.
+func (o *HTTPOracle) Traffic() (sent, received int64) {
+	return o.traffic.traffic()
+}
+
+// WithTCPByteAccounting makes the TCPLineOracle count the bytes sent and
+// received.
+func WithTCPByteAccounting() TCPOption {
+	return func(o *TCPLineOracle) {
+		o.traffic = &trafficCounter{}
+	}
+}
+
+// Traffic returns the bytes sent to and received from the oracle when the
+// WithTCPByteAccounting option is used, and zero otherwise. It implements
+// the goracler.TrafficCounter interface.
+func (o *TCPLineOracle) Traffic() (sent, received int64) {
+	return o.traffic.traffic()
+}
+
+// headerLen returns the size of the given headers in the wire format.
+func headerLen(h http.Header) int {
+	n := 0
+	for name, values := range h {
+		for _, v := range values {
+			// name: value\r\n
+			n += len(name) + len(v) + 4
+		}
+	}
+	return n
+}
+
+// requestLen returns the approximate size of a request with the given body.
+func requestLen(req *http.Request, body string) int {
+	// method uri proto\r\n headers \r\n body
+	return len(req.Method) + len(req.URL.RequestURI()) + len(req.Proto) + 4 +
+		headerLen(req.Header) + 2 + len(body)
+}
+
+// responseLen returns the approximate size of a response with the given body.
+func responseLen(resp *http.Response, body []byte) int {
+	// proto status\r\n headers \r\n body
+	return len(resp.Proto) + len(resp.Status) + 3 + headerLen(resp.Header) + 2 + len(body)
+}
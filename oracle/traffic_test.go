@@ -0,0 +1,63 @@
+package oracle
+
+import (
+	"net/http"
+	"testing"
+
+	"github.com/manelmontilla/goracler"
+)
+
+func TestByteAccounting(t *testing.T) {
+	srv := newTestServer()
+	defer srv.Close()
+	addr, closeTCP := newTestTCPServer(t, false)
+	defer closeTCP()
+	tests := []struct {
+		name string
+		q    func(t *testing.T) goracler.Poracle
+		// querySize is the size of each query, if it's fixed.
+		querySize int64
+	}{
+		{
+			name: "CountsHTTPTraffic",
+			q: func(t *testing.T) goracler.Poracle {
+				q, err := NewHTTPOracle(http.MethodGet, srv.URL+"/?c="+Placeholder, "",
+					StatusClassifier(http.StatusOK), WithByteAccounting())
+				if err != nil {
+					t.Fatal(err)
+				}
+				return q
+			},
+		},
+		{
+			name: "CountsTCPTraffic",
+			q: func(t *testing.T) goracler.Poracle {
+				return NewTCPLineOracle(addr, okClassifier, WithTCPByteAccounting())
+			},
+			// Two hex encoded blocks and a new line.
+			querySize: 2*2*int64(goracler.CipherBlockLen) + 1,
+		},
+	}
+	for _, tt := range tests {
+		tt := tt
+		t.Run(tt.name, func(t *testing.T) {
+			q := tt.q(t)
+			var report goracler.DecryptReport
+			_, err := goracler.Decrypt(testCiphertext(t, "Hello world"), q, nopLogger{}, goracler.WithReport(&report))
+			if err != nil {
+				t.Fatal(err)
+			}
+			sent, received := q.(goracler.TrafficCounter).Traffic()
+			if sent == 0 || received == 0 {
+				t.Fatalf("got %d bytes sent and %d received, want more than zero", sent, received)
+			}
+			if report.BytesSent != sent || report.BytesReceived != received {
+				t.Errorf("got report with %d bytes sent and %d received, want %d and %d",
+					report.BytesSent, report.BytesReceived, sent, received)
+			}
+			if tt.querySize != 0 && sent%tt.querySize != 0 {
+				t.Errorf("got %d bytes sent, want a multiple of %d", sent, tt.querySize)
+			}
+		})
+	}
+}
@@ -0,0 +1,325 @@
+// Package ws contains an implementation of the goracler.Poracle interface for
+// oracles exposed through WebSocket. It's a separate package so only the
+// programs using it depend on the WebSocket library.
+package ws
+
+import (
+	"encoding/json"
+	"errors"
+	"net/http"
+	neturl "net/url"
+	"sync"
+	"time"
+
+	"github.com/gorilla/websocket"
+	"github.com/manelmontilla/goracler/oracle"
+)
+
+var (
+	// ErrTimeout is returned by a WebSocketOracle when the response to a
+	// query is not received in time.
+	ErrTimeout = errors.New("timeout waiting for the response")
+
+	// ErrConnectionLost is returned by a WebSocketOracle when the
+	// connection is lost before receiving the response to a query, even
+	// after sending it again through a new connection.
+	ErrConnectionLost = errors.New("connection lost before receiving the response")
+)
+
+// MessageClassifier decides if the message received from a WebSocket oracle
+// means that the pad of the candidate ciphertext was valid. It returns an
+// error if the message is not related to the pad.
+type MessageClassifier func(msg []byte) (bool, error)
+
+// RequestBuilder builds the message sent to the oracle for the encoded
+// candidate, including the given id so the response can be correlated.
+type RequestBuilder func(id uint64, candidate string) ([]byte, error)
+
+// ResponseID extracts from a message received from the oracle the id of the
+// request it answers.
+type ResponseID func(msg []byte) (uint64, error)
+
+// Option configures a WebSocketOracle.
+type Option func(*WebSocketOracle)
+
+// WebSocketOracle queries an oracle exposed through WebSocket. It keeps a
+// persistent connection, opened on the first query, and sends each candidate
+// in a text message with an id that the oracle must include in the message
+// with the response, so several queries can be sent at the same time through
+// the same connection. By default the messages sent are JSON objects like:
+//
+//	{"id": 1, "ciphertext": "<encoded candidate>"}
+//
+// and the id of the response is read from the "id" field of a JSON object.
+// When the connection is lost, the queries waiting for a response are sent
+// again, once, through a new connection. It's safe for concurrent use.
+type WebSocketOracle struct {
+	url        string
+	header     http.Header
+	classify   MessageClassifier
+	encode     oracle.Encoder
+	build      RequestBuilder
+	responseID ResponseID
+	timeout    time.Duration
+	dialer     *websocket.Dialer
+
+	mu     sync.Mutex
+	conn   *conn
+	nextID uint64
+}
+
+// NewWebSocketOracle returns a WebSocketOracle connecting to the given url. By
+// default the candidates are hex encoded and the queries have a timeout of 30
+// seconds.
+func NewWebSocketOracle(url string, classify MessageClassifier, opts ...Option) *WebSocketOracle {
+	o := &WebSocketOracle{
+		url:        url,
+		header:     http.Header{},
+		classify:   classify,
+		encode:     oracle.HexEncoder,
+		build:      jsonRequest,
+		responseID: jsonResponseID,
+		timeout:    30 * time.Second,
+		dialer:     websocket.DefaultDialer,
+	}
+	for _, opt := range opts {
+		opt(o)
+	}
+	return o
+}
+
+// WithEncoder sets the Encoder used to encode the candidates.
+func WithEncoder(e oracle.Encoder) Option {
+	return func(o *WebSocketOracle) {
+		o.encode = e
+	}
+}
+
+// WithHeader adds a header to the handshake request of the connections.
+func WithHeader(name, value string) Option {
+	return func(o *WebSocketOracle) {
+		o.header.Add(name, value)
+	}
+}
+
+// WithFraming sets the functions used to build the messages sent to the
+// oracle and to get the id of the request answered by each message received.
+func WithFraming(build RequestBuilder, responseID ResponseID) Option {
+	return func(o *WebSocketOracle) {
+		o.build = build
+		o.responseID = responseID
+	}
+}
+
+// WithTimeout sets the maximum time to wait for the response to each query.
+func WithTimeout(d time.Duration) Option {
+	return func(o *WebSocketOracle) {
+		o.timeout = d
+	}
+}
+
+// WithDialer sets the websocket.Dialer used to open the connections.
+func WithDialer(d *websocket.Dialer) Option {
+	return func(o *WebSocketOracle) {
+		o.dialer = d
+	}
+}
+
+// Do implements the goracler.Poracle interface.
+func (o *WebSocketOracle) Do(c []byte) (int, error) {
+	candidate := o.encode(c)
+	var err error
+	for attempt := 0; attempt < 2; attempt++ {
+		var msg []byte
+		msg, err = o.query(candidate)
+		if err == ErrConnectionLost {
+			continue
+		}
+		if err != nil {
+			return 0, err
+		}
+		valid, err := o.classify(msg)
+		if err != nil {
+			return 0, err
+		}
+		if !valid {
+			return 0, nil
+		}
+		return 1, nil
+	}
+	return 0, err
+}
+
+// Host implements the goracler.HostPoracle interface. It returns the host,
+// including the port if present, of the url of the oracle.
+func (o *WebSocketOracle) Host() string {
+	u, err := neturl.Parse(o.url)
+	if err != nil {
+		return o.url
+	}
+	return u.Host
+}
+
+// Close closes the connection to the oracle, if open. The next query opens a
+// new one.
+func (o *WebSocketOracle) Close() error {
+	o.mu.Lock()
+	cn := o.conn
+	o.conn = nil
+	o.mu.Unlock()
+	if cn == nil {
+		return nil
+	}
+	return cn.ws.Close()
+}
+
+// query sends the candidate and returns the message with the response.
+func (o *WebSocketOracle) query(candidate string) ([]byte, error) {
+	cn, id, err := o.connect()
+	if err != nil {
+		return nil, err
+	}
+	ch := cn.register(id)
+	defer cn.unregister(id)
+	msg, err := o.build(id, candidate)
+	if err != nil {
+		return nil, err
+	}
+	if err := cn.write(msg); err != nil {
+		o.drop(cn)
+		return nil, ErrConnectionLost
+	}
+	t := time.NewTimer(o.timeout)
+	defer t.Stop()
+	select {
+	case resp, ok := <-ch:
+		if !ok {
+			return nil, ErrConnectionLost
+		}
+		return resp, nil
+	case <-t.C:
+		return nil, ErrTimeout
+	}
+}
+
+// connect returns the current connection, opening it if needed, and the id
+// for a new request.
+func (o *WebSocketOracle) connect() (*conn, uint64, error) {
+	o.mu.Lock()
+	defer o.mu.Unlock()
+	if o.conn == nil {
+		ws, _, err := o.dialer.Dial(o.url, o.header)
+		if err != nil {
+			return nil, 0, err
+		}
+		o.conn = &conn{ws: ws, pending: make(map[uint64]chan []byte)}
+		go o.read(o.conn)
+	}
+	o.nextID++
+	return o.conn, o.nextID, nil
+}
+
+// drop closes the given connection, if it's still the current one.
+func (o *WebSocketOracle) drop(cn *conn) {
+	o.mu.Lock()
+	if o.conn == cn {
+		o.conn = nil
+	}
+	o.mu.Unlock()
+	cn.ws.Close()
+}
+
+// read delivers the messages received through the connection to the queries
+// waiting for them, until the connection is closed.
+func (o *WebSocketOracle) read(cn *conn) {
+	defer cn.close()
+	for {
+		_, msg, err := cn.ws.ReadMessage()
+		if err != nil {
+			o.drop(cn)
+			return
+		}
+		id, err := o.responseID(msg)
+		if err != nil {
+			// The message doesn't answer any request.
+			continue
+		}
+		cn.deliver(id, msg)
+	}
+}
+
+// conn is a connection to the oracle with the queries waiting for a response.
+type conn struct {
+	ws      *websocket.Conn
+	writeMu sync.Mutex
+
+	mu      sync.Mutex
+	pending map[uint64]chan []byte
+	closed  bool
+}
+
+func (c *conn) write(msg []byte) error {
+	c.writeMu.Lock()
+	defer c.writeMu.Unlock()
+	return c.ws.WriteMessage(websocket.TextMessage, msg)
+}
+
+// register returns the channel where the response to the request with the
+// given id is delivered. The channel is closed if the connection is lost.
+func (c *conn) register(id uint64) chan []byte {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	ch := make(chan []byte, 1)
+	if c.closed {
+		close(ch)
+		return ch
+	}
+	c.pending[id] = ch
+	return ch
+}
+
+func (c *conn) unregister(id uint64) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	delete(c.pending, id)
+}
+
+func (c *conn) deliver(id uint64, msg []byte) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	if ch, ok := c.pending[id]; ok {
+		ch <- msg
+		delete(c.pending, id)
+	}
+}
+
+// close closes the channels of the queries waiting for a response.
+func (c *conn) close() {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	c.closed = true
+	for id, ch := range c.pending {
+		close(ch)
+		delete(c.pending, id)
+	}
+}
+
+func jsonRequest(id uint64, candidate string) ([]byte, error) {
+	return json.Marshal(struct {
+		ID         uint64 `json:"id"`
+		Ciphertext string `json:"ciphertext"`
+	}{id, candidate})
+}
+
+func jsonResponseID(msg []byte) (uint64, error) {
+	var r struct {
+		ID *uint64 `json:"id"`
+	}
+	if err := json.Unmarshal(msg, &r); err != nil {
+		return 0, err
+	}
+	if r.ID == nil {
+		return 0, errors.New("the message has no id")
+	}
+	return *r.ID, nil
+}
@@ -0,0 +1,106 @@
+package ws
+
+import (
+	"encoding/hex"
+	"encoding/json"
+	"math/rand"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"sync"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/gorilla/websocket"
+	"github.com/manelmontilla/goracler"
+	"github.com/manelmontilla/goracler/crypto"
+)
+
+const testKey = "ee581a043ac19191c7d551710bab13a9"
+
+type nopLogger struct{}
+
+func (nopLogger) Printf(format string, v ...interface{}) {}
+
+// newTestServer returns a server that decrypts the ciphertexts received in
+// the messages and answers them, out of order, with a message reporting if
+// the pad was valid. Each connection is closed after receiving the given
+// number of messages.
+func newTestServer(t *testing.T, perConn int, conns *int32) *httptest.Server {
+	upgrader := websocket.Upgrader{}
+	return httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		ws, err := upgrader.Upgrade(w, r, nil)
+		if err != nil {
+			t.Error(err)
+			return
+		}
+		atomic.AddInt32(conns, 1)
+		defer ws.Close()
+		var writeMu sync.Mutex
+		for i := 0; i < perConn; i++ {
+			_, msg, err := ws.ReadMessage()
+			if err != nil {
+				return
+			}
+			go func(msg []byte) {
+				var req struct {
+					ID         uint64 `json:"id"`
+					Ciphertext string `json:"ciphertext"`
+				}
+				if err := json.Unmarshal(msg, &req); err != nil {
+					t.Error(err)
+					return
+				}
+				time.Sleep(time.Duration(rand.Intn(200)) * time.Microsecond)
+				_, err := crypto.CBCDecrypt(testKey, req.Ciphertext)
+				resp, _ := json.Marshal(struct {
+					ID    uint64 `json:"id"`
+					Valid bool   `json:"valid"`
+				}{req.ID, err == nil})
+				writeMu.Lock()
+				defer writeMu.Unlock()
+				ws.WriteMessage(websocket.TextMessage, resp)
+			}(msg)
+		}
+	}))
+}
+
+func validClassifier(msg []byte) (bool, error) {
+	var resp struct {
+		Valid bool `json:"valid"`
+	}
+	err := json.Unmarshal(msg, &resp)
+	return resp.Valid, err
+}
+
+func TestWebSocketOracle(t *testing.T) {
+	var conns int32
+	srv := newTestServer(t, 1000, &conns)
+	defer srv.Close()
+	url := "ws" + strings.TrimPrefix(srv.URL, "http")
+	q := NewWebSocketOracle(url, validClassifier, WithTimeout(5*time.Second))
+	defer q.Close()
+
+	msg := "Somewhere in la Mancha"
+	ct, err := crypto.CBCEncrypt("91db4482c4ffa9858338ab0e98ddf96c", testKey, msg)
+	if err != nil {
+		t.Fatal(err)
+	}
+	c, err := hex.DecodeString(ct)
+	if err != nil {
+		t.Fatal(err)
+	}
+	got, err := goracler.DecryptUnpadded(c, q, nopLogger{})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if got != msg {
+		t.Errorf("DecryptUnpadded() = %q, want %q", got, msg)
+	}
+	// The attack needs several thousands of queries, so the oracle must
+	// have reconnected after the server closed the connections.
+	if n := atomic.LoadInt32(&conns); n < 2 {
+		t.Errorf("got %d connections, want the oracle to reconnect", n)
+	}
+}
@@ -0,0 +1,115 @@
+package goracler
+
+// PaddingScheme defines the values of the bytes of the pad checked by the
+// oracle, so the attack can build the probes that produce valid pads. Only
+// deterministic schemes, where the value of each byte depends only on the
+// length of the pad, are supported.
+type PaddingScheme interface {
+	// PadByte returns the value of the byte at index i, counting from the
+	// first byte of the pad, of a pad of length n.
+	PadByte(n, i int) byte
+}
+
+type pkcs7Padding struct{}
+
+func (pkcs7Padding) PadByte(n, i int) byte {
+	return byte(n)
+}
+
+type x923Padding struct{}
+
+func (x923Padding) PadByte(n, i int) byte {
+	if i == n-1 {
+		return byte(n)
+	}
+	return 0
+}
+
+var (
+	// PKCS7Padding is the PKCS#7 padding scheme, where all the bytes of the
+	// pad are equal to its length. It's the scheme used by default.
+	PKCS7Padding PaddingScheme = pkcs7Padding{}
+
+	// X923Padding is the ANSI X.923 padding scheme, where the last byte of
+	// the pad is equal to its length and the rest of the bytes are zero.
+	X923Padding PaddingScheme = x923Padding{}
+)
+
+// OffsetPaddingScheme is implemented by the padding schemes whose pad
+// doesn't end at the end of the block, but Offset bytes before it, for
+// formats that append a trailer, like a length field, after the pad.
+type OffsetPaddingScheme interface {
+	PaddingScheme
+	Offset() int
+}
+
+type offsetPadding struct {
+	PaddingScheme
+	offset int
+}
+
+func (s offsetPadding) Offset() int {
+	return s.offset
+}
+
+// NonTerminalPadding returns a PaddingScheme for formats where the last
+// block of plaintext is laid out as data || pad || trailer, with a trailer of
+// offset bytes, like a length field, that the oracle doesn't check as part of
+// the pad. The values of the bytes of the pad are the ones of the given
+// scheme, and the pad ends offset bytes before the end of the block. For
+// instance, with PKCS#7 and an offset of 2, a block with 3 bytes of pad ends
+// with 03 03 03 L1 L2. The attack builds the probes targeting the pad at that
+// offset and leaves the trailer of the probes untouched. As every block is
+// attacked as if it were the last one, the last offset bytes of all the
+// blocks, not only the trailer, can't be recovered and are set to the
+// FailedBytePlaceholder.
+//
+// The offset must be lower than the block length minus 1, otherwise Decrypt
+// returns ErrInvalidPaddingOffset, and Encrypt doesn't support it.
+func NonTerminalPadding(s PaddingScheme, offset int) PaddingScheme {
+	return offsetPadding{s, offset}
+}
+
+// padEnd returns the position of the last byte of the pad in a block.
+func (c *config) padEnd() int {
+	if s, ok := c.padding.(OffsetPaddingScheme); ok {
+		return c.blockLen - 1 - s.Offset()
+	}
+	return c.blockLen - 1
+}
+
+// padLen returns the length of the pad targeted to recover the byte at the
+// position p of a block.
+func (c *config) padLen(p int) int {
+	return c.padEnd() - p + 1
+}
+
+// pad pads the given message to a multiple of the block length using the
+// given scheme.
+func pad(s PaddingScheme, m []byte, blockLen int) []byte {
+	n := blockLen - len(m)%blockLen
+	r := make([]byte, len(m), len(m)+n)
+	copy(r, m)
+	for i := 0; i < n; i++ {
+		r = append(r, s.PadByte(n, i))
+	}
+	return r
+}
+
+// unpad removes the pad of the given scheme from the message. It returns
+// ErrInvalidRecoveredPad if the message doesn't end with a valid pad.
+func unpad(s PaddingScheme, m []byte, blockLen int) ([]byte, error) {
+	if len(m) == 0 {
+		return nil, ErrInvalidRecoveredPad
+	}
+	n := int(m[len(m)-1])
+	if n < 1 || n > blockLen || n > len(m) {
+		return nil, ErrInvalidRecoveredPad
+	}
+	for i, b := range m[len(m)-n:] {
+		if b != s.PadByte(n, i) {
+			return nil, ErrInvalidRecoveredPad
+		}
+	}
+	return m[:len(m)-n], nil
+}
@@ -0,0 +1,174 @@
+package goracler
+
+import (
+	"bytes"
+	"crypto/aes"
+	"encoding/hex"
+	"testing"
+
+	"github.com/manelmontilla/goracler/crypto"
+)
+
+// x923Oracle simulates an oracle checking ANSI X.923 pads.
+type x923Oracle struct {
+	key string
+}
+
+func (o x923Oracle) Do(c []byte) (int, error) {
+	m, err := o.decrypt(c)
+	if err != nil {
+		return 0, err
+	}
+	n := int(m[len(m)-1])
+	if n < 1 || n > CipherBlockLen {
+		return 0, nil
+	}
+	for _, b := range m[len(m)-n : len(m)-1] {
+		if b != 0 {
+			return 0, nil
+		}
+	}
+	return 1, nil
+}
+
+// decrypt returns the padded plaintext of the iv||ciphertext c.
+func (o x923Oracle) decrypt(c []byte) ([]byte, error) {
+	k, err := hex.DecodeString(o.key)
+	if err != nil {
+		return nil, err
+	}
+	bc, err := aes.NewCipher(k)
+	if err != nil {
+		return nil, err
+	}
+	m := make([]byte, 0, len(c)-CipherBlockLen)
+	for i := CipherBlockLen; i < len(c); i += CipherBlockLen {
+		d := make([]byte, CipherBlockLen)
+		bc.Decrypt(d, c[i:i+CipherBlockLen])
+		m = append(m, xorBlocks(d, c[i-CipherBlockLen:i])...)
+	}
+	return m, nil
+}
+
+func TestX923Padding(t *testing.T) {
+	key := "ee581a043ac19191c7d551710bab13a9"
+	iv := "91db4482c4ffa9858338ab0e98ddf96c"
+	msg := []byte("Somewhere in la Mancha, in a place")
+	padded := pad(X923Padding, msg, CipherBlockLen)
+	want := append(append([]byte{}, msg...), 0, 0, 0, 0, 0, 0, 0, 0, 0, 0, 0, 0, 0, 14)
+	if !bytes.Equal(padded, want) {
+		t.Fatalf("pad() = %x, want %x", padded, want)
+	}
+	k, err := hex.DecodeString(key)
+	if err != nil {
+		t.Fatal(err)
+	}
+	bc, err := aes.NewCipher(k)
+	if err != nil {
+		t.Fatal(err)
+	}
+	biv, err := hex.DecodeString(iv)
+	if err != nil {
+		t.Fatal(err)
+	}
+	// CBCEncryptWithCipher always adds a PKCS#7 pad, as the message is
+	// already aligned the pad is a full block that can be dropped.
+	c, err := crypto.CBCEncryptWithCipher(bc, biv, padded)
+	if err != nil {
+		t.Fatal(err)
+	}
+	c = c[:len(c)-CipherBlockLen]
+	q := x923Oracle{key: key}
+
+	got, err := Decrypt(c, q, nopLogger{}, WithPaddingScheme(X923Padding))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if got != string(padded) {
+		t.Errorf("Decrypt() = %q, want %q", got, padded)
+	}
+
+	forged, err := Encrypt(msg, q, nopLogger{}, WithPaddingScheme(X923Padding))
+	if err != nil {
+		t.Fatal(err)
+	}
+	m, err := q.decrypt(forged)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !bytes.Equal(m, padded) {
+		t.Errorf("got forged plaintext %q, want %q", m, padded)
+	}
+}
+
+// trailerOracle simulates an oracle for a format where the last block of
+// plaintext ends with a PKCS#7 pad followed by a 2 bytes length field, which
+// it doesn't check.
+type trailerOracle struct {
+	x923Oracle
+}
+
+func (o trailerOracle) Do(c []byte) (int, error) {
+	m, err := o.decrypt(c)
+	if err != nil {
+		return 0, err
+	}
+	if _, err := padLength(m[:len(m)-2], CipherBlockLen); err != nil {
+		return 0, nil
+	}
+	return 1, nil
+}
+
+func TestNonTerminalPadding(t *testing.T) {
+	key := "ee581a043ac19191c7d551710bab13a9"
+	iv := "91db4482c4ffa9858338ab0e98ddf96c"
+	msg := []byte("Somewhere in la Mancha, in a place")
+	// The message is followed by a pad up to 2 bytes before the end of the
+	// block and its length.
+	plaintext := append(append(msg, bytes.Repeat([]byte{12}, 12)...), 0, byte(len(msg)))
+	k, err := hex.DecodeString(key)
+	if err != nil {
+		t.Fatal(err)
+	}
+	bc, err := aes.NewCipher(k)
+	if err != nil {
+		t.Fatal(err)
+	}
+	biv, err := hex.DecodeString(iv)
+	if err != nil {
+		t.Fatal(err)
+	}
+	c, err := crypto.CBCEncryptWithCipher(bc, biv, plaintext)
+	if err != nil {
+		t.Fatal(err)
+	}
+	c = c[:len(c)-CipherBlockLen]
+	q := trailerOracle{x923Oracle{key: key}}
+	got, err := Decrypt(c, q, nopLogger{}, WithPaddingScheme(NonTerminalPadding(PKCS7Padding, 2)))
+	if err != nil {
+		t.Fatal(err)
+	}
+	// The last 2 bytes of each block can't be recovered.
+	want := make([]byte, len(plaintext))
+	copy(want, plaintext)
+	for i := CipherBlockLen - 2; i < len(want); i += CipherBlockLen {
+		want[i], want[i+1] = FailedBytePlaceholder, FailedBytePlaceholder
+	}
+	if got != string(want) {
+		t.Errorf("Decrypt() = %q, want %q", got, want)
+	}
+
+	ok, err := VerifyPlaintext(c, 2, plaintext[2*CipherBlockLen:], q, nopLogger{}, WithPaddingScheme(NonTerminalPadding(PKCS7Padding, 2)))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !ok {
+		t.Error("VerifyPlaintext() = false, want true")
+	}
+	if _, err := Encrypt(msg, q, nopLogger{}, WithPaddingScheme(NonTerminalPadding(PKCS7Padding, 2))); err != ErrUnsupportedPadding {
+		t.Errorf("Encrypt() error = %v, want %v", err, ErrUnsupportedPadding)
+	}
+	if _, err := Decrypt(c, q, nopLogger{}, WithPaddingScheme(NonTerminalPadding(PKCS7Padding, CipherBlockLen-1))); err != ErrInvalidPaddingOffset {
+		t.Errorf("Decrypt() error = %v, want %v", err, ErrInvalidPaddingOffset)
+	}
+}
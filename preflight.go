@@ -0,0 +1,180 @@
+package goracler
+
+import (
+	"errors"
+	"sync"
+	"time"
+)
+
+// ErrRejectedCiphertext is reported by Preflight when the oracle reports as
+// invalid the pad of the ciphertext to attack.
+var ErrRejectedCiphertext = errors.New("the oracle reports the pad of the ciphertext as invalid")
+
+// PreflightReport contains the results of the checks performed by Preflight.
+type PreflightReport struct {
+	// Blocks is the number of blocks of plaintext in the ciphertext.
+	Blocks int
+
+	// Reachable is true if the oracle answered all the queries sent by
+	// Preflight without returning an error.
+	Reachable bool
+
+	// ValidCiphertext is true if the oracle reports the pad of the
+	// ciphertext, as it's sent by the attack, as valid.
+	ValidCiphertext bool
+
+	// PKCS7Checked is true if the PKCS#7 behavior of the oracle was checked
+	// using HealthCheck.
+	PKCS7Checked bool
+
+	// Queries is the number of queries sent by Preflight and AvgLatency the
+	// average time the oracle took to answer them.
+	Queries    int
+	AvgLatency time.Duration
+
+	// EstimatedQueries and EstimatedDuration are the estimations of the
+	// queries and the time needed to decrypt the ciphertext, computed using
+	// the same model as EstimateDuration with the latency measured by
+	// Preflight and the concurrency set in the options.
+	EstimatedQueries  int
+	EstimatedDuration time.Duration
+
+	// Problems contains the problems found by the checks, in the order
+	// they were found. It can contain ErrRejectedCiphertext,
+	// ErrDegenerateClassifier, ErrAlwaysValidOracle, an error wrapping
+	// ErrUnhealthyOracle or the error returned by the oracle.
+	Problems []error
+}
+
+// Ready returns true if no problems were found.
+func (r PreflightReport) Ready() bool {
+	return len(r.Problems) == 0
+}
+
+// Preflight checks that the ciphertext, the oracle and the options are ready
+// for a decrypt attack without running it. It returns ErrInvalidCiphertext,
+// or the error returned when validating the options, without sending any
+// query if the ciphertext, after applying the tag and transform options, is
+// not block aligned or doesn't contain at least two blocks. Otherwise, it
+// sends to the oracle, in this order:
+//   - The last two blocks of the ciphertext, built as the attack builds its
+//     probes, to check the oracle is reachable and reports the pad as valid.
+//   - The checks Decrypt performs before the attack: the one detecting
+//     oracles that return the same result for valid and invalid pads and,
+//     unless WithoutAlwaysValidCheck is used, the one detecting oracles that
+//     always report the pads as valid.
+//   - The queries of HealthCheck, which decrypts a random block, not one of
+//     the ciphertext, and checks the PKCS#7 behavior of the oracle. They are
+//     only sent when the padding scheme is PKCS7Padding and the options
+//     don't change how the probes are built, as the queries are always made
+//     of two blocks.
+//
+// When the oracle returns an error the error is added to the problems and the
+// remaining checks are skipped. The problems found by the checks are reported
+// in the returned PreflightReport, not as an error.
+//
+// No block of the ciphertext is decrypted, so the sink, the report, the
+// checkpoint and the callbacks set in the options are not used. The options
+// wrapping the oracle, like the rate limits or the query budget, do apply to
+// the queries sent by Preflight.
+func Preflight(c []byte, q Poracle, opts ...Option) (r PreflightReport, err error) {
+	cfg := newConfig(opts)
+	if err := cfg.validate(); err != nil {
+		return r, err
+	}
+	if cfg.computeTag != nil {
+		c, err = stripTags(c, cfg.tagLen, cfg.blockLen)
+		if err != nil {
+			return r, err
+		}
+	}
+	if cfg.forward != nil {
+		t := cfg.forward(c)
+		if len(t) != len(c) {
+			return r, ErrInvalidTransform
+		}
+		c = t
+	}
+	n := len(c) / cfg.blockLen
+	if n < 2 || len(c)%cfg.blockLen != 0 {
+		return r, ErrInvalidCiphertext
+	}
+	r.Blocks = n - 1
+	r.EstimatedQueries = r.Blocks * cfg.blockLen * avgCandidates
+
+	tq := &timingOracle{Poracle: cfg.oracle(q)}
+	defer func() {
+		r.Queries, r.AvgLatency = tq.avg()
+		r.EstimatedDuration = EstimateDuration(c, cfg.blockLen, r.AvgLatency, cfg.concurrency)
+	}()
+	prev := c[len(c)-2*cfg.blockLen : len(c)-cfg.blockLen]
+	current := c[len(c)-cfg.blockLen:]
+	res, err := tq.Do(cfg.buildProbe(prev, current))
+	if err != nil {
+		r.Problems = append(r.Problems, err)
+		return r, nil
+	}
+	r.Reachable = true
+	r.ValidCiphertext = res > 0
+	if !r.ValidCiphertext {
+		r.Problems = append(r.Problems, ErrRejectedCiphertext)
+	}
+
+	checks := []func() error{
+		func() error { return checkClassifier(tq, prev, current, cfg) },
+	}
+	if !cfg.skipAlwaysValidCheck {
+		checks = append(checks, func() error {
+			return checkAlwaysValid(tq, c[:cfg.blockLen], c[cfg.blockLen:2*cfg.blockLen], cfg)
+		})
+	}
+	// A custom PaddingScheme may not be comparable, so the type is checked
+	// instead of the value.
+	if _, ok := cfg.padding.(pkcs7Padding); ok && cfg.fullMessage == nil && cfg.validBlocks == 0 && cfg.fixedLen == 0 {
+		r.PKCS7Checked = true
+		checks = append(checks, func() error { return HealthCheck(tq, WithBlockLen(cfg.blockLen)) })
+	}
+	for _, check := range checks {
+		err := check()
+		if err == nil {
+			continue
+		}
+		r.Problems = append(r.Problems, err)
+		if !errors.Is(err, ErrDegenerateClassifier) && !errors.Is(err, ErrAlwaysValidOracle) && !errors.Is(err, ErrUnhealthyOracle) {
+			r.Reachable = false
+			return r, nil
+		}
+	}
+	return r, nil
+}
+
+// timingOracle measures the number of queries sent to the wrapped oracle and
+// the time it takes to answer them.
+type timingOracle struct {
+	Poracle
+	mu      sync.Mutex
+	queries int
+	total   time.Duration
+}
+
+func (o *timingOracle) Do(c []byte) (int, error) {
+	start := time.Now()
+	res, err := o.Poracle.Do(c)
+	d := time.Since(start)
+	o.mu.Lock()
+	o.queries++
+	o.total += d
+	o.mu.Unlock()
+	return res, err
+}
+
+// avg returns the number of queries sent and the average time the oracle took
+// to answer them.
+func (o *timingOracle) avg() (int, time.Duration) {
+	o.mu.Lock()
+	defer o.mu.Unlock()
+	if o.queries == 0 {
+		return 0, 0
+	}
+	return o.queries, o.total / time.Duration(o.queries)
+}
@@ -0,0 +1,61 @@
+package goracler
+
+import (
+	"errors"
+	"testing"
+)
+
+func TestPreflight(t *testing.T) {
+	key := "ee581a043ac19191c7d551710bab13a9"
+	c := testCiphertext(t, "Somewhere in la Mancha, in a place")
+
+	q := &countingOracle{Poracle: testOracle{key: key}}
+	if _, err := Preflight(c[:len(c)-1], q); err != ErrInvalidCiphertext {
+		t.Errorf("Preflight() error = %v, want %v", err, ErrInvalidCiphertext)
+	}
+	if q.queries != 0 {
+		t.Errorf("Preflight() sent %d queries for a misaligned ciphertext, want 0", q.queries)
+	}
+
+	r, err := Preflight(c, q)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !r.Ready() {
+		t.Errorf("Preflight() problems = %v, want none", r.Problems)
+	}
+	if !r.Reachable || !r.ValidCiphertext || !r.PKCS7Checked {
+		t.Errorf("Preflight() = %+v, want a reachable oracle accepting the ciphertext", r)
+	}
+	if r.Blocks != 3 || r.EstimatedQueries != 3*CipherBlockLen*avgCandidates {
+		t.Errorf("Preflight() Blocks = %d, EstimatedQueries = %d", r.Blocks, r.EstimatedQueries)
+	}
+	if r.Queries != int(q.queries) {
+		t.Errorf("Preflight() Queries = %d, want %d", r.Queries, q.queries)
+	}
+
+	r, err = Preflight(c, constantOracle(0))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if r.Ready() || r.ValidCiphertext {
+		t.Errorf("Preflight() = %+v, want a degenerate oracle rejecting the ciphertext", r)
+	}
+	var degenerate, unhealthy bool
+	for _, p := range r.Problems {
+		degenerate = degenerate || errors.Is(p, ErrDegenerateClassifier)
+		unhealthy = unhealthy || errors.Is(p, ErrUnhealthyOracle)
+	}
+	if !degenerate || !unhealthy {
+		t.Errorf("Preflight() problems = %v, want %v and %v", r.Problems, ErrDegenerateClassifier, ErrUnhealthyOracle)
+	}
+
+	oracleErr := errors.New("connection refused")
+	r, err = Preflight(c, failingOracle{oracleErr})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if r.Reachable || len(r.Problems) != 1 || r.Problems[0] != oracleErr {
+		t.Errorf("Preflight() = %+v, want an unreachable oracle", r)
+	}
+}
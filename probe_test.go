@@ -0,0 +1,315 @@
+package goracler
+
+import (
+	"bytes"
+	"crypto/aes"
+	"encoding/hex"
+	"errors"
+	"strings"
+	"testing"
+
+	"github.com/manelmontilla/goracler/crypto"
+)
+
+// lastByteOracle simulates an oracle that only checks the last byte of the
+// pad, so it doesn't implement PKCS#7.
+type lastByteOracle struct {
+	key string
+}
+
+func (o lastByteOracle) Do(c []byte) (int, error) {
+	k, err := hex.DecodeString(o.key)
+	if err != nil {
+		return 0, err
+	}
+	bc, err := aes.NewCipher(k)
+	if err != nil {
+		return 0, err
+	}
+	n := len(c)
+	m := make([]byte, CipherBlockLen)
+	bc.Decrypt(m, c[n-CipherBlockLen:])
+	last := m[CipherBlockLen-1] ^ c[n-CipherBlockLen-1]
+	if last < 1 || int(last) > CipherBlockLen {
+		return 0, nil
+	}
+	return 1, nil
+}
+
+// zeroPadOracle simulates an oracle that, besides the PKCS#7 pads, accepts the
+// block filled by the probes of ValidatePKCS7Behavior ending with 0x00.
+type zeroPadOracle struct {
+	testOracle
+}
+
+func (o zeroPadOracle) Do(c []byte) (int, error) {
+	k, err := hex.DecodeString(o.key)
+	if err != nil {
+		return 0, err
+	}
+	bc, err := aes.NewCipher(k)
+	if err != nil {
+		return 0, err
+	}
+	n := len(c)
+	m := make([]byte, CipherBlockLen)
+	bc.Decrypt(m, c[n-CipherBlockLen:])
+	for i := range m {
+		m[i] ^= c[n-2*CipherBlockLen+i]
+	}
+	if bytes.Equal(m, append(bytes.Repeat([]byte{'A'}, CipherBlockLen-1), 0)) {
+		return 1, nil
+	}
+	return o.testOracle.Do(c)
+}
+
+func TestValidatePKCS7Behavior(t *testing.T) {
+	key := "ee581a043ac19191c7d551710bab13a9"
+	tests := []struct {
+		name     string
+		q        Poracle
+		wantOK   bool
+		wantLine string
+	}{
+		{
+			name:   "AcceptsPKCS7Oracle",
+			q:      testOracle{key: key},
+			wantOK: true,
+		},
+		{
+			name: "RejectsLastByteOracle",
+			q:    lastByteOracle{key: key},
+		},
+		{
+			name:     "RejectsZeroPad",
+			q:        zeroPadOracle{testOracle{key: key}},
+			wantLine: "pad 0x00: expected valid false, got valid true",
+		},
+	}
+	for _, tt := range tests {
+		tt := tt
+		t.Run(tt.name, func(t *testing.T) {
+			report, ok, err := ValidatePKCS7Behavior(tt.q, CipherBlockLen)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if ok != tt.wantOK {
+				t.Errorf("ValidatePKCS7Behavior() ok = %t, want %t, report:\n%s", ok, tt.wantOK, report)
+			}
+			if !ok && report == "" {
+				t.Errorf("ValidatePKCS7Behavior() returned an empty report for a failed validation")
+			}
+			if !strings.Contains(report, tt.wantLine) {
+				t.Errorf("ValidatePKCS7Behavior() report:\n%s\nwant it to contain %q", report, tt.wantLine)
+			}
+		})
+	}
+}
+
+func TestDiagnose(t *testing.T) {
+	key := "ee581a043ac19191c7d551710bab13a9"
+	tests := []struct {
+		name        string
+		iv          string
+		msg         string
+		dropIV      bool
+		wantVerdict string
+	}{
+		{
+			name:        "DetectsTheIV",
+			iv:          "91db4482c4ffa9858338ab0e98ddf96c",
+			msg:         "Somewhere in la Mancha, in a place whose name",
+			wantVerdict: "verdict: the leading block looks like the IV",
+		},
+		{
+			name:        "DetectsMissingZeroIV",
+			iv:          "00000000000000000000000000000000",
+			msg:         "Somewhere in la \x00\x01\x02\x03\x04\x05\x06\x07\x08\x09\x0a\x0b\x0c\x0d\x0e\x0f",
+			dropIV:      true,
+			wantVerdict: "verdict: the leading block looks like ciphertext encrypted with a zero IV",
+		},
+	}
+	for _, tt := range tests {
+		tt := tt
+		t.Run(tt.name, func(t *testing.T) {
+			ct, err := crypto.CBCEncrypt(tt.iv, key, tt.msg)
+			if err != nil {
+				t.Fatal(err)
+			}
+			c, err := hex.DecodeString(ct)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if tt.dropIV {
+				c = c[CipherBlockLen:]
+			}
+			report, err := Diagnose(c, testOracle{key: key})
+			if err != nil {
+				t.Fatal(err)
+			}
+			if !strings.Contains(report, tt.wantVerdict) {
+				t.Errorf("Diagnose() report:\n%s\nwant it to contain %q", report, tt.wantVerdict)
+			}
+		})
+	}
+}
+
+type alwaysValidOracle struct{}
+
+func (alwaysValidOracle) Do(c []byte) (int, error) {
+	return 1, nil
+}
+
+func TestAlwaysValidCheck(t *testing.T) {
+	c := make([]byte, 2*CipherBlockLen)
+	if _, err := Decrypt(c, alwaysValidOracle{}, nopLogger{}); err != ErrAlwaysValidOracle {
+		t.Errorf("Decrypt() error = %v, want %v", err, ErrAlwaysValidOracle)
+	}
+	if _, err := Encrypt([]byte("a"), alwaysValidOracle{}, nopLogger{}); err != ErrAlwaysValidOracle {
+		t.Errorf("Encrypt() error = %v, want %v", err, ErrAlwaysValidOracle)
+	}
+	_, err := Decrypt(c, alwaysValidOracle{}, nopLogger{}, WithoutAlwaysValidCheck())
+	if err == ErrAlwaysValidOracle {
+		t.Errorf("Decrypt() returned %v with the check disabled", err)
+	}
+}
+
+// integrityOracle simulates a target checking the integrity of the
+// ciphertexts, like one using an AEAD mode, that only accepts the ciphertext
+// it generated.
+type integrityOracle struct {
+	valid []byte
+}
+
+func (o integrityOracle) Do(c []byte) (int, error) {
+	if !bytes.Equal(c, o.valid) {
+		return 0, nil
+	}
+	return 1, nil
+}
+
+func TestIsCBCExploitable(t *testing.T) {
+	key := "ee581a043ac19191c7d551710bab13a9"
+	tests := []struct {
+		name string
+		msg  string
+		q    func(c []byte) Poracle
+		want bool
+	}{
+		{
+			name: "DetectsCBC",
+			msg:  "Somewhere in la Mancha, in a place whose name",
+			q:    func([]byte) Poracle { return testOracle{key: key} },
+			want: true,
+		},
+		{
+			name: "DetectsCBCWithOneBlock",
+			msg:  "Somewhere",
+			q:    func([]byte) Poracle { return testOracle{key: key} },
+			want: true,
+		},
+		{
+			name: "DetectsIntegrityChecks",
+			msg:  "Somewhere in la Mancha, in a place whose name",
+			q:    func(c []byte) Poracle { return integrityOracle{valid: c} },
+			want: false,
+		},
+	}
+	for _, tt := range tests {
+		tt := tt
+		t.Run(tt.name, func(t *testing.T) {
+			c := testCiphertext(t, tt.msg)
+			got, err := IsCBCExploitable(c, tt.q(c))
+			if err != nil {
+				t.Fatal(err)
+			}
+			if got != tt.want {
+				t.Errorf("IsCBCExploitable() = %t, want %t", got, tt.want)
+			}
+		})
+	}
+}
+
+// constantOracle simulates an oracle returning always the same result.
+type constantOracle int
+
+func (o constantOracle) Do(c []byte) (int, error) {
+	return int(o), nil
+}
+
+func TestClassifierCheck(t *testing.T) {
+	key := "ee581a043ac19191c7d551710bab13a9"
+	c := testCiphertext(t, "Hello world")
+	tests := []struct {
+		name    string
+		q       Poracle
+		opts    []Option
+		wantErr error
+	}{
+		{
+			name:    "DetectsConstantNonZeroResults",
+			q:       constantOracle(2),
+			opts:    []Option{WithoutAlwaysValidCheck()},
+			wantErr: ErrDegenerateClassifier,
+		},
+		{
+			name:    "DetectsConstantInvalidResults",
+			q:       constantOracle(0),
+			wantErr: ErrDegenerateClassifier,
+		},
+		{
+			name:    "CanBeDisabled",
+			q:       constantOracle(0),
+			opts:    []Option{WithClassifierCheck(false)},
+			wantErr: ErrNoValidByte,
+		},
+		{
+			name: "AcceptsCorrectOracles",
+			q:    testOracle{key: key},
+		},
+	}
+	for _, tt := range tests {
+		tt := tt
+		t.Run(tt.name, func(t *testing.T) {
+			if _, err := Decrypt(c, tt.q, nopLogger{}, tt.opts...); !errors.Is(err, tt.wantErr) {
+				t.Errorf("Decrypt() error = %v, want %v", err, tt.wantErr)
+			}
+		})
+	}
+}
+
+func TestMinimalProbe(t *testing.T) {
+	key := "ee581a043ac19191c7d551710bab13a9"
+	msg := "Somewhere in la Mancha, in a place whose name"
+	c := testCiphertext(t, msg)
+	q := testOracle{key: key}
+	n := len(c)/CipherBlockLen - 1
+	for i := 0; i < n; i++ {
+		p, err := MinimalProbe(c, i)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if !bytes.Equal(p, c[i*CipherBlockLen:(i+2)*CipherBlockLen]) {
+			t.Errorf("MinimalProbe(c, %d) = %x, want blocks %d and %d", i, p, i, i+1)
+		}
+		m, err := decryptBlock(p[:CipherBlockLen], p[CipherBlockLen:], q, nopLogger{}, newConfig(nil))
+		if err != nil {
+			t.Fatal(err)
+		}
+		want := msg[i*CipherBlockLen:]
+		if len(want) > CipherBlockLen {
+			want = want[:CipherBlockLen]
+		}
+		if !bytes.HasPrefix(m, []byte(want)) {
+			t.Errorf("got block %d %q, want %q", i, m, want)
+		}
+	}
+	for _, i := range []int{-1, n} {
+		if _, err := MinimalProbe(c, i); err != ErrInvalidBlockIndex {
+			t.Errorf("MinimalProbe(c, %d) error = %v, want %v", i, err, ErrInvalidBlockIndex)
+		}
+	}
+	if _, err := MinimalProbe(c[1:], 0); err != ErrInvalidCiphertext {
+		t.Errorf("MinimalProbe() error = %v, want %v", err, ErrInvalidCiphertext)
+	}
+}
@@ -0,0 +1,36 @@
+package goracler
+
+import (
+	"io"
+	"io/ioutil"
+)
+
+// DecryptReader performs a decrypt attack in the same way Decrypt does over the
+// ciphertext read from r. The full ciphertext is read, and its alignment
+// validated, before starting the attack, so errors reading r or invalid
+// ciphertexts are returned directly. The attack runs in the background and the
+// returned reader streams the plaintext as the blocks are recovered, in the
+// order they have in the ciphertext and without applying the
+// PayloadExtractor. The blocks are not buffered beyond what the
+// WithPlaintextSink option does, so the attack advances as the reader is
+// consumed, and the attack runs in the WithLowMemory mode. Errors of the
+// attack arrive mid-stream: they are returned by Read after the plaintext
+// recovered so far. The reader must be read until it returns an error, io.EOF
+// when the attack succeeds, to release the resources of the attack.
+func DecryptReader(r io.Reader, q Poracle, l Logger, opts ...Option) (io.Reader, error) {
+	c, err := ioutil.ReadAll(r)
+	if err != nil {
+		return nil, err
+	}
+	cfg := newConfig(opts)
+	if len(c) < 2*cfg.blockLen || len(c)%cfg.blockLen != 0 {
+		return nil, ErrInvalidCiphertext
+	}
+	pr, pw := io.Pipe()
+	opts = append(opts, WithPlaintextSink(pw), WithLowMemory())
+	go func() {
+		_, err := Decrypt(c, q, l, opts...)
+		pw.CloseWithError(err)
+	}()
+	return pr, nil
+}
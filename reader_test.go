@@ -0,0 +1,58 @@
+package goracler
+
+import (
+	"bytes"
+	"io/ioutil"
+	"testing"
+
+	"github.com/manelmontilla/goracler/crypto"
+)
+
+func TestDecryptReader(t *testing.T) {
+	key := "ee581a043ac19191c7d551710bab13a9"
+	msg := "Somewhere in la Mancha, in a place whose name I do not care to remember"
+	tests := []struct {
+		name    string
+		c       func(t *testing.T) []byte
+		q       Poracle
+		want    []byte
+		wantErr error
+	}{
+		{
+			name: "DecryptsTheCiphertext",
+			c: func(t *testing.T) []byte {
+				c := testCiphertext(t, msg)
+				return c
+			},
+			q:    testOracle{key: key},
+			want: crypto.PCKCS5Pad([]byte(msg)),
+		},
+		{
+			name: "ReturnsErrorWhenNotAligned",
+			c: func(t *testing.T) []byte {
+				return make([]byte, CipherBlockLen*2+1)
+			},
+			q:       testOracle{key: key},
+			wantErr: ErrInvalidCiphertext,
+		},
+	}
+	for _, tt := range tests {
+		tt := tt
+		t.Run(tt.name, func(t *testing.T) {
+			r, err := DecryptReader(bytes.NewReader(tt.c(t)), tt.q, nopLogger{})
+			if err != tt.wantErr {
+				t.Fatalf("DecryptReader() error = %v, want %v", err, tt.wantErr)
+			}
+			if err != nil {
+				return
+			}
+			got, err := ioutil.ReadAll(r)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if !bytes.Equal(got, tt.want) {
+				t.Errorf("got %q, want %q", got, tt.want)
+			}
+		})
+	}
+}
@@ -0,0 +1,103 @@
+package goracler
+
+import (
+	"bufio"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"io"
+	"strconv"
+	"strings"
+	"sync"
+)
+
+// ErrNotRecorded is returned by a ReplayOracle when it's queried with a
+// candidate that is not in the recording.
+var ErrNotRecorded = errors.New("candidate not found in the recording")
+
+// RecordingOracle is a Poracle that queries another oracle and writes every
+// candidate together with the result returned by the oracle. Each query is
+// written in a line with the format:
+//
+//	<hex encoded candidate> <result>
+//
+// The queries for which the oracle returned an error are not recorded. It's
+// safe for concurrent use.
+type RecordingOracle struct {
+	q  Poracle
+	mu sync.Mutex
+	w  io.Writer
+}
+
+// NewRecordingOracle returns a RecordingOracle querying q and writing the
+// recording to w.
+func NewRecordingOracle(q Poracle, w io.Writer) *RecordingOracle {
+	return &RecordingOracle{q: q, w: w}
+}
+
+// Do implements the Poracle interface.
+func (r *RecordingOracle) Do(c []byte) (int, error) {
+	res, err := r.q.Do(c)
+	if err != nil {
+		return res, err
+	}
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	if _, err := fmt.Fprintf(r.w, "%s %d\n", hex.EncodeToString(c), res); err != nil {
+		return 0, err
+	}
+	return res, nil
+}
+
+// ReplayOracle is a Poracle that answers the queries using the results stored
+// in a recording written by a RecordingOracle, without querying any real
+// oracle. It's safe for concurrent use. Which candidates are queried by the
+// workers of an attack depends on the timing of the responses, so the replay
+// of an attack is only guaranteed to find all its candidates in the recording
+// when it's run using a single worker, WithConcurrency(1), as it tries the
+// candidates in order.
+type ReplayOracle struct {
+	results map[string]int
+}
+
+// NewReplayOracle returns a ReplayOracle that reads the recording from r.
+func NewReplayOracle(r io.Reader) (*ReplayOracle, error) {
+	results := make(map[string]int)
+	s := bufio.NewScanner(r)
+	s.Buffer(make([]byte, 0, 64*1024), 1024*1024)
+	n := 0
+	for s.Scan() {
+		n++
+		line := strings.TrimSpace(s.Text())
+		if line == "" {
+			continue
+		}
+		parts := strings.Fields(line)
+		if len(parts) != 2 {
+			return nil, fmt.Errorf("invalid recording line %d", n)
+		}
+		if _, err := hex.DecodeString(parts[0]); err != nil {
+			return nil, fmt.Errorf("invalid candidate in recording line %d: %w", n, err)
+		}
+		res, err := strconv.Atoi(parts[1])
+		if err != nil {
+			return nil, fmt.Errorf("invalid result in recording line %d: %w", n, err)
+		}
+		results[strings.ToLower(parts[0])] = res
+	}
+	if err := s.Err(); err != nil {
+		return nil, err
+	}
+	return &ReplayOracle{results: results}, nil
+}
+
+// Do implements the Poracle interface. It returns an error wrapping
+// ErrNotRecorded if the candidate is not in the recording.
+func (r *ReplayOracle) Do(c []byte) (int, error) {
+	h := hex.EncodeToString(c)
+	res, ok := r.results[h]
+	if !ok {
+		return 0, fmt.Errorf("%w: %s", ErrNotRecorded, h)
+	}
+	return res, nil
+}
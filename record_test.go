@@ -0,0 +1,49 @@
+package goracler
+
+import (
+	"bytes"
+	"errors"
+	"testing"
+)
+
+func TestRecordAndReplay(t *testing.T) {
+	key := "ee581a043ac19191c7d551710bab13a9"
+	msg := "Somewhere in la Mancha"
+	c := testCiphertext(t, msg)
+	var recording bytes.Buffer
+	rec := NewRecordingOracle(testOracle{key: key}, &recording)
+	want, err := Decrypt(c, rec, nopLogger{})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	replay, err := NewReplayOracle(bytes.NewReader(recording.Bytes()))
+	if err != nil {
+		t.Fatal(err)
+	}
+	got, err := Decrypt(c, replay, nopLogger{}, WithConcurrency(1))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if got != want {
+		t.Errorf("replayed Decrypt() = %q, want %q", got, want)
+	}
+
+	_, err = replay.Do(make([]byte, 2*CipherBlockLen))
+	if !errors.Is(err, ErrNotRecorded) {
+		t.Errorf("ReplayOracle.Do() error = %v, want %v", err, ErrNotRecorded)
+	}
+}
+
+func TestNewReplayOracleInvalidRecording(t *testing.T) {
+	recordings := []string{
+		"0011\n",
+		"zz 1\n",
+		"0011 x\n",
+	}
+	for _, r := range recordings {
+		if _, err := NewReplayOracle(bytes.NewBufferString(r)); err == nil {
+			t.Errorf("NewReplayOracle(%q) returned no error", r)
+		}
+	}
+}
@@ -0,0 +1,85 @@
+package goracler
+
+// DecryptReport contains information about a decrypt attack. It's filled by
+// Decrypt when it's passed in using the WithReport option.
+type DecryptReport struct {
+	// FinalPadLength is the length of the PKCS#7 pad found in the last
+	// recovered block, or 0 if the block doesn't end with a valid pad.
+	FinalPadLength int
+
+	// FailedBlocks contains the indexes of the blocks that couldn't be
+	// decrypted when the WithMaxQueriesPerBlock option is used.
+	FailedBlocks []int
+
+	// MissingBlocks contains the indexes of the blocks of plaintext that
+	// couldn't be decrypted because they are missing from a ciphertext
+	// passed to DecryptTruncated.
+	MissingBlocks []int
+
+	// Coverage describes which bytes of the plaintext were recovered, which
+	// is useful when the attack recovers only part of it, because the query
+	// budget is exhausted, some blocks or bytes can't be recovered or the
+	// ciphertext is truncated. The bytes are counted in the plaintext as
+	// returned by Decrypt, before applying the PayloadExtractor.
+	Coverage Coverage
+
+	// BytesSent and BytesReceived are the bytes sent to and received from
+	// the oracle during the attack, when it implements TrafficCounter.
+	BytesSent     int64
+	BytesReceived int64
+
+	// StoppedOnMatch is true if the attack stopped before decrypting all
+	// the blocks because the plaintext matched the expression set with the
+	// WithStopOnMatch option.
+	StoppedOnMatch bool
+
+	// CandidatesPerByte is the histogram of the number of candidates tried
+	// to recover each byte, and LatencyMillis the one of the time, in
+	// milliseconds, the oracle took to answer each query. They are only
+	// filled when the WithHistograms option is used.
+	CandidatesPerByte *Histogram
+	LatencyMillis     *Histogram
+}
+
+// TrafficCounter is implemented by the oracles counting the bytes they send
+// and receive, like the ones in the oracle package when the byte accounting
+// is enabled. The counts are cumulative.
+type TrafficCounter interface {
+	Traffic() (sent, received int64)
+}
+
+// countTraffic returns a function that, when called, adds to the report the
+// bytes sent and received by the oracle since countTraffic was called. It does
+// nothing if the report is nil or the oracle doesn't implement
+// TrafficCounter.
+func countTraffic(report *DecryptReport, q Poracle) func() {
+	tc, ok := q.(TrafficCounter)
+	if report == nil || !ok {
+		return func() {}
+	}
+	sent, received := tc.Traffic()
+	return func() {
+		s, r := tc.Traffic()
+		report.BytesSent += s - sent
+		report.BytesReceived += r - received
+	}
+}
+
+// padLength returns the length of the PKCS#7 pad at the end of the given
+// plaintext, made of blocks of the given length. It returns
+// ErrInvalidRecoveredPad if the pad is not valid.
+func padLength(m []byte, blockLen int) (int, error) {
+	if len(m) == 0 {
+		return 0, ErrInvalidRecoveredPad
+	}
+	p := int(m[len(m)-1])
+	if p < 1 || p > blockLen || p > len(m) {
+		return 0, ErrInvalidRecoveredPad
+	}
+	for _, b := range m[len(m)-p:] {
+		if int(b) != p {
+			return 0, ErrInvalidRecoveredPad
+		}
+	}
+	return p, nil
+}
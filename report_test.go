@@ -0,0 +1,232 @@
+package goracler
+
+import (
+	"bytes"
+	"reflect"
+	"strings"
+	"testing"
+
+	"github.com/manelmontilla/goracler/crypto"
+)
+
+func TestDecryptReportFinalPadLength(t *testing.T) {
+	key := "ee581a043ac19191c7d551710bab13a9"
+	tests := []struct {
+		name string
+		msg  string
+		want int
+	}{
+		{
+			name: "ReportsPartialPadBlock",
+			msg:  "Hello world",
+			want: 5,
+		},
+		{
+			name: "ReportsFullPadBlock",
+			msg:  "Somewhere in la ",
+			want: 16,
+		},
+	}
+	for _, tt := range tests {
+		tt := tt
+		t.Run(tt.name, func(t *testing.T) {
+			c := testCiphertext(t, tt.msg)
+			var r DecryptReport
+			if _, err := Decrypt(c, testOracle{key: key}, nopLogger{}, WithReport(&r)); err != nil {
+				t.Fatal(err)
+			}
+			if r.FinalPadLength != tt.want {
+				t.Errorf("FinalPadLength = %d, want %d", r.FinalPadLength, tt.want)
+			}
+		})
+	}
+}
+
+func TestDecryptStructured(t *testing.T) {
+	key := "ee581a043ac19191c7d551710bab13a9"
+	msgs := []string{
+		"Hello world",
+		"a",
+		"Somewhere in la ",
+		"Somewhere in la Mancha",
+	}
+	for _, msg := range msgs {
+		c := testCiphertext(t, msg)
+		got, err := DecryptStructured(c, testOracle{key: key}, nopLogger{})
+		if err != nil {
+			t.Fatal(err)
+		}
+		if string(got.Data) != msg {
+			t.Errorf("DecryptStructured() Data = %q, want %q", got.Data, msg)
+		}
+		pad := CipherBlockLen - len(msg)%CipherBlockLen
+		if len(got.Padding) != pad {
+			t.Errorf("DecryptStructured() len(Padding) = %d, want %d", len(got.Padding), pad)
+		}
+		for _, b := range got.Padding {
+			if int(b) != pad {
+				t.Errorf("DecryptStructured() Padding = %v, want all bytes equal to %d", got.Padding, pad)
+				break
+			}
+		}
+	}
+}
+
+// blindOracle reports as invalid the pads of the probes decrypting the given
+// block.
+type blindOracle struct {
+	testOracle
+	block []byte
+}
+
+func (o blindOracle) Do(c []byte) (int, error) {
+	if bytes.Equal(c[len(c)-CipherBlockLen:], o.block) {
+		return 0, nil
+	}
+	return o.testOracle.Do(c)
+}
+
+func TestDecryptWithMaxQueriesPerBlock(t *testing.T) {
+	key := "ee581a043ac19191c7d551710bab13a9"
+	msg := "Somewhere in la Mancha, in a place whose name"
+	c := testCiphertext(t, msg)
+	q := blindOracle{testOracle{key: key}, c[2*CipherBlockLen : 3*CipherBlockLen]}
+	var r DecryptReport
+	got, err := Decrypt(c, q, nopLogger{}, WithMaxQueriesPerBlock(10000), WithReport(&r))
+	if err != nil {
+		t.Fatal(err)
+	}
+	padded := crypto.PCKCS5Pad([]byte(msg))
+	want := string(padded[:CipherBlockLen]) +
+		strings.Repeat(string(FailedBytePlaceholder), CipherBlockLen) +
+		string(padded[2*CipherBlockLen:])
+	if got != want {
+		t.Errorf("Decrypt() = %q, want %q", got, want)
+	}
+	if len(r.FailedBlocks) != 1 || r.FailedBlocks[0] != 1 {
+		t.Errorf("FailedBlocks = %v, want [1]", r.FailedBlocks)
+	}
+
+	// A budget too small to decrypt any block.
+	r = DecryptReport{}
+	_, err = Decrypt(c, testOracle{key: key}, nopLogger{}, WithMaxQueriesPerBlock(10), WithReport(&r))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(r.FailedBlocks) != 3 {
+		t.Errorf("FailedBlocks = %v, want [0 1 2]", r.FailedBlocks)
+	}
+}
+
+func TestDecryptReportHistograms(t *testing.T) {
+	key := "ee581a043ac19191c7d551710bab13a9"
+	msg := "Somewhere in la Mancha"
+	c := testCiphertext(t, msg)
+	var report DecryptReport
+	q := &countingOracle{Poracle: testOracle{key: key}}
+	if _, err := Decrypt(c, q, nopLogger{}, WithReport(&report), WithHistograms()); err != nil {
+		t.Fatal(err)
+	}
+	if report.LatencyMillis == nil || report.CandidatesPerByte == nil {
+		t.Fatal("got nil histograms")
+	}
+	if got := report.LatencyMillis.Total(); got != q.queries {
+		t.Errorf("got %d latencies, want one per query: %d", got, q.queries)
+	}
+	positions := int64(len(c) - CipherBlockLen)
+	if got := report.CandidatesPerByte.Total(); got != positions {
+		t.Errorf("got %d bytes in the candidates histogram, want %d", got, positions)
+	}
+	if n := len(report.LatencyMillis.Counts); n != len(LatencyHistogramBounds)+1 {
+		t.Errorf("got %d latency buckets, want %d", n, len(LatencyHistogramBounds)+1)
+	}
+
+	var plain DecryptReport
+	if _, err := Decrypt(c, testOracle{key: key}, nopLogger{}, WithReport(&plain)); err != nil {
+		t.Fatal(err)
+	}
+	if plain.LatencyMillis != nil || plain.CandidatesPerByte != nil {
+		t.Error("got histograms without the WithHistograms option")
+	}
+}
+
+func TestHistogram(t *testing.T) {
+	h := newHistogram([]float64{1, 10})
+	for _, v := range []float64{0, 1, 2, 10, 11, 100} {
+		h.add(v)
+	}
+	want := []int64{2, 2, 2}
+	for i := range want {
+		if h.Counts[i] != want[i] {
+			t.Fatalf("got counts %v, want %v", h.Counts, want)
+		}
+	}
+}
+
+func TestDecryptReportCoverage(t *testing.T) {
+	key := "ee581a043ac19191c7d551710bab13a9"
+	msg := "Somewhere in la Mancha, in a place whose name"
+	c := testCiphertext(t, msg)
+	tests := []struct {
+		name    string
+		decrypt func(r *DecryptReport) error
+		want    Coverage
+	}{
+		{
+			name: "FullRecovery",
+			decrypt: func(r *DecryptReport) error {
+				_, err := Decrypt(c, testOracle{key: key}, nopLogger{}, WithReport(r))
+				return err
+			},
+			want: Coverage{RecoveredBytes: 48, TotalBytes: 48, Recovered: []ByteRange{{0, 48}}},
+		},
+		{
+			name: "SkippedBytes",
+			decrypt: func(r *DecryptReport) error {
+				// The bytes 0 to 12 of the second block can't be
+				// recovered.
+				q := shortPadOracle{testOracle{key: key}, c[2*CipherBlockLen : 3*CipherBlockLen], 3}
+				_, err := Decrypt(c, q, nopLogger{}, WithReport(r), WithSkipUnrecoverableBytes(true))
+				return err
+			},
+			want: Coverage{RecoveredBytes: 35, TotalBytes: 48, Recovered: []ByteRange{{0, 16}, {29, 48}}},
+		},
+		{
+			name: "SkippedBytesWithSaltAndReversedBytes",
+			decrypt: func(r *DecryptReport) error {
+				q := shortPadOracle{testOracle{key: key}, c[2*CipherBlockLen : 3*CipherBlockLen], 3}
+				_, err := Decrypt(c, q, nopLogger{}, WithReport(r), WithSkipUnrecoverableBytes(true),
+					WithBlockByteOrder(ReversedByteOrder), WithPerBlockSalt(2))
+				return err
+			},
+			// Each block has 14 bytes of plaintext after removing the
+			// salt. Once reversed, the 13 bytes skipped are the last
+			// ones of the second block, and 2 of the 3 recovered are
+			// the salt.
+			want: Coverage{RecoveredBytes: 29, TotalBytes: 42, Recovered: []ByteRange{{0, 15}, {28, 42}}},
+		},
+		{
+			name: "TruncatedCiphertext",
+			decrypt: func(r *DecryptReport) error {
+				_, err := DecryptTruncated(c[:len(c)-CipherBlockLen], len(c), testOracle{key: key}, nopLogger{}, WithReport(r))
+				if err != ErrTruncatedCiphertext {
+					return err
+				}
+				return nil
+			},
+			want: Coverage{RecoveredBytes: 32, TotalBytes: 48, Recovered: []ByteRange{{0, 32}}},
+		},
+	}
+	for _, tt := range tests {
+		tt := tt
+		t.Run(tt.name, func(t *testing.T) {
+			var r DecryptReport
+			if err := tt.decrypt(&r); err != nil {
+				t.Fatal(err)
+			}
+			if !reflect.DeepEqual(r.Coverage, tt.want) {
+				t.Errorf("got coverage %+v, want %+v", r.Coverage, tt.want)
+			}
+		})
+	}
+}
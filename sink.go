@@ -0,0 +1,70 @@
+package goracler
+
+import (
+	"io"
+	"sort"
+)
+
+// flusher is implemented by writers buffering data, like bufio.Writer.
+type flusher interface {
+	Flush() error
+}
+
+// orderedSink writes the recovered blocks to a writer in the order they have
+// in the ciphertext, regardless of the order in which they are recovered.
+type orderedSink struct {
+	w        io.Writer
+	expected []int
+	next     int
+	pending  map[int][]byte
+}
+
+func newOrderedSink(w io.Writer, order []int) *orderedSink {
+	expected := append([]int(nil), order...)
+	sort.Ints(expected)
+	return &orderedSink{w: w, expected: expected, pending: make(map[int][]byte)}
+}
+
+// write stores the block with the given index and writes all the blocks that
+// are ready to be written.
+func (s *orderedSink) write(index int, block []byte) error {
+	s.pending[index] = block
+	written := false
+	for s.next < len(s.expected) {
+		b, ok := s.pending[s.expected[s.next]]
+		if !ok {
+			break
+		}
+		if _, err := s.w.Write(b); err != nil {
+			return err
+		}
+		delete(s.pending, s.expected[s.next])
+		s.next++
+		written = true
+	}
+	if f, ok := s.w.(flusher); ok && written {
+		return f.Flush()
+	}
+	return nil
+}
+
+// peakPending returns the maximum number of blocks held by an orderedSink,
+// including the one being written, when the blocks are written in the given
+// order.
+func peakPending(order []int) int {
+	expected := append([]int(nil), order...)
+	sort.Ints(expected)
+	pending := make(map[int]bool, len(order))
+	next, peak := 0, 0
+	for _, i := range order {
+		pending[i] = true
+		if len(pending) > peak {
+			peak = len(pending)
+		}
+		for next < len(expected) && pending[expected[next]] {
+			delete(pending, expected[next])
+			next++
+		}
+	}
+	return peak
+}
@@ -0,0 +1,159 @@
+package goracler
+
+import (
+	"bufio"
+	"bytes"
+	"crypto/aes"
+	"encoding/hex"
+	"io/ioutil"
+	"strings"
+	"testing"
+
+	"github.com/manelmontilla/goracler/crypto"
+)
+
+func TestDecryptWithPlaintextSink(t *testing.T) {
+	key := "ee581a043ac19191c7d551710bab13a9"
+	msg := "Somewhere in la Mancha, in a place whose name"
+	c := testCiphertext(t, msg)
+	padded := crypto.PCKCS5Pad([]byte(msg))
+	var out bytes.Buffer
+	w := bufio.NewWriter(&out)
+	_, err := Decrypt(c, testOracle{key: key}, nopLogger{},
+		WithBlockOrder([]int{1, 2, 0}), WithPlaintextSink(w))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !bytes.Equal(out.Bytes(), padded) {
+		t.Errorf("sink = %q, want %q", out.Bytes(), padded)
+	}
+}
+
+func TestOrderedSink(t *testing.T) {
+	var out bytes.Buffer
+	s := newOrderedSink(&out, []int{3, 1, 0})
+	steps := []struct {
+		index int
+		block string
+		want  string
+	}{
+		{3, "d", ""},
+		{1, "b", ""},
+		{0, "a", "abd"},
+	}
+	for _, st := range steps {
+		if err := s.write(st.index, []byte(st.block)); err != nil {
+			t.Fatal(err)
+		}
+		if out.String() != st.want {
+			t.Errorf("after writing block %d sink = %q, want %q", st.index, out.String(), st.want)
+		}
+	}
+}
+
+func TestDecryptWithLowMemory(t *testing.T) {
+	key := "ee581a043ac19191c7d551710bab13a9"
+	msg := "Somewhere in la Mancha, in a place whose name I do not care to remember"
+	c := testCiphertext(t, msg)
+	var sink bytes.Buffer
+	got, err := Decrypt(c, testOracle{key: key}, nopLogger{}, WithPlaintextSink(&sink), WithLowMemory())
+	if err != nil {
+		t.Fatal(err)
+	}
+	if got != "" {
+		t.Errorf("Decrypt() = %q, want an empty string", got)
+	}
+	if want := crypto.PCKCS5Pad([]byte(msg)); !bytes.Equal(sink.Bytes(), want) {
+		t.Errorf("got plaintext %q in the sink, want %q", sink.Bytes(), want)
+	}
+	if _, err := Decrypt(c, testOracle{key: key}, nopLogger{}, WithLowMemory()); err != ErrNoPlaintextSink {
+		t.Errorf("got error %v without a sink, want %v", err, ErrNoPlaintextSink)
+	}
+}
+
+func TestDecryptWithMaxInFlightBytes(t *testing.T) {
+	key := "ee581a043ac19191c7d551710bab13a9"
+	msg := strings.Repeat("A", 1024*CipherBlockLen-1)
+	c := testCiphertext(t, msg)
+	k, err := hex.DecodeString(key)
+	if err != nil {
+		t.Fatal(err)
+	}
+	bc, err := aes.NewCipher(k)
+	if err != nil {
+		t.Fatal(err)
+	}
+	// The intermediate values are stored in a Corpus, so the oracle is only
+	// queried by the checks performed before the attack.
+	corpus := NewCorpus()
+	for i := CipherBlockLen; i < len(c); i += CipherBlockLen {
+		d := make([]byte, CipherBlockLen)
+		bc.Decrypt(d, c[i:i+CipherBlockLen])
+		corpus.Add(c[i:i+CipherBlockLen], d)
+	}
+	// Attacking the blocks in swapped pairs, 1 0 3 2..., keeps at most two
+	// blocks in flight.
+	n := len(c)/CipherBlockLen - 1
+	var order []int
+	for i := 0; i < n; i += 2 {
+		order = append(order, i+1, i)
+	}
+	if got := peakPending(order); got != 2 {
+		t.Errorf("peakPending() = %d, want 2", got)
+	}
+	var sink bytes.Buffer
+	opts := []Option{WithCorpus(corpus), WithPlaintextSink(&sink), WithLowMemory(), WithMaxInFlightBytes(2 * CipherBlockLen)}
+	if _, err := Decrypt(c, testOracle{key: key}, nopLogger{}, append(opts, WithBlockOrder(order))...); err != nil {
+		t.Fatal(err)
+	}
+	if want := crypto.PCKCS5Pad([]byte(msg)); !bytes.Equal(sink.Bytes(), want) {
+		t.Errorf("got %d bytes of plaintext in the sink, want %d", sink.Len(), len(want))
+	}
+
+	// Attacking them in reverse order would keep all of them in flight.
+	q := &countingOracle{Poracle: testOracle{key: key}}
+	if _, err := Decrypt(c, q, nopLogger{}, append(opts, WithReverseBlocks())...); err != ErrInFlightBytesExceeded {
+		t.Errorf("Decrypt() error = %v, want %v", err, ErrInFlightBytesExceeded)
+	}
+	if q.queries != 0 {
+		t.Errorf("Decrypt() sent %d queries, want 0", q.queries)
+	}
+}
+
+// benchmarkDecrypt decrypts a ciphertext of 4096 blocks. The intermediate
+// values of the blocks are stored in a Corpus, so the oracle is not queried
+// and the benchmark only measures the assembly of the plaintext.
+func benchmarkDecrypt(b *testing.B, opts ...Option) {
+	key := "ee581a043ac19191c7d551710bab13a9"
+	c := testCiphertext(b, strings.Repeat("A", 4096*CipherBlockLen-1))
+	k, err := hex.DecodeString(key)
+	if err != nil {
+		b.Fatal(err)
+	}
+	bc, err := aes.NewCipher(k)
+	if err != nil {
+		b.Fatal(err)
+	}
+	corpus := NewCorpus()
+	for i := CipherBlockLen; i < len(c); i += CipherBlockLen {
+		d := make([]byte, CipherBlockLen)
+		bc.Decrypt(d, c[i:i+CipherBlockLen])
+		corpus.Add(c[i:i+CipherBlockLen], d)
+	}
+	opts = append(opts, WithCorpus(corpus), WithPlaintextSink(ioutil.Discard), WithoutAlwaysValidCheck())
+	b.ReportAllocs()
+	b.ResetTimer()
+	for i := 0; i < b.N; i++ {
+		if _, err := Decrypt(c, testOracle{key: key}, nopLogger{}, opts...); err != nil {
+			b.Fatal(err)
+		}
+	}
+}
+
+func BenchmarkDecrypt(b *testing.B) {
+	benchmarkDecrypt(b)
+}
+
+func BenchmarkDecryptWithLowMemory(b *testing.B) {
+	benchmarkDecrypt(b, WithLowMemory())
+}
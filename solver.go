@@ -0,0 +1,35 @@
+package goracler
+
+// Solver computes the bytes of plaintext from the intermediate values
+// recovered by the decrypt attacks. The intermediate value of a byte is the
+// value that, xored with the byte at the same position of the previous block,
+// produces the byte of plaintext whose pad is checked by the oracle. The
+// attack always recovers the intermediate values, and builds its probes,
+// according to the behaviour of the oracle, so a Solver can only change the
+// plaintext returned. It's useful for targets where the plaintext doesn't
+// follow the standard CBC relationship with the value checked by the oracle,
+// for instance, because the target xors a constant to the decrypted block
+// before checking the pad. Solvers are not used by Encrypt.
+type Solver interface {
+	// Plaintext returns the byte of plaintext at the position p of a block
+	// given its intermediate value d and the byte prev at the same position
+	// of the previous block of ciphertext.
+	Plaintext(p int, d, prev byte) byte
+}
+
+type cbcSolver struct{}
+
+func (cbcSolver) Plaintext(p int, d, prev byte) byte {
+	return d ^ prev
+}
+
+// CBCSolver is the Solver implementing the standard CBC relationship:
+// plaintext = intermediate XOR prev. It's the Solver used by default.
+var CBCSolver Solver = cbcSolver{}
+
+// WithSolver sets the Solver used by Decrypt to compute the plaintext.
+func WithSolver(s Solver) Option {
+	return func(c *config) {
+		c.solver = s
+	}
+}
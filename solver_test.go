@@ -0,0 +1,71 @@
+package goracler
+
+import (
+	"strings"
+	"testing"
+
+	"github.com/manelmontilla/goracler/crypto"
+)
+
+// maskedCheckOracle simulates a target that xors a constant to the plaintext
+// before checking its pad.
+type maskedCheckOracle struct {
+	testOracle
+	mask byte
+}
+
+func (o maskedCheckOracle) Do(c []byte) (int, error) {
+	masked := make([]byte, len(c))
+	copy(masked, c)
+	// Xoring the previous block is equivalent to xoring the plaintext.
+	n := len(masked)
+	for i := n - 2*CipherBlockLen; i < n-CipherBlockLen; i++ {
+		masked[i] ^= o.mask
+	}
+	return o.testOracle.Do(masked)
+}
+
+// maskSolver is the Solver for the maskedCheckOracle.
+type maskSolver byte
+
+func (s maskSolver) Plaintext(p int, d, prev byte) byte {
+	return d ^ prev ^ byte(s)
+}
+
+func TestDecryptWithSolver(t *testing.T) {
+	key := "ee581a043ac19191c7d551710bab13a9"
+	msg := "Somewhere in la Mancha, in a place"
+	c := testCiphertext(t, msg)
+	const mask = 0x5a
+	q := maskedCheckOracle{testOracle{key: key}, mask}
+	padded := crypto.PCKCS5Pad([]byte(msg))
+	tests := []struct {
+		name string
+		opts []Option
+		want []byte
+	}{
+		{
+			name: "ReturnsThePlaintextCheckedByDefault",
+			want: xorBlocks(padded, []byte(strings.Repeat(string([]byte{mask}), len(padded)))),
+		},
+		{
+			name: "ReturnsThePlaintextUsingTheSolver",
+			opts: []Option{WithSolver(maskSolver(mask))},
+			want: padded,
+		},
+	}
+	for _, tt := range tests {
+		tt := tt
+		t.Run(tt.name, func(t *testing.T) {
+			// The pad of the ciphertext is not valid for the oracle.
+			opts := append(tt.opts, WithClassifierCheck(false))
+			got, err := Decrypt(c, q, nopLogger{}, opts...)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if got != string(tt.want) {
+				t.Errorf("Decrypt() = %q, want %q", got, tt.want)
+			}
+		})
+	}
+}
@@ -0,0 +1,61 @@
+package goracler
+
+import (
+	"math/rand"
+	"time"
+)
+
+// SpotCheck checks the reliability of the oracle used to recover the given
+// plaintext, without repeating the whole attack, by attacking again a random
+// sample of sampleBytes bytes of the ciphertext c and comparing them with the
+// recovered ones. The result must be the plaintext returned by Decrypt for c
+// without applying any PayloadExtractor. Each byte is attacked using the
+// bytes of the result that follow it in its block, so a byte can't be checked
+// independently of them. It returns false when any of the bytes attacked
+// doesn't match, or none of its candidates produces a valid pad.
+//
+// If the oracle returns a wrong result for a byte with a probability e, the
+// probability of passing the check is (1-e)^sampleBytes. So, passing a check
+// of k bytes means, with 95% confidence, that e is less than 3/k.
+func SpotCheck(c []byte, result string, q Poracle, sampleBytes int, l Logger, opts ...Option) (bool, error) {
+	cfg := newConfig(opts)
+	if err := cfg.validate(); err != nil {
+		return false, err
+	}
+	n := len(c) / cfg.blockLen
+	if n < 2 || len(c)%cfg.blockLen != 0 || len(result) != len(c)-cfg.blockLen {
+		return false, ErrInvalidCiphertext
+	}
+	q = cfg.oracle(q)
+	rnd := rand.New(rand.NewSource(time.Now().UnixNano()))
+	// The bytes after a non-terminal pad can't be checked.
+	var positions []int
+	for _, i := range rnd.Perm(len(result)) {
+		if i%cfg.blockLen <= cfg.padEnd() {
+			positions = append(positions, i)
+		}
+	}
+	if sampleBytes > len(positions) {
+		sampleBytes = len(positions)
+	}
+	for _, i := range positions[:sampleBytes] {
+		b, p := i/cfg.blockLen, i%cfg.blockLen
+		prev := c[b*cfg.blockLen : (b+1)*cfg.blockLen]
+		current := c[(b+1)*cfg.blockLen : (b+2)*cfg.blockLen]
+		mi := []byte(result[b*cfg.blockLen : (b+1)*cfg.blockLen])
+		val, err := startPositionSearch(prev, current, q, mi, p, l, cfg).result()
+		if err == ErrNoValidByte {
+			l.Printf("\nspot check of byte %d of block %d: no valid value found", p, b)
+			return false, nil
+		}
+		if err != nil {
+			return false, err
+		}
+		got := val ^ prev[p] ^ cfg.padding.PadByte(cfg.padLen(p), 0)
+		if got != mi[p] {
+			l.Printf("\nspot check of byte %d of block %d: got 0x%02x, recovered 0x%02x", p, b, got, mi[p])
+			return false, nil
+		}
+	}
+	return true, nil
+}
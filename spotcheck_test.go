@@ -0,0 +1,62 @@
+package goracler
+
+import (
+	"math/rand"
+	"sync"
+	"testing"
+
+	"github.com/manelmontilla/goracler/crypto"
+)
+
+// flakyOracle simulates an oracle that reports a valid pad, regardless of the
+// pad, for one of each 64 queries on average.
+type flakyOracle struct {
+	Poracle
+	mu  sync.Mutex
+	rnd *rand.Rand
+}
+
+func (o *flakyOracle) Do(c []byte) (int, error) {
+	o.mu.Lock()
+	lie := o.rnd.Intn(64) == 0
+	o.mu.Unlock()
+	if lie {
+		return 1, nil
+	}
+	return o.Poracle.Do(c)
+}
+
+func TestSpotCheck(t *testing.T) {
+	key := "ee581a043ac19191c7d551710bab13a9"
+	msg := "Somewhere in la Mancha, in a place whose name"
+	c := testCiphertext(t, msg)
+	result := string(crypto.PCKCS5Pad([]byte(msg)))
+	tests := []struct {
+		name string
+		q    Poracle
+		want bool
+	}{
+		{
+			name: "PassesWithAReliableOracle",
+			q:    testOracle{key: key},
+			want: true,
+		},
+		{
+			name: "FailsWithAFlakyOracle",
+			q:    &flakyOracle{Poracle: testOracle{key: key}, rnd: rand.New(rand.NewSource(1))},
+			want: false,
+		},
+	}
+	for _, tt := range tests {
+		tt := tt
+		t.Run(tt.name, func(t *testing.T) {
+			got, err := SpotCheck(c, result, tt.q, 16, nopLogger{})
+			if err != nil {
+				t.Fatal(err)
+			}
+			if got != tt.want {
+				t.Errorf("SpotCheck() = %t, want %t", got, tt.want)
+			}
+		})
+	}
+}
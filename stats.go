@@ -0,0 +1,76 @@
+package goracler
+
+import "sync"
+
+// Stats is a snapshot of the counters of an AttackStats.
+type Stats struct {
+	// Queries is the number of queries sent to the oracle and Errors the
+	// number of them that returned an error.
+	Queries int64
+	Errors  int64
+
+	// BlocksRecovered and BytesRecovered are the number of blocks and bytes
+	// of plaintext recovered so far. They are updated each time a block is
+	// recovered.
+	BlocksRecovered int64
+	BytesRecovered  int64
+}
+
+// AttackStats counts the queries sent and the plaintext recovered by the
+// attacks it's passed to using the WithStats option, while they run. It's
+// safe for concurrent use, so it can be read, using Snapshot, from a
+// goroutine other than the ones running the attacks, and it can be shared by
+// several attacks running concurrently.
+type AttackStats struct {
+	mu sync.Mutex
+	s  Stats
+}
+
+// NewAttackStats returns an AttackStats with all the counters set to 0.
+func NewAttackStats() *AttackStats {
+	return &AttackStats{}
+}
+
+// Snapshot returns the current values of all the counters. The values are
+// read at once, so they are consistent between them.
+func (a *AttackStats) Snapshot() Stats {
+	a.mu.Lock()
+	defer a.mu.Unlock()
+	return a.s
+}
+
+func (a *AttackStats) query(err error) {
+	a.mu.Lock()
+	defer a.mu.Unlock()
+	a.s.Queries++
+	if err != nil {
+		a.s.Errors++
+	}
+}
+
+func (a *AttackStats) block(n int) {
+	a.mu.Lock()
+	defer a.mu.Unlock()
+	a.s.BlocksRecovered++
+	a.s.BytesRecovered += int64(n)
+}
+
+// WithStats makes the attack update the given stats while it runs.
+func WithStats(s *AttackStats) Option {
+	return func(c *config) {
+		c.stats = s
+	}
+}
+
+// statsOracle is a Poracle that counts the queries sent to the wrapped oracle
+// in the stats.
+type statsOracle struct {
+	Poracle
+	stats *AttackStats
+}
+
+func (o statsOracle) Do(c []byte) (int, error) {
+	res, err := o.Poracle.Do(c)
+	o.stats.query(err)
+	return res, err
+}
@@ -0,0 +1,50 @@
+package goracler
+
+import (
+	"testing"
+)
+
+func TestAttackStatsSnapshot(t *testing.T) {
+	key := "ee581a043ac19191c7d551710bab13a9"
+	msg := "Somewhere in la Mancha, in a place whose name I do not care to remember"
+	c := testCiphertext(t, msg)
+	stats := NewAttackStats()
+	q := &countingOracle{Poracle: testOracle{key: key}}
+	done := make(chan struct{})
+	snapshots := make(chan []Stats)
+	go func() {
+		var got []Stats
+		for {
+			select {
+			case <-done:
+				snapshots <- got
+				return
+			default:
+				got = append(got, stats.Snapshot())
+			}
+		}
+	}()
+	_, err := Decrypt(c, q, nopLogger{}, WithStats(stats))
+	close(done)
+	got := <-snapshots
+	if err != nil {
+		t.Fatal(err)
+	}
+	for i := 1; i < len(got); i++ {
+		if got[i].Queries < got[i-1].Queries || got[i].BytesRecovered < got[i-1].BytesRecovered {
+			t.Fatalf("snapshot %+v is older than the previous one %+v", got[i], got[i-1])
+		}
+		if got[i].BytesRecovered != got[i].BlocksRecovered*int64(CipherBlockLen) {
+			t.Fatalf("inconsistent snapshot %+v", got[i])
+		}
+	}
+	final := stats.Snapshot()
+	want := Stats{
+		Queries:         q.queries,
+		BlocksRecovered: int64(len(c)/CipherBlockLen - 1),
+		BytesRecovered:  int64(len(c) - CipherBlockLen),
+	}
+	if final != want {
+		t.Errorf("Snapshot() = %+v, want %+v", final, want)
+	}
+}
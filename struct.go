@@ -0,0 +1,159 @@
+package goracler
+
+import (
+	"errors"
+	"fmt"
+	"reflect"
+	"strings"
+)
+
+// ErrInvalidStruct is returned by DecryptStruct when the value passed in is
+// not a pointer to a struct or its fields are not tagged correctly.
+var ErrInvalidStruct = errors.New("invalid tagged struct")
+
+// structTag is the key of the struct tags read by DecryptStruct.
+const structTag = "goracler"
+
+// tagCodecs are the Codecs that can be set in the struct tags read by
+// DecryptStruct.
+var tagCodecs = map[string]Codec{
+	"hex":       HexCodec,
+	"base64":    Base64Codec,
+	"base64url": Base64URLCodec,
+	"base32":    Base32Codec,
+	"base58":    Base58Codec,
+}
+
+// DecryptStruct performs a decrypt attack in the same way Decrypt does for a
+// ciphertext held in a field of the struct pointed by v, and writes the
+// recovered plaintext to another field of the struct. The fields are
+// identified by tags with the format `goracler:"role[,encoding]"`, where role
+// is one of:
+//
+//   - ciphertext: the field holding the ciphertext. It's required, and it
+//     includes the IV unless an iv field is present.
+//   - iv: the field holding the IV, for ciphertexts where it's not prepended
+//     to the ciphertext. It's optional.
+//   - plaintext: the field the recovered plaintext is written to. It's
+//     required.
+//
+// The encoding is one of hex, base64, base64url, base32 or base58, and it's
+// used to decode the value of the ciphertext and iv fields, and to encode the
+// plaintext written to the plaintext field. When it's omitted the bytes are
+// used as they are. The fields must be exported and of type string or []byte.
+// The plaintext field is only written when the attack succeeds.
+func DecryptStruct(v interface{}, q Poracle, l Logger, opts ...Option) error {
+	rv := reflect.ValueOf(v)
+	if rv.Kind() != reflect.Ptr || rv.IsNil() || rv.Elem().Kind() != reflect.Struct {
+		return fmt.Errorf("%w: %T is not a pointer to a struct", ErrInvalidStruct, v)
+	}
+	fields, err := taggedFields(rv.Elem())
+	if err != nil {
+		return err
+	}
+	ct, ok := fields["ciphertext"]
+	if !ok {
+		return fmt.Errorf("%w: no ciphertext field", ErrInvalidStruct)
+	}
+	pt, ok := fields["plaintext"]
+	if !ok {
+		return fmt.Errorf("%w: no plaintext field", ErrInvalidStruct)
+	}
+	c, err := ct.read()
+	if err != nil {
+		return err
+	}
+	var m string
+	if ivf, ok := fields["iv"]; ok {
+		iv, err := ivf.read()
+		if err != nil {
+			return err
+		}
+		m, err = DecryptWithIV(iv, c, q, l, opts...)
+		if err != nil {
+			return err
+		}
+	} else {
+		m, err = Decrypt(c, q, l, opts...)
+		if err != nil {
+			return err
+		}
+	}
+	pt.write([]byte(m))
+	return nil
+}
+
+// taggedField is a field of a struct tagged for DecryptStruct.
+type taggedField struct {
+	name  string
+	v     reflect.Value
+	codec Codec
+}
+
+// taggedFields returns the fields of the struct tagged for DecryptStruct
+// indexed by their role.
+func taggedFields(s reflect.Value) (map[string]taggedField, error) {
+	fields := make(map[string]taggedField)
+	t := s.Type()
+	for i := 0; i < t.NumField(); i++ {
+		f := t.Field(i)
+		tag, ok := f.Tag.Lookup(structTag)
+		if !ok || tag == "-" {
+			continue
+		}
+		parts := strings.Split(tag, ",")
+		role := parts[0]
+		if role != "ciphertext" && role != "iv" && role != "plaintext" {
+			return nil, fmt.Errorf("%w: unknown role %q of field %s", ErrInvalidStruct, role, f.Name)
+		}
+		if _, ok := fields[role]; ok {
+			return nil, fmt.Errorf("%w: more than one %s field", ErrInvalidStruct, role)
+		}
+		tf := taggedField{name: f.Name, v: s.Field(i)}
+		if len(parts) > 1 && parts[1] != "" {
+			tf.codec, ok = tagCodecs[parts[1]]
+			if !ok {
+				return nil, fmt.Errorf("%w: unknown encoding %q of field %s", ErrInvalidStruct, parts[1], f.Name)
+			}
+		}
+		isBytes := f.Type.Kind() == reflect.Slice && f.Type.Elem().Kind() == reflect.Uint8
+		if f.Type.Kind() != reflect.String && !isBytes {
+			return nil, fmt.Errorf("%w: field %s is not a string or a []byte", ErrInvalidStruct, f.Name)
+		}
+		if !tf.v.CanSet() {
+			return nil, fmt.Errorf("%w: field %s is not exported", ErrInvalidStruct, f.Name)
+		}
+		fields[role] = tf
+	}
+	return fields, nil
+}
+
+// read returns the bytes held by the field, decoded if it has a Codec.
+func (f taggedField) read() ([]byte, error) {
+	var b []byte
+	if f.v.Kind() == reflect.String {
+		b = []byte(f.v.String())
+	} else {
+		b = f.v.Bytes()
+	}
+	if f.codec == nil {
+		return b, nil
+	}
+	d, err := f.codec.Decode(string(b))
+	if err != nil {
+		return nil, fmt.Errorf("decoding field %s: %w", f.name, err)
+	}
+	return d, nil
+}
+
+// write sets the field to the given bytes, encoded if it has a Codec.
+func (f taggedField) write(b []byte) {
+	if f.codec != nil {
+		b = []byte(f.codec.Encode(b))
+	}
+	if f.v.Kind() == reflect.String {
+		f.v.SetString(string(b))
+		return
+	}
+	f.v.SetBytes(b)
+}
@@ -0,0 +1,81 @@
+package goracler
+
+import (
+	"encoding/base64"
+	"encoding/hex"
+	"errors"
+	"testing"
+
+	"github.com/manelmontilla/goracler/crypto"
+)
+
+type testToken struct {
+	User      string
+	IV        string `goracler:"iv,hex"`
+	Data      []byte `goracler:"ciphertext,base64"`
+	Plaintext string `goracler:"plaintext"`
+}
+
+func TestDecryptStruct(t *testing.T) {
+	key := "ee581a043ac19191c7d551710bab13a9"
+	iv := "91db4482c4ffa9858338ab0e98ddf96c"
+	msg := "user=manel;role=admin"
+	ct, err := crypto.CBCEncrypt(iv, key, msg)
+	if err != nil {
+		t.Fatal(err)
+	}
+	c, err := hex.DecodeString(ct)
+	if err != nil {
+		t.Fatal(err)
+	}
+	q := testOracle{key: key}
+	token := testToken{
+		User: "manel",
+		IV:   iv,
+		Data: []byte(base64.StdEncoding.EncodeToString(c[CipherBlockLen:])),
+	}
+	if err := DecryptStruct(&token, q, nopLogger{}, WithPayloadExtractor(PKCS7Extractor)); err != nil {
+		t.Fatal(err)
+	}
+	if token.Plaintext != msg {
+		t.Errorf("got plaintext %q, want %q", token.Plaintext, msg)
+	}
+
+	var withoutIV struct {
+		Ciphertext string `goracler:"ciphertext,hex"`
+		Plaintext  []byte `goracler:"plaintext,hex"`
+	}
+	withoutIV.Ciphertext = ct
+	if err := DecryptStruct(&withoutIV, q, nopLogger{}, WithPayloadExtractor(PKCS7Extractor)); err != nil {
+		t.Fatal(err)
+	}
+	if want := hex.EncodeToString([]byte(msg)); string(withoutIV.Plaintext) != want {
+		t.Errorf("got plaintext %q, want %q", withoutIV.Plaintext, want)
+	}
+
+	invalid := []struct {
+		name string
+		v    interface{}
+	}{
+		{"NotAPointer", token},
+		{"MissingPlaintext", &struct {
+			C string `goracler:"ciphertext,hex"`
+		}{ct}},
+		{"UnknownEncoding", &struct {
+			C string `goracler:"ciphertext,rot13"`
+			P string `goracler:"plaintext"`
+		}{C: ct}},
+		{"UnsupportedType", &struct {
+			C string `goracler:"ciphertext,hex"`
+			P int    `goracler:"plaintext"`
+		}{C: ct}},
+	}
+	for _, tt := range invalid {
+		tt := tt
+		t.Run(tt.name, func(t *testing.T) {
+			if err := DecryptStruct(tt.v, q, nopLogger{}); !errors.Is(err, ErrInvalidStruct) {
+				t.Errorf("DecryptStruct() error = %v, want %v", err, ErrInvalidStruct)
+			}
+		})
+	}
+}
@@ -0,0 +1,63 @@
+package goracler
+
+import "errors"
+
+// ErrInvalidTaggedCiphertext is returned when the length of a ciphertext with
+// interleaved tags doesn't match the layout set with WithInterleavedTags.
+var ErrInvalidTaggedCiphertext = errors.New("invalid ciphertext with interleaved tags")
+
+// WithInterleavedTags sets the layout of the ciphertexts for targets where
+// each block is followed by a tag that is not part of the CBC chain. The
+// supported layout is:
+//
+//	iv || tag(iv) || block1 || tag(block1) || ... || blockN || tag(blockN)
+//
+// where each tag has a length of tagLen bytes and only depends on the block
+// before it. Decrypt removes the tags from the ciphertext before the attack,
+// and the tags of each probe, and of the ciphertext returned by Encrypt, are
+// computed using the computeTag function. The ciphertext passed to
+// WithFullMessageProbe must not contain the tags.
+func WithInterleavedTags(tagLen int, computeTag func(block []byte) []byte) Option {
+	return func(c *config) {
+		c.tagLen = tagLen
+		c.computeTag = computeTag
+	}
+}
+
+// stripTags returns the given ciphertext, made of blocks of the given length,
+// without the interleaved tags.
+func stripTags(c []byte, tagLen, blockLen int) ([]byte, error) {
+	n := blockLen + tagLen
+	if len(c)%n != 0 {
+		return nil, ErrInvalidTaggedCiphertext
+	}
+	s := make([]byte, 0, len(c)/n*blockLen)
+	for i := 0; i < len(c); i += n {
+		s = append(s, c[i:i+blockLen]...)
+	}
+	return s, nil
+}
+
+// insertTags returns the given ciphertext, made of blocks of the given length,
+// with a tag after each block.
+func insertTags(c []byte, computeTag func([]byte) []byte, blockLen int) []byte {
+	var t []byte
+	for i := 0; i+blockLen <= len(c); i += blockLen {
+		b := c[i : i+blockLen]
+		t = append(t, b...)
+		t = append(t, computeTag(b)...)
+	}
+	return t
+}
+
+// tagOracle is a Poracle that inserts the tags in the probes before sending
+// them to the wrapped oracle.
+type tagOracle struct {
+	Poracle
+	computeTag func([]byte) []byte
+	blockLen   int
+}
+
+func (o tagOracle) Do(c []byte) (int, error) {
+	return o.Poracle.Do(insertTags(c, o.computeTag, o.blockLen))
+}
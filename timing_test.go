@@ -0,0 +1,60 @@
+package goracler
+
+import (
+	"encoding/hex"
+	"testing"
+	"time"
+
+	"github.com/manelmontilla/goracler/crypto"
+)
+
+// leakyOracle simulates an oracle that always returns the same result but
+// takes longer to answer when the pad is valid.
+type leakyOracle struct {
+	key   string
+	delay time.Duration
+}
+
+func (o leakyOracle) Do(c []byte) (int, error) {
+	_, err := crypto.CBCDecrypt(o.key, hex.EncodeToString(c))
+	if err != nil && err != crypto.ErrInvalidPad {
+		return 0, err
+	}
+	if err == nil {
+		time.Sleep(o.delay)
+	}
+	return 0, nil
+}
+
+func TestDecryptWithTimingAttack(t *testing.T) {
+	key := "ee581a043ac19191c7d551710bab13a9"
+	msg := "Hello world"
+	c := testCiphertext(t, msg)
+	q := leakyOracle{key: key, delay: 2 * time.Millisecond}
+	got, err := DecryptUnpadded(c, q, nopLogger{}, WithTimingAttack(3))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if got != msg {
+		t.Errorf("DecryptUnpadded() = %q, want %q", got, msg)
+	}
+}
+
+func TestTimingScores(t *testing.T) {
+	d := []time.Duration{10, 11, 9, 10, 10, 12, 50, 10, 9}
+	center, scores := timingScores(d)
+	if center != 10 {
+		t.Errorf("timingScores() center = %d, want 10", center)
+	}
+	for i, z := range scores {
+		if outlier := z > TimingOutlierThreshold; outlier != (i == 6) {
+			t.Errorf("timingScores() score of %d = %f", d[i], z)
+		}
+	}
+	_, scores = timingScores([]time.Duration{5, 5, 5})
+	for _, z := range scores {
+		if z != 0 {
+			t.Errorf("timingScores() of equal latencies = %v, want zeros", scores)
+		}
+	}
+}
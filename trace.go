@@ -0,0 +1,156 @@
+package goracler
+
+import (
+	"fmt"
+	"io"
+	"sync"
+)
+
+// Trace collects, for each block and position attacked, the number of
+// candidates tried and the candidate that produced a valid pad. It's intended
+// to study and explain the attack. It can be exported as JSON using
+// encoding/json or as a graphviz graph using WriteDOT.
+type Trace struct {
+	// Candidates makes the trace also store every candidate tried and
+	// whether it produced a valid pad. As up to 256 candidates are tried
+	// for each position, it should only be used for small ciphertexts.
+	Candidates bool `json:"-"`
+	// Blocks contains the blocks in the order they were attacked.
+	Blocks []BlockTrace `json:"blocks"`
+
+	mu sync.Mutex
+}
+
+// BlockTrace is the trace of the attack on one block.
+type BlockTrace struct {
+	// Index is the index of the block in the plaintext.
+	Index int `json:"index"`
+	// Positions contains the trace of each position of the block, indexed
+	// by position.
+	Positions []PositionTrace `json:"positions"`
+}
+
+// PositionTrace is the trace of the search of the byte at one position of a
+// block.
+type PositionTrace struct {
+	Position int `json:"position"`
+	// Tried is the number of candidates tried.
+	Tried int `json:"tried"`
+	// Found is true if a candidate produced a valid pad.
+	Found bool `json:"found"`
+	// Value is the candidate that produced a valid pad.
+	Value byte `json:"value"`
+	// Attempts contains the candidates tried, only when the Candidates
+	// field of the Trace is true.
+	Attempts []Attempt `json:"attempts,omitempty"`
+}
+
+// Attempt is a candidate tried for a position.
+type Attempt struct {
+	Value byte `json:"value"`
+	Valid bool `json:"valid"`
+}
+
+// WithTraceCollector makes the attacks collect their trace in t.
+func WithTraceCollector(t *Trace) Option {
+	return func(c *config) {
+		c.trace = t
+	}
+}
+
+// startBlock adds a block, of the given length, to the trace. The next
+// candidates recorded belong to it.
+func (t *Trace) startBlock(index, blockLen int) {
+	if t == nil {
+		return
+	}
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	b := BlockTrace{Index: index, Positions: make([]PositionTrace, blockLen)}
+	for p := range b.Positions {
+		b.Positions[p].Position = p
+	}
+	t.Blocks = append(t.Blocks, b)
+}
+
+// try records a candidate tried for the position p of the current block.
+func (t *Trace) try(p int, g byte, valid bool) {
+	if t == nil {
+		return
+	}
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	if len(t.Blocks) == 0 {
+		return
+	}
+	pos := &t.Blocks[len(t.Blocks)-1].Positions[p]
+	pos.Tried++
+	if t.Candidates {
+		pos.Attempts = append(pos.Attempts, Attempt{Value: g, Valid: valid})
+	}
+}
+
+// found records the candidate that produced a valid pad for the position p of
+// the current block.
+func (t *Trace) found(p int, g byte) {
+	if t == nil {
+		return
+	}
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	if len(t.Blocks) == 0 {
+		return
+	}
+	pos := &t.Blocks[len(t.Blocks)-1].Positions[p]
+	pos.Found = true
+	pos.Value = g
+}
+
+// WriteDOT writes the trace as a graphviz graph with a node per block, a node
+// per position and, when the candidates are stored, a node per candidate.
+func (t *Trace) WriteDOT(w io.Writer) error {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	ew := &errWriter{w: w}
+	ew.printf("digraph trace {\n")
+	ew.printf("\tattack [shape=box];\n")
+	for _, b := range t.Blocks {
+		bn := fmt.Sprintf("b%d", b.Index)
+		ew.printf("\t%s [shape=box, label=\"block %d\"];\n", bn, b.Index)
+		ew.printf("\tattack -> %s;\n", bn)
+		for i := len(b.Positions) - 1; i >= 0; i-- {
+			pos := b.Positions[i]
+			pn := fmt.Sprintf("%sp%d", bn, pos.Position)
+			label := fmt.Sprintf("byte %d\\n%d tried", pos.Position, pos.Tried)
+			if pos.Found {
+				label = fmt.Sprintf("byte %d\\n0x%02x after %d tried", pos.Position, pos.Value, pos.Tried)
+			}
+			ew.printf("\t%s [label=\"%s\"];\n", pn, label)
+			ew.printf("\t%s -> %s;\n", bn, pn)
+			for j, a := range pos.Attempts {
+				an := fmt.Sprintf("%sc%d", pn, j)
+				color := "gray"
+				if a.Valid {
+					color = "green"
+				}
+				ew.printf("\t%s [label=\"0x%02x\", color=%s];\n", an, a.Value, color)
+				ew.printf("\t%s -> %s;\n", pn, an)
+			}
+		}
+	}
+	ew.printf("}\n")
+	return ew.err
+}
+
+// errWriter writes formatted strings to a writer until an error happens.
+type errWriter struct {
+	w   io.Writer
+	err error
+}
+
+func (e *errWriter) printf(format string, a ...interface{}) {
+	if e.err != nil {
+		return
+	}
+	_, e.err = fmt.Fprintf(e.w, format, a...)
+}
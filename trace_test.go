@@ -0,0 +1,55 @@
+package goracler
+
+import (
+	"bytes"
+	"encoding/json"
+	"strings"
+	"testing"
+)
+
+func TestTraceCollector(t *testing.T) {
+	key := "ee581a043ac19191c7d551710bab13a9"
+	c := testCiphertext(t, "Hello world")
+	trace := &Trace{Candidates: true}
+	_, err := Decrypt(c, testOracle{key: key}, nopLogger{}, WithSequentialExecution(), WithTraceCollector(trace))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(trace.Blocks) != 1 || len(trace.Blocks[0].Positions) != CipherBlockLen {
+		t.Fatalf("got trace with shape %+v, want one block with %d positions", trace.Blocks, CipherBlockLen)
+	}
+	for _, pos := range trace.Blocks[0].Positions {
+		// The candidates are tried in order, so the number of candidates
+		// tried is the value found plus one.
+		if !pos.Found || pos.Tried != int(pos.Value)+1 || len(pos.Attempts) != pos.Tried {
+			t.Errorf("invalid trace for position %d: %+v", pos.Position, pos)
+			continue
+		}
+		last := pos.Attempts[len(pos.Attempts)-1]
+		if !last.Valid || last.Value != pos.Value {
+			t.Errorf("got last attempt %+v for position %d, want the valid value 0x%02x", last, pos.Position, pos.Value)
+		}
+	}
+
+	data, err := json.Marshal(trace)
+	if err != nil {
+		t.Fatal(err)
+	}
+	var decoded Trace
+	if err := json.Unmarshal(data, &decoded); err != nil {
+		t.Fatal(err)
+	}
+	if len(decoded.Blocks) != 1 || decoded.Blocks[0].Positions[0].Value != trace.Blocks[0].Positions[0].Value {
+		t.Errorf("got decoded trace %+v, want %+v", decoded.Blocks, trace.Blocks)
+	}
+
+	var dot bytes.Buffer
+	if err := trace.WriteDOT(&dot); err != nil {
+		t.Fatal(err)
+	}
+	for _, want := range []string{"digraph trace {", "attack -> b0;", "b0 -> b0p15;", "b0p15 -> b0p15c0;"} {
+		if !strings.Contains(dot.String(), want) {
+			t.Errorf("DOT graph:\n%s\nwant it to contain %q", dot.String(), want)
+		}
+	}
+}
@@ -0,0 +1,70 @@
+package goracler
+
+// Transform decrypts the given ciphertext, in the same way Decrypt does,
+// modifies its plaintext using the modify function, and forges a ciphertext
+// of the modified plaintext, in the same way Encrypt does. The modify function
+// receives the plaintext without the pad and can return a plaintext of any
+// length, which is padded before forging it.
+//
+// Instead of forging the whole ciphertext from scratch, the forged ciphertext
+// ends with the last block of the original one and reuses the intermediate
+// values recovered by the decrypt attack: the blocks are forged backwards, and
+// the oracle is only queried for the blocks of the forged ciphertext not
+// present in the original one. So, when the length of the plaintext doesn't
+// change, every block before the last modified one is attacked, and modifying
+// only the first block doesn't require any query.
+//
+// As Encrypt does, Transform always uses the CBCSolver, because forging needs
+// the intermediate values as seen by the oracle.
+func Transform(c []byte, modify func(plaintext []byte) []byte, q Poracle, l Logger, opts ...Option) ([]byte, error) {
+	corpus := NewCorpus()
+	opts = append(append([]Option(nil), opts...), WithSolver(CBCSolver), WithCorpus(corpus))
+	cfg := newConfig(opts)
+	if cfg.padEnd() != cfg.blockLen-1 {
+		return nil, ErrUnsupportedPadding
+	}
+	m, err := Decrypt(c, q, l, opts...)
+	if err != nil {
+		return nil, err
+	}
+	plaintext, err := unpad(cfg.padding, []byte(m), cfg.blockLen)
+	if err != nil {
+		return nil, err
+	}
+	payload := pad(cfg.padding, modify(plaintext), cfg.blockLen)
+	if cfg.computeTag != nil {
+		c, err = stripTags(c, cfg.tagLen, cfg.blockLen)
+		if err != nil {
+			return nil, err
+		}
+	}
+	if cfg.forward != nil {
+		c = cfg.forward(c)
+	}
+	q = cfg.oracle(q)
+
+	current := c[len(c)-cfg.blockLen:]
+	forged := append([]byte(nil), current...)
+	zero := make([]byte, cfg.blockLen)
+	for i := len(payload)/cfg.blockLen - 1; i >= 0; i-- {
+		d, ok := corpus.Intermediate(current)
+		if !ok {
+			cfg.trace.startBlock(i, cfg.blockLen)
+			cfg.block, cfg.blocks = i, len(payload)/cfg.blockLen
+			d, err = decryptBlock(zero, current, q, l, cfg)
+			if err != nil {
+				return nil, err
+			}
+			corpus.Add(current, d)
+		}
+		current = xorBlocks(payload[cfg.blockLen*i:cfg.blockLen*(i+1)], d)
+		forged = append(current, forged...)
+	}
+	if cfg.inverse != nil {
+		forged = cfg.inverse(forged)
+	}
+	if cfg.computeTag != nil {
+		forged = insertTags(forged, cfg.computeTag, cfg.blockLen)
+	}
+	return forged, nil
+}
@@ -0,0 +1,91 @@
+package goracler
+
+import (
+	"bytes"
+	"encoding/hex"
+	"testing"
+
+	"github.com/manelmontilla/goracler/crypto"
+)
+
+func TestTransform(t *testing.T) {
+	key := "ee581a043ac19191c7d551710bab13a9"
+	msg := "role=user;name=Alonso Quijano;city=la Mancha"
+	tests := []struct {
+		name   string
+		modify func([]byte) []byte
+		// sameBlocks is the number of trailing blocks of the forged
+		// ciphertext that must be equal to the ones of the original.
+		sameBlocks int
+	}{
+		{
+			name: "ModifiesTheFirstBlock",
+			modify: func(m []byte) []byte {
+				return bytes.Replace(m, []byte("role=user"), []byte("role=root"), 1)
+			},
+			sameBlocks: 3,
+		},
+		{
+			name: "ModifiesTheLastBlock",
+			modify: func(m []byte) []byte {
+				return bytes.Replace(m, []byte("la Mancha"), []byte("Toboso"), 1)
+			},
+			sameBlocks: 1,
+		},
+	}
+	for _, tt := range tests {
+		tt := tt
+		t.Run(tt.name, func(t *testing.T) {
+			c := testCiphertext(t, msg)
+			forged, err := Transform(c, tt.modify, testOracle{key: key}, nopLogger{})
+			if err != nil {
+				t.Fatal(err)
+			}
+			got, err := crypto.CBCDecrypt(key, hex.EncodeToString(forged))
+			if err != nil {
+				t.Fatal(err)
+			}
+			if want := string(tt.modify([]byte(msg))); got != want {
+				t.Errorf("got forged plaintext %q, want %q", got, want)
+			}
+			n := tt.sameBlocks * CipherBlockLen
+			if !bytes.Equal(forged[len(forged)-n:], c[len(c)-n:]) {
+				t.Errorf("got forged ciphertext %x, want it to end with %x", forged, c[len(c)-n:])
+			}
+		})
+	}
+}
+
+func TestTransformWithInterleavedTags(t *testing.T) {
+	key := "ee581a043ac19191c7d551710bab13a9"
+	msg := "role=user;name=Alonso Quijano;city=la Mancha"
+	c := insertTags(testCiphertext(t, msg), testTag, CipherBlockLen)
+	q := taggedOracle{testOracle{key: key}}
+	modify := func(m []byte) []byte {
+		return bytes.Replace(m, []byte("role=user"), []byte("role=root"), 1)
+	}
+	// The Solver must be ignored: forging needs the intermediate values.
+	opts := []Option{WithInterleavedTags(testTagLen, testTag), WithSolver(maskSolver(0x5a))}
+	forged, err := Transform(c, modify, q, nopLogger{}, opts...)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if res, err := q.Do(forged); err != nil || res == 0 {
+		t.Fatalf("the oracle rejected the forged ciphertext: %v", err)
+	}
+	stripped, err := stripTags(forged, testTagLen, CipherBlockLen)
+	if err != nil {
+		t.Fatal(err)
+	}
+	got, err := crypto.CBCDecrypt(key, hex.EncodeToString(stripped))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if want := string(modify([]byte(msg))); got != want {
+		t.Errorf("got forged plaintext %q, want %q", got, want)
+	}
+	n := 3 * (CipherBlockLen + testTagLen)
+	if !bytes.Equal(forged[len(forged)-n:], c[len(c)-n:]) {
+		t.Errorf("got forged ciphertext %x, want it to end with %x", forged, c[len(c)-n:])
+	}
+}
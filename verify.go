@@ -0,0 +1,59 @@
+package goracler
+
+// VerifyPlaintext checks whether the block of plaintext at the given index,
+// where the block 0 is the one following the IV, is equal to the guess,
+// without recovering it. The guess must be a whole block, otherwise it returns
+// ErrInvalidGuess. It xors the block before the attacked one with the guess
+// and with a pad filling the whole block, so the oracle reports a valid pad
+// only if the plaintext matches the guess in all its bytes. A second query,
+// with the first byte of the pad broken, must then be reported as invalid,
+// which rules out oracles that only check the last byte of the pad. So
+// verifying a guess costs two queries, while recovering a block costs around
+// 128 queries per byte, that is, around 2048 per block. A guess that doesn't
+// match costs only one query. With a NonTerminalPadding the bytes of the
+// trailer are not verified. The options apply as they do for Decrypt,
+// although only the ones related to the oracle and to the probes are
+// meaningful.
+func VerifyPlaintext(c []byte, blockIndex int, guess []byte, q Poracle, l Logger, opts ...Option) (bool, error) {
+	cfg := newConfig(opts)
+	if err := cfg.validate(); err != nil {
+		return false, err
+	}
+	if len(guess) != cfg.blockLen {
+		return false, ErrInvalidGuess
+	}
+	n := len(c) / cfg.blockLen
+	if n < 2 || len(c)%cfg.blockLen != 0 {
+		return false, ErrInvalidCiphertext
+	}
+	if blockIndex < 0 || blockIndex >= n-1 {
+		return false, ErrInvalidBlockIndex
+	}
+	p := c[blockIndex*cfg.blockLen : (blockIndex+2)*cfg.blockLen]
+	q = cfg.oracle(q)
+	prev, current := p[:cfg.blockLen], p[cfg.blockLen:]
+	forged := make([]byte, cfg.blockLen)
+	copy(forged, prev)
+	for i := 0; i <= cfg.padEnd(); i++ {
+		forged[i] ^= guess[i] ^ cfg.padding.PadByte(cfg.padLen(0), i)
+	}
+	res, err := q.Do(cfg.buildProbe(forged, current))
+	if err != nil {
+		return false, err
+	}
+	if res == 0 {
+		l.Printf("\nthe guess for block %d doesn't match", blockIndex+1)
+		return false, nil
+	}
+	forged[0] ^= 0xff
+	res, err = q.Do(cfg.buildProbe(forged, current))
+	if err != nil {
+		return false, err
+	}
+	if res > 0 {
+		l.Printf("\nthe oracle accepted a broken pad, the guess for block %d can't be verified", blockIndex+1)
+		return false, nil
+	}
+	l.Printf("\nthe guess for block %d matches", blockIndex+1)
+	return true, nil
+}
@@ -0,0 +1,57 @@
+package goracler
+
+import (
+	"testing"
+)
+
+func TestVerifyPlaintext(t *testing.T) {
+	key := "ee581a043ac19191c7d551710bab13a9"
+	msg := "user=manel;role=admin;expires=1700000000"
+	c := testCiphertext(t, msg)
+	tests := []struct {
+		name        string
+		q           Poracle
+		guess       string
+		want        bool
+		wantQueries int64
+	}{
+		{
+			name:        "ConfirmsACorrectGuess",
+			q:           testOracle{key: key},
+			guess:       "admin;expires=17",
+			want:        true,
+			wantQueries: 2,
+		},
+		{
+			name:        "RefutesAnIncorrectGuess",
+			q:           testOracle{key: key},
+			guess:       "guest;expires=17",
+			wantQueries: 1,
+		},
+		{
+			name:        "RefutesGuessesWithOraclesCheckingOnlyTheLastByte",
+			q:           lastByteOracle{key: key},
+			guess:       "admin;expires=17",
+			wantQueries: 2,
+		},
+	}
+	for _, tt := range tests {
+		tt := tt
+		t.Run(tt.name, func(t *testing.T) {
+			q := &countingOracle{Poracle: tt.q}
+			got, err := VerifyPlaintext(c, 1, []byte(tt.guess), q, nopLogger{})
+			if err != nil {
+				t.Fatal(err)
+			}
+			if got != tt.want {
+				t.Errorf("VerifyPlaintext() = %t, want %t", got, tt.want)
+			}
+			if q.queries != tt.wantQueries {
+				t.Errorf("got %d queries, want %d", q.queries, tt.wantQueries)
+			}
+		})
+	}
+	if _, err := VerifyPlaintext(c, 1, []byte("admin"), testOracle{key: key}, nopLogger{}); err != ErrInvalidGuess {
+		t.Errorf("VerifyPlaintext() error = %v, want %v", err, ErrInvalidGuess)
+	}
+}
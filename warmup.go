@@ -0,0 +1,56 @@
+package goracler
+
+import "time"
+
+// WithWarmup makes Decrypt send the given number of throwaway probes to the
+// oracle before starting the attack, to let it stabilize, for instance, by
+// warming up its caches. Half of the probes are the last two blocks of the
+// ciphertext, which have a valid pad, and the other half the same blocks with
+// the last byte of the pad modified, which makes it invalid. The number of
+// probes classified as expected and their average latencies are logged.
+func WithWarmup(probes int) Option {
+	return func(c *config) {
+		c.warmup = probes
+	}
+}
+
+// warmupResult contains the results of the warmup probes of each kind.
+type warmupResult struct {
+	sent     int
+	expected int
+	elapsed  time.Duration
+}
+
+func (r warmupResult) avgLatency() time.Duration {
+	if r.sent == 0 {
+		return 0
+	}
+	return r.elapsed / time.Duration(r.sent)
+}
+
+// warmup sends the warmup probes built from the given valid pair of blocks.
+func warmup(q Poracle, prev, current []byte, l Logger, cfg *config) error {
+	invalid := invalidPad(prev, cfg)
+	var results [2]warmupResult
+	for i := 0; i < cfg.warmup; i++ {
+		valid := i%2 == 0
+		p := prev
+		if !valid {
+			p = invalid
+		}
+		start := time.Now()
+		res, err := q.Do(cfg.buildProbe(p, current))
+		if err != nil {
+			return err
+		}
+		r := &results[i%2]
+		r.elapsed += time.Since(start)
+		r.sent++
+		if (res > 0) == valid {
+			r.expected++
+		}
+	}
+	l.Printf("\nwarmup: %d of %d valid probes reported as valid, avg latency %s", results[0].expected, results[0].sent, results[0].avgLatency())
+	l.Printf("\nwarmup: %d of %d invalid probes reported as invalid, avg latency %s", results[1].expected, results[1].sent, results[1].avgLatency())
+	return nil
+}
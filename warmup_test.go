@@ -0,0 +1,35 @@
+package goracler
+
+import (
+	"sync/atomic"
+	"testing"
+)
+
+// coldOracle simulates an oracle that reports all the pads as invalid for
+// its first queries.
+type coldOracle struct {
+	Poracle
+	cold    int64
+	queries int64
+}
+
+func (o *coldOracle) Do(c []byte) (int, error) {
+	if atomic.AddInt64(&o.queries, 1) <= o.cold {
+		return 0, nil
+	}
+	return o.Poracle.Do(c)
+}
+
+func TestDecryptWithWarmup(t *testing.T) {
+	key := "ee581a043ac19191c7d551710bab13a9"
+	msg := "Hello world"
+	c := testCiphertext(t, msg)
+	q := &coldOracle{Poracle: testOracle{key: key}, cold: 300}
+	got, err := DecryptUnpadded(c, q, nopLogger{}, WithWarmup(300))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if got != msg {
+		t.Errorf("DecryptUnpadded() = %q, want %q", got, msg)
+	}
+}